@@ -0,0 +1,159 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.27.1
+// source: biathlon.proto
+
+package api
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Biathlon_SubmitEvent_FullMethodName  = "/biathlon.Biathlon/SubmitEvent"
+	Biathlon_GetStandings_FullMethodName = "/biathlon.Biathlon/GetStandings"
+)
+
+// BiathlonClient is the client API for Biathlon service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type BiathlonClient interface {
+	SubmitEvent(ctx context.Context, in *Event, opts ...grpc.CallOption) (*SubmitEventResponse, error)
+	GetStandings(ctx context.Context, in *GetStandingsRequest, opts ...grpc.CallOption) (*Standings, error)
+}
+
+type biathlonClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBiathlonClient(cc grpc.ClientConnInterface) BiathlonClient {
+	return &biathlonClient{cc}
+}
+
+func (c *biathlonClient) SubmitEvent(ctx context.Context, in *Event, opts ...grpc.CallOption) (*SubmitEventResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SubmitEventResponse)
+	err := c.cc.Invoke(ctx, Biathlon_SubmitEvent_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *biathlonClient) GetStandings(ctx context.Context, in *GetStandingsRequest, opts ...grpc.CallOption) (*Standings, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Standings)
+	err := c.cc.Invoke(ctx, Biathlon_GetStandings_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BiathlonServer is the server API for Biathlon service.
+// All implementations must embed UnimplementedBiathlonServer
+// for forward compatibility.
+type BiathlonServer interface {
+	SubmitEvent(context.Context, *Event) (*SubmitEventResponse, error)
+	GetStandings(context.Context, *GetStandingsRequest) (*Standings, error)
+	mustEmbedUnimplementedBiathlonServer()
+}
+
+// UnimplementedBiathlonServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedBiathlonServer struct{}
+
+func (UnimplementedBiathlonServer) SubmitEvent(context.Context, *Event) (*SubmitEventResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitEvent not implemented")
+}
+func (UnimplementedBiathlonServer) GetStandings(context.Context, *GetStandingsRequest) (*Standings, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStandings not implemented")
+}
+func (UnimplementedBiathlonServer) mustEmbedUnimplementedBiathlonServer() {}
+func (UnimplementedBiathlonServer) testEmbeddedByValue()                  {}
+
+// UnsafeBiathlonServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BiathlonServer will
+// result in compilation errors.
+type UnsafeBiathlonServer interface {
+	mustEmbedUnimplementedBiathlonServer()
+}
+
+func RegisterBiathlonServer(s grpc.ServiceRegistrar, srv BiathlonServer) {
+	// If the following call pancis, it indicates UnimplementedBiathlonServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Biathlon_ServiceDesc, srv)
+}
+
+func _Biathlon_SubmitEvent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Event)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BiathlonServer).SubmitEvent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Biathlon_SubmitEvent_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BiathlonServer).SubmitEvent(ctx, req.(*Event))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Biathlon_GetStandings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStandingsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BiathlonServer).GetStandings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Biathlon_GetStandings_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BiathlonServer).GetStandings(ctx, req.(*GetStandingsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Biathlon_ServiceDesc is the grpc.ServiceDesc for Biathlon service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Biathlon_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "biathlon.Biathlon",
+	HandlerType: (*BiathlonServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SubmitEvent",
+			Handler:    _Biathlon_SubmitEvent_Handler,
+		},
+		{
+			MethodName: "GetStandings",
+			Handler:    _Biathlon_GetStandings_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "biathlon.proto",
+}
@@ -0,0 +1,4 @@
+// Package api содержит gRPC-интерфейс приёма событий и запроса результатов.
+package api
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative biathlon.proto
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"biathlon_system/internal/race"
+
+	"github.com/sirupsen/logrus"
+)
+
+// processDir обрабатывает каждый файл событий в дереве каталогов как
+// отдельную гонку и возвращает число гонок, завершившихся ошибкой.
+// Ошибка в одной гонке не прерывает обработку остальных.
+func processDir(ctx context.Context, opts options, globalConfigs race.Configs) int {
+	var processed int
+	var failed []string
+
+	err := filepath.WalkDir(opts.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			failed = append(failed, path)
+			logrus.Errorf("Ошибка обхода %s: %s", path, err)
+			return nil
+		}
+		if d.IsDir() || !isEventsFile(d.Name()) {
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return filepath.SkipAll
+		}
+		if err := processRaceFile(ctx, opts, path, globalConfigs); err != nil {
+			failed = append(failed, path)
+			logrus.Errorf("Гонка %s не обработана: %s", path, err)
+			return nil
+		}
+		processed++
+		return nil
+	})
+	if err != nil {
+		logrus.Errorf("Ошибка обхода каталога %s: %s", opts.dir, err)
+	}
+
+	logrus.Infof("Обработано гонок: %d, с ошибками: %d", processed, len(failed))
+	for _, path := range failed {
+		logrus.Warnf("Не обработана: %s", path)
+	}
+
+	return len(failed)
+}
+
+// isEventsFile отбирает файлы вида events, events.log, events.gz и т.п.
+func isEventsFile(name string) bool {
+	return name == "events" || strings.HasPrefix(name, "events.")
+}
+
+func processRaceFile(ctx context.Context, opts options, path string, globalConfigs race.Configs) error {
+	dir := filepath.Dir(path)
+
+	configs, err := raceConfigFor(dir, globalConfigs)
+	if err != nil {
+		return err
+	}
+	configs = configs.WithOptions(opts.raceOptions)
+	opts.configs = configs
+
+	input, err := openInput(path)
+	if err != nil {
+		return fmt.Errorf("Ошибка открытия файла событий: %w", err)
+	}
+	defer input.Close()
+	logrus.Infof("Обработка файла событий: %s", path)
+
+	races := race.NewSet(configs)
+	stats := newProcessStats(opts.mode, configs)
+	stats.rejectUnknown = opts.rejectUnknown
+	err = processEvents(ctx, opts.newSource()(input), races, stats)
+	stats.logSummary()
+	if err != nil {
+		return err
+	}
+
+	outDir := dir
+	if opts.outDir != "" {
+		rel, err := filepath.Rel(opts.dir, dir)
+		if err != nil {
+			return err
+		}
+		outDir = filepath.Join(opts.outDir, rel)
+	}
+	eventsPath := ""
+	if opts.outEventsPath != "" {
+		eventsPath = filepath.Join(outDir, "output_events")
+	}
+
+	outputs := []reportOutput{{writer: opts.reportWriter(""), path: filepath.Join(outDir, "resulting_table")}}
+	if opts.outputSpec != "" {
+		outputs = nil
+		for _, output := range opts.reportOutputs() {
+			outputs = append(outputs, reportOutput{writer: output.writer, path: filepath.Join(outDir, filepath.Base(output.path))})
+		}
+	}
+
+	if err := writeRaceReports(races, outputs, eventsPath); err != nil {
+		return err
+	}
+	return stats.incomplete()
+}
+
+// raceConfigFor берёт configs/config.<ext> рядом с файлом событий,
+// а если его нет — общую конфигурацию
+func raceConfigFor(dir string, globalConfigs race.Configs) (race.Configs, error) {
+	v, err := readConfigDir(dir)
+	if errors.Is(err, errConfigNotFound) {
+		return globalConfigs, nil
+	}
+	if err != nil {
+		return race.Configs{}, fmt.Errorf("Ошибка чтения конфигурации гонки: %w", err)
+	}
+	logrus.Infof("Конфигурация гонки: %s", v.ConfigFileUsed())
+
+	configs, err := race.LoadConfigs(v)
+	if err != nil {
+		return race.Configs{}, fmt.Errorf("%s: %w", v.ConfigFileUsed(), err)
+	}
+	return configs, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"biathlon_system/internal/race"
+
+	"github.com/sirupsen/logrus"
+)
+
+// brokerMessage — сообщение брокера, содержащее одно событие
+type brokerMessage struct {
+	payload []byte
+	// commit подтверждает обработку сообщения брокеру
+	commit func(ctx context.Context) error
+}
+
+// messageConsumer скрывает конкретный брокер сообщений, чтобы обработка
+// событий от него не зависела
+type messageConsumer interface {
+	fetch(ctx context.Context) (brokerMessage, error)
+	close() error
+}
+
+type consumeOptions struct {
+	newSource func(io.Reader) eventSource
+	// endMarker — содержимое сообщения, означающего конец гонки
+	endMarker  string
+	deadLetter io.Writer
+	// clock — часы для отметок времени в журнале недоставленных
+	clock race.Clock
+	// dedup, если задан, отбрасывает повторную доставку одних и тех же событий
+	dedup *eventDedup
+}
+
+// consumeMessages обрабатывает сообщения до маркера конца гонки или отмены ctx.
+// Сообщение подтверждается только после обработки; сообщения с ошибками
+// сохраняются в журнал недоставленных вместе с исходным содержимым.
+func consumeMessages(ctx context.Context, consumer messageConsumer, races *race.Set, opts consumeOptions) error {
+	for {
+		msg, err := consumer.fetch(ctx)
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		payload := bytes.TrimRight(msg.payload, "\r\n")
+		if opts.endMarker != "" && string(payload) == opts.endMarker {
+			logrus.Info("Получен маркер окончания гонки")
+			return msg.commit(ctx)
+		}
+
+		if err := applyPayload(payload, races, opts.newSource, opts.dedup); err != nil {
+			logrus.Errorf("Ошибка обработки сообщения: %s", err)
+			if err := writeDeadLetter(opts.deadLetter, opts.clock.Now(), payload, err); err != nil {
+				return fmt.Errorf("Ошибка записи в журнал недоставленных сообщений: %w", err)
+			}
+		}
+
+		if err := msg.commit(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			return err
+		}
+	}
+}
+
+func applyPayload(payload []byte, races *race.Set, newSource func(io.Reader) eventSource, dedup *eventDedup) error {
+	src := newSource(bytes.NewReader(payload))
+	for {
+		ev, err := src.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if dedup != nil && !dedup.add(ev) {
+			logrus.Infof("Повторная доставка события пропущена: %s", ev.Raw)
+			continue
+		}
+		if err := races.Apply(ev); err != nil {
+			return err
+		}
+	}
+}
+
+func writeDeadLetter(w io.Writer, now time.Time, payload []byte, cause error) error {
+	_, err := fmt.Fprintf(w, "%s\t%s\t%s\n", now.Format(time.RFC3339), cause, payload)
+	return err
+}
+
+func openDeadLetter(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"biathlon_system/internal/race"
+	"biathlon_system/parser"
+
+	"github.com/sirupsen/logrus"
+)
+
+// checkpointSink считает прочитанные из источника события и, если задан
+// path, сохраняет снимок состояния каждые every событий
+type checkpointSink struct {
+	races     *race.Set
+	path      string
+	every     int
+	processed int
+}
+
+func (c *checkpointSink) Apply(ev parser.Event) error {
+	// Событие с ошибкой тоже учитывается: при возобновлении skipEvents
+	// пропускает события источника, и в мягком режиме такое событие не
+	// должно применяться повторно
+	c.processed++
+	err := c.races.Apply(ev)
+	if c.path != "" && c.processed%c.every == 0 {
+		if saveErr := c.save(); saveErr != nil {
+			return saveErr
+		}
+	}
+	return err
+}
+
+func (c *checkpointSink) save() error {
+	if err := race.SaveCheckpoint(c.path, c.races.Snapshot(c.processed)); err != nil {
+		return outputError(fmt.Errorf("Ошибка сохранения контрольной точки %s: %w", c.path, err))
+	}
+	return nil
+}
+
+// skipEvents пропускает первые n событий источника, уже учтённые в
+// контрольной точке
+type skipEvents struct {
+	src eventSource
+	n   int
+}
+
+func (s *skipEvents) next() (parser.Event, error) {
+	for ; s.n > 0; s.n-- {
+		if _, err := s.src.next(); err != nil {
+			if err == io.EOF {
+				logrus.Warn("Файл событий короче, чем указано в контрольной точке")
+			}
+			return parser.Event{}, err
+		}
+	}
+	return s.src.next()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"biathlon_system/internal/race"
+)
+
+func TestResumeMatchesUninterruptedRun(t *testing.T) {
+	// В testdata/checkpoint попадание участника 3 вне огневого рубежа в
+	// строке 19 — в мягком режиме строка пропускается
+	configs := testConfigs(t, "")
+	fixture := filepath.Join("testdata", "checkpoint")
+	opts := options{eventsPaths: []string{fixture}, format: "text", mode: modeLenient, checkpointEvery: 5}
+
+	races := race.NewSet(configs)
+	if err := processFiles(context.Background(), opts, races, newProcessStats(opts.mode, configs)); err != nil {
+		t.Fatal(err)
+	}
+	want := reportText(t, races.Race(""))
+
+	// Обработка, прерванная на 30-й строке
+	data, err := os.ReadFile(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	partial := filepath.Join(dir, "partial")
+	lines := strings.SplitAfter(string(data), "\n")
+	if err := os.WriteFile(partial, []byte(strings.Join(lines[:30], "")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	opts.eventsPaths = []string{partial}
+	opts.checkpointPath = filepath.Join(dir, "state.json")
+	if err := processFiles(context.Background(), opts, race.NewSet(configs), newProcessStats(opts.mode, configs)); err != nil {
+		t.Fatal(err)
+	}
+	cp, err := race.LoadCheckpoint(opts.checkpointPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Processed != 30 {
+		t.Errorf("в контрольной точке %d событий, прочитано 30", cp.Processed)
+	}
+
+	opts.eventsPaths = []string{fixture}
+	opts.resume = true
+	resumed := race.NewSet(configs)
+	if err := processFiles(context.Background(), opts, resumed, newProcessStats(opts.mode, configs)); err != nil {
+		t.Fatal(err)
+	}
+	if got := reportText(t, resumed.Race("")); got != want {
+		t.Errorf("таблица после возобновления:\n%s\nбез прерывания:\n%s", got, want)
+	}
+}
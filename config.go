@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"biathlon_system/internal/race"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// configExts — поддерживаемые форматы файла конфигурации в порядке поиска;
+// формат определяется по расширению файла
+var configExts = []string{"json", "yaml", "yml", "toml"}
+
+var errConfigNotFound = errors.New("файл конфигурации не найден")
+
+// configEnv — переменные окружения, переопределяющие параметры файла
+// конфигурации. Порядок приоритета: параметры запуска, переменные
+// окружения, файл конфигурации, значения по умолчанию.
+var configEnv = map[string]string{
+	"raceType":            "BIATHLON_RACE_TYPE",
+	"laps":                "BIATHLON_LAPS",
+	"lapLen":              "BIATHLON_LAP_LEN",
+	"penaltyLen":          "BIATHLON_PENALTY_LEN",
+	"firingLines":         "BIATHLON_FIRING_LINES",
+	"firingSchedule":      "BIATHLON_FIRING_SCHEDULE",
+	"targets":             "BIATHLON_TARGETS",
+	"targetsPerLine":      "BIATHLON_TARGETS_PER_LINE",
+	"start":               "BIATHLON_START",
+	"startDelta":          "BIATHLON_START_DELTA",
+	"checkPenalties":      "BIATHLON_CHECK_PENALTIES",
+	"penaltyLoopsPerMiss": "BIATHLON_PENALTY_LOOPS_PER_MISS",
+	"totalTimeBase":       "BIATHLON_TOTAL_TIME_BASE",
+	"timeLimit":           "BIATHLON_TIME_LIMIT",
+	"missPenalty":         "BIATHLON_MISS_PENALTY",
+	"gapPrecision":        "BIATHLON_GAP_PRECISION",
+	"falseStartTolerance": "BIATHLON_FALSE_START_TOLERANCE",
+	"qualifiers":          "BIATHLON_QUALIFIERS",
+	"finalStart":          "BIATHLON_FINAL_START",
+	"competitorIdPattern": "BIATHLON_COMPETITOR_ID_PATTERN",
+	"teamsFile":           "BIATHLON_TEAMS_FILE",
+	"teamScorers":         "BIATHLON_TEAM_SCORERS",
+	"points":              "BIATHLON_POINTS",
+}
+
+// bindEnv подключает к v переменные окружения configEnv
+func bindEnv(v *viper.Viper) {
+	v.SetEnvPrefix("biathlon")
+	v.AutomaticEnv()
+	for key, env := range configEnv {
+		// BindEnv с явным именем переменной ошибку не возвращает
+		_ = v.BindEnv(key, env)
+	}
+}
+
+// initConfig читает файл конфигурации path, а если он не задан — первый
+// найденный в configSearchDirs
+func initConfig(path string) error {
+	if path == "" {
+		found, err := findConfig(configSearchDirs()...)
+		if err != nil {
+			return err
+		}
+		path = found
+	}
+	viper.SetConfigFile(path)
+	bindEnv(viper.GetViper())
+
+	if err := viper.ReadInConfig(); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	logrus.Infof("Конфигурация: %s", path)
+	return nil
+}
+
+// watchConfig перечитывает файл конфигурации v при каждом его изменении и
+// передаёт новые параметры гонок в apply. Ошибочная конфигурация не
+// применяется: гонка продолжается с прежними параметрами.
+func watchConfig(v *viper.Viper, apply func(race.Configs)) {
+	v.OnConfigChange(func(in fsnotify.Event) {
+		configs, err := race.LoadConfigs(v)
+		if err != nil {
+			logrus.Warnf("Конфигурация %s не применена: %v", in.Name, err)
+			return
+		}
+		logrus.Infof("Конфигурация перечитана: %s", in.Name)
+		apply(configs)
+	})
+	v.WatchConfig()
+}
+
+// configSearchDirs — каталоги поиска конфигурации без -config: рабочий
+// каталог, configs и каталог настроек пользователя
+func configSearchDirs() []string {
+	dirs := []string{".", "configs"}
+	if dir, err := os.UserConfigDir(); err == nil {
+		dirs = append(dirs, filepath.Join(dir, "biathlon_system"))
+	}
+	return dirs
+}
+
+// readConfigDir читает configs/config.<ext> относительно каталога dir
+func readConfigDir(dir string) (*viper.Viper, error) {
+	v := viper.New()
+	path, err := findConfig(filepath.Join(dir, "configs"))
+	if err != nil {
+		return v, err
+	}
+	v.SetConfigFile(path)
+	bindEnv(v)
+
+	return v, v.ReadInConfig()
+}
+
+// findConfig ищет в каталогах dirs файл config в одном из форматов configExts
+func findConfig(dirs ...string) (string, error) {
+	var tried []string
+	for _, dir := range dirs {
+		for _, ext := range configExts {
+			path := filepath.Join(dir, "config."+ext)
+			if _, err := os.Stat(path); err == nil {
+				return path, nil
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return "", err
+			}
+			tried = append(tried, path)
+		}
+	}
+	return "", fmt.Errorf("%w, проверены: %s", errConfigNotFound, strings.Join(tried, ", "))
+}
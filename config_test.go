@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"biathlon_system/internal/race"
+	"biathlon_system/parser"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// envConfigs загружает параметры testConfigJSON с переопределениями из
+// переменных окружения
+func envConfigs(t *testing.T) (race.Configs, error) {
+	t.Helper()
+
+	v := viper.New()
+	v.SetConfigType("json")
+	bindEnv(v)
+	if err := v.ReadConfig(strings.NewReader(testConfigJSON)); err != nil {
+		t.Fatal(err)
+	}
+	return race.LoadConfigs(v)
+}
+
+func TestConfigEnvOverrides(t *testing.T) {
+	t.Setenv("BIATHLON_LAPS", "3")
+	t.Setenv("BIATHLON_LAP_LEN", "4000")
+	t.Setenv("BIATHLON_START", "11:30:00.000")
+	t.Setenv("BIATHLON_START_DELTA", "45s")
+
+	configs, err := envConfigs(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := configs.Base
+	if cfg.Laps != 3 || cfg.LapLen != 4000 || cfg.StartDelta != 45*time.Second {
+		t.Errorf("laps %d, lapLen %d, startDelta %s, ожидалось 3, 4000 и 45s", cfg.Laps, cfg.LapLen, cfg.StartDelta)
+	}
+	if got := cfg.Start.Format(parser.TimeFormat); got != "11:30:00.000" {
+		t.Errorf("start %s, ожидалось 11:30:00.000", got)
+	}
+	// Параметры без переменных окружения берутся из файла
+	if cfg.PenaltyLen != 150 || cfg.FiringLines != 2 {
+		t.Errorf("penaltyLen %d, firingLines %d, ожидалось 150 и 2", cfg.PenaltyLen, cfg.FiringLines)
+	}
+}
+
+func TestConfigEnvInvalid(t *testing.T) {
+	t.Setenv("BIATHLON_LAPS", "three")
+
+	_, err := envConfigs(t)
+	if err == nil || !strings.Contains(err.Error(), "laps") || !strings.Contains(err.Error(), "three") {
+		t.Errorf("ошибка %v, ожидалась ошибка параметра laps со значением three", err)
+	}
+}
+
+func TestWatchConfigReload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(testConfigJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+	configs, err := race.LoadConfigs(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var logs bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&logs)
+	races := race.NewSet(configs.WithOptions(race.Options{Strict: true, Logger: logger}))
+
+	// Гонка уже идёт: обработаны первые 30 событий events
+	file, err := os.Open("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for n := 0; n < 30 && scanner.Scan(); n++ {
+		ev, err := parser.ParseEvent(scanner.Text())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := races.Apply(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reloaded := make(chan struct{}, 1)
+	watchConfig(v, func(configs race.Configs) {
+		races.Reconfigure(configs)
+		select {
+		case reloaded <- struct{}{}:
+		default:
+		}
+	})
+
+	// Файл заменяется целиком, чтобы не перечитать его наполовину записанным
+	updated := strings.Replace(strings.Replace(testConfigJSON, `"lapLen": 3500`, `"lapLen": 4000`, 1), `"laps": 2`, `"laps": 3`, 1)
+	tmp := filepath.Join(dir, "config.json.tmp")
+	if err := os.WriteFile(tmp, []byte(updated), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-reloaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("конфигурация не перечитана за 5 секунд")
+	}
+
+	cfg := races.Race("").Config()
+	if cfg.LapLen != 4000 || cfg.Laps != 2 {
+		t.Errorf("lapLen %d, laps %d, ожидалось 4000 и прежние 2", cfg.LapLen, cfg.Laps)
+	}
+	for _, want := range []string{"lapLen изменён с 3500 на 4000", "изменение laps с 2 на 3 отклонено"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("в журнале нет %q:\n%s", want, logs.String())
+		}
+	}
+}
+
+func TestConfigFormats(t *testing.T) {
+	// Один и тот же набор параметров в JSON, YAML и TOML, в YAML и TOML время
+	// и интервалы записаны без кавычек
+	load := func(ext string) race.Config {
+		t.Helper()
+		v := viper.New()
+		v.SetConfigFile(filepath.Join("testdata", "configformats", "config."+ext))
+		if err := v.ReadInConfig(); err != nil {
+			t.Fatalf("%s: %v", ext, err)
+		}
+		configs, err := race.LoadConfigs(v)
+		if err != nil {
+			t.Fatalf("%s: %v", ext, err)
+		}
+		return configs.Base
+	}
+
+	want := load("json")
+	if want.StartDelta != 30*time.Second || want.TimeLimit != 70*time.Minute || want.Start.Format(parser.TimeFormat) != "10:00:00.000" {
+		t.Fatalf("json: startDelta %s, timeLimit %s, start %s", want.StartDelta, want.TimeLimit, want.Start.Format(parser.TimeFormat))
+	}
+	for _, ext := range []string{"yaml", "toml"} {
+		if got := load(ext); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s:\n%+v\njson:\n%+v", ext, got, want)
+		}
+	}
+}
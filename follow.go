@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"time"
+
+	"biathlon_system/internal/race"
+	"biathlon_system/parser"
+)
+
+const followPollInterval = 200 * time.Millisecond
+
+// timeLimitInterval — период проверки лимита времени в режиме -follow
+const timeLimitInterval = time.Second
+
+// followReader продолжает чтение файла после EOF, как tail -f, пока не закрыт stop.
+// Наружу отдаются только завершённые строки: хвост без перевода строки
+// придерживается до появления '\n' и отбрасывается при остановке.
+type followReader struct {
+	r       io.Reader
+	clock   race.Clock
+	stop    <-chan struct{}
+	buf     []byte
+	pending []byte
+	ready   []byte
+}
+
+func newFollowReader(r io.Reader, clock race.Clock, stop <-chan struct{}) *followReader {
+	return &followReader{r: r, clock: clock, stop: stop, buf: make([]byte, 32*1024)}
+}
+
+func (f *followReader) Read(p []byte) (int, error) {
+	for len(f.ready) == 0 {
+		n, err := f.r.Read(f.buf)
+		f.pending = append(f.pending, f.buf[:n]...)
+		if i := bytes.LastIndexByte(f.pending, '\n'); i >= 0 {
+			f.ready = append(f.ready, f.pending[:i+1]...)
+			f.pending = append(f.pending[:0], f.pending[i+1:]...)
+			break
+		}
+
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if n == 0 {
+			select {
+			case <-f.stop:
+				return 0, io.EOF
+			case <-f.clock.After(followPollInterval):
+			}
+		}
+	}
+
+	n := copy(p, f.ready)
+	f.ready = f.ready[n:]
+	return n, nil
+}
+
+// untilEvent завершает источник на служебном событии с заданным ID.
+// Само служебное событие не обрабатывается.
+type untilEvent struct {
+	src eventSource
+	id  int
+}
+
+func (u *untilEvent) next() (parser.Event, error) {
+	ev, err := u.src.next()
+	if err == nil && ev.ID == u.id {
+		return parser.Event{}, io.EOF
+	}
+	return ev, err
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strconv"
+
+	"biathlon_system/api"
+	"biathlon_system/internal/race"
+	"biathlon_system/parser"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+type grpcServer struct {
+	api.UnimplementedBiathlonServer
+	races *race.Set
+}
+
+// serveGRPC обслуживает gRPC-интерфейс до отмены ctx; события с
+// идентификатором гонки идут в свою гонку races
+func serveGRPC(ctx context.Context, addr string, races *race.Set) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	server := newGRPCServer(races)
+	go func() {
+		<-ctx.Done()
+		server.GracefulStop()
+	}()
+
+	logrus.Infof("gRPC-сервер слушает %s", listener.Addr())
+	return server.Serve(listener)
+}
+
+// newGRPCServer создаёт gRPC-сервер, принимающий события гонок races
+func newGRPCServer(races *race.Set) *grpc.Server {
+	server := grpc.NewServer()
+	api.RegisterBiathlonServer(server, &grpcServer{races: races})
+	return server
+}
+
+func (s *grpcServer) SubmitEvent(_ context.Context, req *api.Event) (*api.SubmitEventResponse, error) {
+	timeStr := "[" + req.GetTime() + "]"
+	raw := timeStr + " " + strconv.Itoa(int(req.GetId())) + " " + req.GetCompetitor()
+	if req.GetExtra() != "" {
+		raw += " " + req.GetExtra()
+	}
+	if req.GetRace() != "" {
+		raw = req.GetRace() + " " + raw
+	}
+
+	ev, err := parser.ParseFields(timeStr, strconv.Itoa(int(req.GetId())), req.GetCompetitor(), req.GetExtra(), raw)
+	if err == nil {
+		ev.Race = req.GetRace()
+		err = s.races.Apply(ev)
+	}
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return &api.SubmitEventResponse{}, nil
+}
+
+func (s *grpcServer) GetStandings(_ context.Context, req *api.GetStandingsRequest) (*api.Standings, error) {
+	rows := s.races.Race(req.GetRace()).Results()
+
+	resp := &api.Standings{Standings: make([]*api.Standing, 0, len(rows))}
+	for _, row := range rows {
+		standing := &api.Standing{
+			Competitor: row.ID,
+			Status:     api.Status_FINISHED,
+			Laps:       lapsProto(row.Laps),
+			Penalties:  lapsProto(row.Penalties),
+			Hits:       int32(row.Hits),
+			Shots:      int32(row.Shots),
+		}
+		switch {
+		case row.NotStarted:
+			standing.Status = api.Status_NOT_STARTED
+		case row.NotFinished:
+			standing.Status = api.Status_NOT_FINISHED
+		case !row.Finished:
+			standing.Status = api.Status_RUNNING
+		case row.InvalidTotal:
+			// Время гонки отрицательно и не публикуется
+		default:
+			standing.TotalTime = durationpb.New(row.TotalTime)
+		}
+		resp.Standings = append(resp.Standings, standing)
+	}
+
+	return resp, nil
+}
+
+func lapsProto(laps []race.LapResult) []*api.Lap {
+	out := make([]*api.Lap, 0, len(laps))
+	for _, lap := range laps {
+		if lap.Incomplete || lap.Invalid {
+			out = append(out, &api.Lap{Incomplete: true})
+			continue
+		}
+		out = append(out, &api.Lap{Time: durationpb.New(lap.Time), Speed: lap.Speed})
+	}
+	return out
+}
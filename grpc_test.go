@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"biathlon_system/api"
+	"biathlon_system/internal/race"
+	"biathlon_system/parser"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// grpcClient запускает gRPC-сервер гонок races в памяти и возвращает клиента к нему
+func grpcClient(t *testing.T, races *race.Set) api.BiathlonClient {
+	t.Helper()
+
+	listener := bufconn.Listen(1 << 20)
+	server := newGRPCServer(races)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return api.NewBiathlonClient(conn)
+}
+
+func TestGRPCSubmitEventsAndStandings(t *testing.T) {
+	configs := testConfigs(t, "")
+	client := grpcClient(t, race.NewSet(configs))
+	want := race.New(configs.Base)
+
+	file, err := os.Open("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	ctx := context.Background()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		ev, err := parser.ParseEvent(scanner.Text())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := want.Apply(ev); err != nil {
+			t.Fatal(err)
+		}
+		_, err = client.SubmitEvent(ctx, &api.Event{
+			Time:       strings.Trim(ev.TimeStr, "[]"),
+			Id:         int32(ev.ID),
+			Competitor: ev.CompetitorID,
+			Extra:      ev.Extra,
+		})
+		if err != nil {
+			t.Fatalf("SubmitEvent(%s): %v", ev.Raw, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.GetStandings(ctx, &api.GetStandingsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := want.Results()
+	if len(resp.GetStandings()) != len(rows) {
+		t.Fatalf("строк таблицы %d, ожидалось %d", len(resp.GetStandings()), len(rows))
+	}
+	for i, standing := range resp.GetStandings() {
+		row := rows[i]
+		if standing.GetCompetitor() != row.ID {
+			t.Errorf("строка %d: участник %s, ожидался %s", i+1, standing.GetCompetitor(), row.ID)
+		}
+		if int(standing.GetHits()) != row.Hits || int(standing.GetShots()) != row.Shots {
+			t.Errorf("участник %s: попаданий %d/%d, ожидалось %d/%d",
+				row.ID, standing.GetHits(), standing.GetShots(), row.Hits, row.Shots)
+		}
+		if len(standing.GetLaps()) != len(row.Laps) {
+			t.Errorf("участник %s: кругов %d, ожидалось %d", row.ID, len(standing.GetLaps()), len(row.Laps))
+		}
+		if row.Finished && !row.NotFinished && standing.GetTotalTime().AsDuration() != row.TotalTime {
+			t.Errorf("участник %s: время %s, ожидалось %s", row.ID, standing.GetTotalTime().AsDuration(), row.TotalTime)
+		}
+	}
+}
+
+func TestGRPCSubmitEventInvalid(t *testing.T) {
+	client := grpcClient(t, race.NewSet(testConfigs(t, "")))
+
+	_, err := client.SubmitEvent(context.Background(), &api.Event{Time: "09:05", Id: 1, Competitor: "1"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("код ошибки %s, ожидался InvalidArgument: %v", status.Code(err), err)
+	}
+}
+
+func TestGRPCRaceToken(t *testing.T) {
+	client := grpcClient(t, race.NewSet(testConfigs(t, "")))
+	ctx := context.Background()
+
+	if _, err := client.SubmitEvent(ctx, &api.Event{Time: "09:05:00.000", Id: 1, Competitor: "7", Race: "R2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for raceID, want := range map[string]int{"R2": 1, "": 0} {
+		resp, err := client.GetStandings(ctx, &api.GetStandingsRequest{Race: raceID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(resp.GetStandings()); got != want {
+			t.Errorf("гонка %q: строк таблицы %d, ожидалось %d", raceID, got, want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"biathlon_system/internal/race"
+	"biathlon_system/internal/report"
+	"biathlon_system/parser"
+
+	"github.com/sirupsen/logrus"
+)
+
+const maxEventBodySize = 64 * 1024
+
+// serveHTTP принимает события через POST /events и отдаёт текущую таблицу
+// результатов через GET /results до отмены ctx; ?race=<id> выбирает гонку
+func serveHTTP(ctx context.Context, addr string, races *race.Set, results report.Writer) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           newHTTPHandler(races, results, newRaceHubs()),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		logrus.Infof("HTTP-сервер слушает %s", addr)
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+func newHTTPHandler(races *race.Set, results report.Writer, hubs *raceHubs) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "метод не поддерживается", http.StatusMethodNotAllowed)
+			return
+		}
+		handlePostEvent(w, r, races, hubs)
+	})
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		serveWebSocket(w, r, races, hubs)
+	})
+	mux.HandleFunc("/results", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "метод не поддерживается", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		race := races.Race(r.URL.Query().Get("race"))
+		if err := results.Write(w, race.Results(), race.Config()); err != nil {
+			logrus.Errorf("Ошибка формирования результатов: %s", err)
+		}
+	})
+
+	return mux
+}
+
+// handlePostEvent принимает одно событие: строку в текстовом формате
+// или JSON-объект в формате jsonl
+func handlePostEvent(w http.ResponseWriter, r *http.Request, races *race.Set, hubs *raceHubs) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxEventBodySize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := applyEventLine(string(body), races, hubs); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyEventLine разбирает событие в текстовом формате или в формате jsonl,
+// применяет его и рассылает обновлённую таблицу гонки события её зрителям
+func applyEventLine(line string, races *race.Set, hubs *raceHubs) error {
+	line = strings.TrimSpace(line)
+	parse := parser.ParseEvent
+	if strings.HasPrefix(line, "{") {
+		parse = parser.ParseJSON
+	}
+
+	ev, err := parse(line)
+	if err != nil {
+		return err
+	}
+	if err := races.Apply(ev); err != nil {
+		return err
+	}
+
+	snapshot, err := standingsJSON(races.Race(ev.Race))
+	if err != nil {
+		return err
+	}
+	hubs.hub(ev.Race).publish(snapshot)
+
+	return nil
+}
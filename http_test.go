@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"biathlon_system/internal/race"
+	"biathlon_system/internal/report"
+	"biathlon_system/parser"
+)
+
+func TestHTTPRaceToken(t *testing.T) {
+	// События двух гонок одного потока попадают каждое в свою гонку
+	server := httptest.NewServer(newHTTPHandler(race.NewSet(testConfigs(t, "")), report.Text{}, newRaceHubs()))
+	defer server.Close()
+
+	for _, line := range []string{"R1 [09:05:00.000] 1 1", "R2 [09:05:01.000] 1 2"} {
+		resp, err := http.Post(server.URL+"/events", "text/plain", strings.NewReader(line))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("POST /events %q: статус %d", line, resp.StatusCode)
+		}
+	}
+
+	for raceID, want := range map[string]string{"R1": " 1 ", "R2": " 2 "} {
+		resp, err := http.Get(server.URL + "/results?race=" + raceID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body strings.Builder
+		_, err = io.Copy(&body, resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lines := strings.Split(strings.TrimSpace(body.String()), "\n"); len(lines) != 1 || !strings.Contains(lines[0], want) {
+			t.Errorf("GET /results?race=%s:\n%s\nожидалась одна строка участника%s", raceID, body.String(), want)
+		}
+	}
+}
+
+// postEvent отправляет событие в POST /events и возвращает статус ответа
+func postEvent(t *testing.T, url, body string) int {
+	t.Helper()
+
+	resp, err := http.Post(url+"/events", "text/plain", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// jsonEventLine переводит событие в текстовом формате в формат jsonl
+func jsonEventLine(t *testing.T, line string) string {
+	t.Helper()
+
+	ev, err := parser.ParseEvent(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := json.Marshal(map[string]any{
+		"time": strings.Trim(ev.TimeStr, "[]"), "event": ev.ID, "competitor": ev.CompetitorID, "extra": ev.Extra,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestHTTPMiniRace(t *testing.T) {
+	// События гонки из events приходят через POST /events, каждое третье —
+	// в формате JSON; GET /results отдаёт ту же таблицу, что и по файлу
+	configs := testConfigs(t, "")
+	server := httptest.NewServer(newHTTPHandler(race.NewSet(configs), report.Text{}, newRaceHubs()))
+	defer server.Close()
+
+	feed, err := os.ReadFile("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := race.New(configs.Base)
+	for n, line := range strings.Split(strings.TrimSpace(string(feed)), "\n") {
+		ev, err := parser.ParseEvent(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := want.Apply(ev); err != nil {
+			t.Fatal(err)
+		}
+		body := line
+		if n%3 == 2 {
+			body = jsonEventLine(t, line)
+		}
+		if status := postEvent(t, server.URL, body); status != http.StatusNoContent {
+			t.Fatalf("POST /events %s: статус %d", body, status)
+		}
+	}
+
+	resp, err := http.Get(server.URL + "/results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	got, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var table bytes.Buffer
+	if err := (report.Text{}).Write(&table, want.Results(), want.Config()); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != table.String() {
+		t.Errorf("GET /results:\n%s\nожидалось:\n%s", got, table.String())
+	}
+}
+
+func TestHTTPBadRequests(t *testing.T) {
+	server := httptest.NewServer(newHTTPHandler(race.NewSet(testConfigs(t, "")), report.Text{}, newRaceHubs()))
+	defer server.Close()
+
+	for _, body := range []string{"[09:05:00.000] 1", `{"time": "09:05:00.000", "event": 1}`, "garbage"} {
+		if status := postEvent(t, server.URL, body); status != http.StatusBadRequest {
+			t.Errorf("POST /events %q: статус %d, ожидался 400", body, status)
+		}
+	}
+
+	resp, err := http.Get(server.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET /events: статус %d, ожидался 405", resp.StatusCode)
+	}
+}
@@ -0,0 +1,444 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"container/heap"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"biathlon_system/internal/race"
+	"biathlon_system/parser"
+
+	"github.com/sirupsen/logrus"
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+type inputReader struct {
+	io.Reader
+	file io.Closer
+}
+
+func (r *inputReader) Close() error {
+	return r.file.Close()
+}
+
+// gzipInput указывает имя файла в ошибках распаковки, чтобы повреждённый
+// архив не выглядел как ошибка сканера
+type gzipInput struct {
+	*gzip.Reader
+	file io.Closer
+	path string
+}
+
+func (r *gzipInput) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		err = fmt.Errorf("файл %s повреждён: %w", r.path, err)
+	}
+	return n, err
+}
+
+func (r *gzipInput) Close() error {
+	r.Reader.Close()
+	return r.file.Close()
+}
+
+// openInput открывает файл событий; путь "-" означает стандартный ввод.
+// Сжатые gzip файлы распознаются по расширению .gz или по сигнатуре.
+func openInput(path string) (io.ReadCloser, error) {
+	file, err := openRawInput(path)
+	if err != nil {
+		return nil, err
+	}
+
+	buffered := bufio.NewReader(file)
+	magic, _ := buffered.Peek(len(gzipMagic))
+	if !strings.HasSuffix(path, ".gz") && !bytes.Equal(magic, gzipMagic) {
+		return &inputReader{Reader: buffered, file: file}, nil
+	}
+
+	gz, err := gzip.NewReader(buffered)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("файл %s не является корректным gzip-архивом: %w", path, err)
+	}
+
+	return &gzipInput{Reader: gz, file: file, path: path}, nil
+}
+
+func openRawInput(path string) (io.ReadCloser, error) {
+	if path != "-" {
+		return os.Open(path)
+	}
+
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode()&os.ModeCharDevice != 0 {
+		return nil, errors.New("не указан файл событий и на стандартный ввод не поданы данные")
+	}
+
+	return os.Stdin, nil
+}
+
+func openInputs(paths []string) ([]io.ReadCloser, error) {
+	inputs := make([]io.ReadCloser, 0, len(paths))
+	for _, path := range paths {
+		input, err := openInput(path)
+		if err != nil {
+			closeInputs(inputs)
+			return nil, err
+		}
+		inputs = append(inputs, input)
+	}
+
+	return inputs, nil
+}
+
+func closeInputs(inputs []io.ReadCloser) {
+	for _, input := range inputs {
+		input.Close()
+	}
+}
+
+// lineError — ошибка разбора отдельной строки, после которой чтение источника
+// можно продолжить
+type lineError struct {
+	line int
+	err  error
+}
+
+func (e *lineError) Error() string {
+	return fmt.Sprintf("строка %d: %s", e.line, e.err)
+}
+
+func (e *lineError) Unwrap() error {
+	return e.err
+}
+
+type eventSource interface {
+	// next возвращает очередное событие или io.EOF, когда источник исчерпан
+	next() (parser.Event, error)
+}
+
+// utf8BOM встречается в начале файлов, сохранённых в Windows
+const utf8BOM = "\ufeff"
+
+// maxLineSize — максимальная длина строки событий в байтах (параметр -max-line)
+var maxLineSize = 1024 * 1024
+
+// errLineTooLong означает, что строка не поместилась в буфер чтения
+var errLineTooLong = errors.New("строка длиннее допустимой")
+
+// eventReader построчно читает события и разбирает их парсером выбранного формата
+type eventReader struct {
+	scanner  *bufio.Scanner
+	parse    lineParser
+	line     int
+	comments int
+	tooLong  bool
+}
+
+func newEventReader(r io.Reader, parse lineParser) *eventReader {
+	reader := &eventReader{scanner: bufio.NewScanner(r), parse: parse}
+	reader.scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize+1)
+	reader.scanner.Split(reader.splitLines)
+	return reader
+}
+
+// splitLines работает как bufio.ScanLines, но слишком длинная строка не
+// прерывает чтение: она отбрасывается до конца и отдаётся пустым токеном
+// с флагом tooLong, чтобы её можно было пропустить в мягком режиме.
+func (r *eventReader) splitLines(data []byte, atEOF bool) (int, []byte, error) {
+	advance, token, err := bufio.ScanLines(data, atEOF)
+	switch {
+	case token == nil && len(data) >= maxLineSize:
+		// Конца строки в буфере нет: отбрасываем прочитанное и ищем его дальше
+		r.tooLong = true
+		return len(data), nil, nil
+	case token == nil && atEOF && r.tooLong:
+		return 0, []byte{}, bufio.ErrFinalToken
+	case token != nil && (r.tooLong || len(token) > maxLineSize):
+		r.tooLong = true
+		return advance, []byte{}, err
+	}
+	return advance, token, err
+}
+
+func (r *eventReader) next() (parser.Event, error) {
+	for {
+		// После ошибки чтения сканер отдаёт недочитанный хвост строки — его пропускаем
+		if !r.scanner.Scan() || r.scanner.Err() != nil {
+			if err := r.scanner.Err(); err != nil {
+				return parser.Event{}, fmt.Errorf("Ошибка чтения файла: %w", err)
+			}
+			if r.comments > 0 {
+				logrus.Infof("Пропущено строк комментариев: %d", r.comments)
+			}
+			return parser.Event{}, io.EOF
+		}
+		r.line++
+
+		if r.tooLong {
+			r.tooLong = false
+			return parser.Event{}, &lineError{line: r.line, err: fmt.Errorf("%w: более %d байт", errLineTooLong, maxLineSize)}
+		}
+
+		line := strings.TrimSuffix(r.scanner.Text(), "\r")
+		if r.line == 1 {
+			line = strings.TrimPrefix(line, utf8BOM)
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			r.comments++
+			continue
+		}
+
+		ev, err := r.parse(line)
+		if err != nil {
+			return parser.Event{}, &lineError{line: r.line, err: err}
+		}
+		ev.Line = r.line
+
+		return ev, nil
+	}
+}
+
+type sliceSource struct {
+	events []parser.Event
+}
+
+func (s *sliceSource) next() (parser.Event, error) {
+	if len(s.events) == 0 {
+		return parser.Event{}, io.EOF
+	}
+	ev := s.events[0]
+	s.events = s.events[1:]
+	return ev, nil
+}
+
+// mergeEvents сливает события из нескольких файлов в хронологическом порядке.
+// Время без даты сравнивается на дате старта гонки, события с одинаковым
+// временем идут в порядке перечисления файлов.
+func mergeEvents(paths []string, inputs []io.ReadCloser, newSource func(io.Reader) eventSource, stats *processStats) (eventSource, error) {
+	var events []parser.Event
+	for i, input := range inputs {
+		reader := newSource(input)
+		for {
+			ev, err := reader.next()
+			if err == io.EOF {
+				break
+			}
+			var lineErr *lineError
+			if errors.As(err, &lineErr) && stats.skip(fmt.Errorf("%s: %w", paths[i], err)) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", paths[i], err)
+			}
+			events = append(events, ev)
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return stats.configs.EventTime(events[i]).Before(stats.configs.EventTime(events[j]))
+	})
+
+	return &sliceSource{events: events}, nil
+}
+
+// reorderSource выдаёт события в хронологическом порядке, исправляя
+// расхождение часов разных источников. Прочитанные события копятся в куче
+// и выдаются, когда самое новое событие опережает их на window или когда
+// в буфере больше size событий. Буфер хранится в памяти, поэтому size
+// ограничивает потребление памяти, но и смещение, которое можно исправить.
+// Событие старше уже выданных помечается как запоздавшее и выдаётся сразу.
+// Время без даты сравнивается на дате старта гонки из configs.
+type reorderSource struct {
+	src     eventSource
+	size    int           // 0 — без ограничения числа событий
+	window  time.Duration // 0 — выдача только по переполнению буфера
+	configs race.Configs
+
+	buf      eventHeap
+	seq      int
+	newest   time.Time
+	released time.Time
+	started  bool
+	eof      bool
+}
+
+func newReorderSource(src eventSource, size int, window time.Duration, configs race.Configs) *reorderSource {
+	return &reorderSource{src: src, size: size, window: window, configs: configs}
+}
+
+func (r *reorderSource) next() (parser.Event, error) {
+	for !r.ready() {
+		ev, err := r.src.next()
+		if err == io.EOF {
+			r.eof = true
+			break
+		}
+		if err != nil {
+			return parser.Event{}, err
+		}
+
+		at := r.configs.EventTime(ev)
+		if r.started && at.Before(r.released) {
+			logrus.Warnf("Строка %d: событие %s опоздало больше чем на окно сортировки, событие: %s", ev.Line, ev.TimeStr, ev.Raw)
+		}
+		if r.buf.Len() == 0 || at.After(r.newest) {
+			r.newest = at
+		}
+		heap.Push(&r.buf, sequencedEvent{Event: ev, at: at, seq: r.seq})
+		r.seq++
+	}
+
+	if r.buf.Len() == 0 {
+		return parser.Event{}, io.EOF
+	}
+	next := heap.Pop(&r.buf).(sequencedEvent)
+	if !r.started || next.at.After(r.released) {
+		r.started, r.released = true, next.at
+	}
+	return next.Event, nil
+}
+
+// ready сообщает, можно ли выдать самое раннее событие буфера
+func (r *reorderSource) ready() bool {
+	switch {
+	case r.buf.Len() == 0:
+		return false
+	case r.eof:
+		return true
+	case r.size > 0 && r.buf.Len() > r.size:
+		return true
+	case r.window > 0:
+		// Событие из прошлого выдаётся сразу: ждать для него нечего
+		return r.buf[0].at.Before(r.released) || !r.buf[0].at.After(r.newest.Add(-r.window))
+	}
+	return false
+}
+
+// sequencedEvent сохраняет порядок чтения событий с одинаковым временем;
+// at — время события на дате старта гонки
+type sequencedEvent struct {
+	parser.Event
+	at  time.Time
+	seq int
+}
+
+// eventHeap — минимальная куча событий по времени для container/heap
+type eventHeap []sequencedEvent
+
+func (h eventHeap) Len() int { return len(h) }
+
+func (h eventHeap) Less(i, j int) bool {
+	if h[i].at.Equal(h[j].at) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].at.Before(h[j].at)
+}
+
+func (h eventHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *eventHeap) Push(x any) { *h = append(*h, x.(sequencedEvent)) }
+
+func (h *eventHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+type lineParser func(line string) (parser.Event, error)
+
+// eventFormats — поддерживаемые форматы входных событий
+var eventFormats = map[string]func(r io.Reader) eventSource{
+	"text": func(r io.Reader) eventSource {
+		return newEventReader(r, parser.ParseEvent)
+	},
+	"jsonl": func(r io.Reader) eventSource {
+		return newEventReader(r, parser.ParseJSON)
+	},
+	"csv": newCSVReader,
+}
+
+// csvReader читает события из CSV с колонками time,eventID,competitorID,extraParams.
+// Строка заголовка, если она есть, пропускается.
+type csvReader struct {
+	reader  *csv.Reader
+	started bool
+}
+
+func newCSVReader(r io.Reader) eventSource {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	return &csvReader{reader: reader}
+}
+
+func (r *csvReader) next() (parser.Event, error) {
+	for {
+		record, err := r.reader.Read()
+		if err == io.EOF {
+			return parser.Event{}, io.EOF
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return parser.Event{}, &lineError{line: parseErr.Line, err: parseErr.Err}
+		}
+		if err != nil {
+			return parser.Event{}, fmt.Errorf("Ошибка чтения CSV: %w", err)
+		}
+		line, _ := r.reader.FieldPos(0)
+
+		if !r.started {
+			r.started = true
+			record[0] = strings.TrimPrefix(record[0], utf8BOM)
+			if isCSVHeader(record) {
+				continue
+			}
+		}
+
+		if len(record) < 3 {
+			return parser.Event{}, &lineError{line: line, err: fmt.Errorf("ожидалось не менее 3 колонок, получено %d", len(record))}
+		}
+
+		timeStr := record[0]
+		if !strings.HasPrefix(timeStr, "[") {
+			timeStr = "[" + timeStr + "]"
+		}
+		// Незакавыченные запятые в extraParams считаем частью значения
+		extra := strings.Join(record[3:], ",")
+
+		ev, err := parser.ParseFields(timeStr, record[1], record[2], extra, strings.Join(record, ","))
+		if err != nil {
+			return parser.Event{}, &lineError{line: line, err: err}
+		}
+		ev.Line = line
+
+		return ev, nil
+	}
+}
+
+func isCSVHeader(record []string) bool {
+	if len(record) < 2 {
+		return false
+	}
+	_, err := strconv.Atoi(strings.TrimSpace(record[1]))
+	return err != nil
+}
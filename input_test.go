@@ -0,0 +1,505 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"biathlon_system/internal/race"
+	"biathlon_system/internal/report"
+	"biathlon_system/parser"
+
+	"github.com/sirupsen/logrus"
+)
+
+// reportText возвращает итоговый отчёт гонки в текстовом формате
+func reportText(t *testing.T, r *race.Race) string {
+	t.Helper()
+
+	r.Finalize()
+	var buf bytes.Buffer
+	if err := (report.Text{}).Write(&buf, r.Results(), r.Config()); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+// processPaths обрабатывает файлы событий так же, как processFiles, и
+// возвращает текстовый отчёт гонки по умолчанию
+func processPaths(t *testing.T, configs race.Configs, paths ...string) string {
+	t.Helper()
+
+	opts := options{eventsPaths: paths, format: "text", mode: modeStrict, checkpointEvery: 1000}
+	races := race.NewSet(configs)
+	if err := processFiles(context.Background(), opts, races, newProcessStats(opts.mode, configs)); err != nil {
+		t.Fatalf("processFiles(%v): %v", paths, err)
+	}
+	return reportText(t, races.Race(""))
+}
+
+func TestMergeEventsSplitFiles(t *testing.T) {
+	// Лента, разделённая хронометристом на старт и два огневых рубежа, даёт
+	// ту же таблицу, что и единый файл
+	configs := testConfigs(t, "")
+	want := processPaths(t, configs, "events")
+	got := processPaths(t, configs,
+		filepath.Join("testdata", "merge", "events_start"),
+		filepath.Join("testdata", "merge", "events_range1"),
+		filepath.Join("testdata", "merge", "events_range2"))
+	if got != want {
+		t.Errorf("таблица по трём файлам:\n%s\nпо единому файлу:\n%s", got, want)
+	}
+}
+
+func TestMergeEventsMixedDates(t *testing.T) {
+	configs := testConfigs(t, `{"start": "2024-03-10 10:00:00.000"}`)
+	inputs := []io.ReadCloser{
+		io.NopCloser(strings.NewReader("[2024-03-10 09:00:00.000] 1 1\n[2024-03-10 09:20:00.000] 1 3\n")),
+		io.NopCloser(strings.NewReader("[09:10:00.000] 1 2\n")),
+	}
+	src, err := mergeEvents([]string{"dated", "undated"}, inputs, eventFormats["text"], newProcessStats(modeStrict, configs))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var order []string
+	for {
+		ev, err := src.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		order = append(order, ev.CompetitorID)
+	}
+	if got := strings.Join(order, " "); got != "1 2 3" {
+		t.Errorf("порядок участников %s, ожидался 1 2 3", got)
+	}
+}
+
+func TestReorderWindowShuffledFile(t *testing.T) {
+	// В testdata/shuffled строки events перемешаны в пределах 2.5 секунды
+	configs := testConfigs(t, "")
+	want := processPaths(t, configs, "events")
+
+	opts := options{eventsPaths: []string{filepath.Join("testdata", "shuffled")}, format: "text", mode: modeStrict,
+		checkpointEvery: 1000, reorderWindow: 3 * time.Second, configs: configs}
+	races := race.NewSet(configs)
+	if err := processFiles(context.Background(), opts, races, newProcessStats(opts.mode, configs)); err != nil {
+		t.Fatalf("processFiles: %v", err)
+	}
+	if got := reportText(t, races.Race("")); got != want {
+		t.Errorf("таблица по перемешанной ленте:\n%s\nпо упорядоченной:\n%s", got, want)
+	}
+}
+
+func TestReorderWindowMixedDates(t *testing.T) {
+	configs := testConfigs(t, `{"start": "2024-03-10 10:00:00.000"}`)
+	lines := `
+[2024-03-10 09:00:02.000] 1 1
+[09:00:00.000] 1 2
+[2024-03-10 09:00:01.000] 1 3
+[09:00:10.000] 1 4
+`
+	src := newReorderSource(textSource(lines), 0, 3*time.Second, configs)
+
+	var order []string
+	for {
+		ev, err := src.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		order = append(order, ev.CompetitorID)
+	}
+	if got := strings.Join(order, " "); got != "2 3 1 4" {
+		t.Errorf("порядок участников %s, ожидался 2 3 1 4", got)
+	}
+}
+
+func TestGzipInput(t *testing.T) {
+	configs := testConfigs(t, "")
+	want := processPaths(t, configs, "events")
+
+	if got := processPaths(t, configs, filepath.Join("testdata", "gzip", "events.gz")); got != want {
+		t.Errorf("таблица по events.gz:\n%s\nпо events:\n%s", got, want)
+	}
+	// Сжатые и несжатые файлы в одном слиянии
+	got := processPaths(t, configs,
+		filepath.Join("testdata", "merge", "events_start"),
+		filepath.Join("testdata", "gzip", "events_range1.gz"),
+		filepath.Join("testdata", "gzip", "events_range2.gz"))
+	if got != want {
+		t.Errorf("таблица по слиянию со сжатыми файлами:\n%s\nпо events:\n%s", got, want)
+	}
+}
+
+func TestGzipInputCorrupted(t *testing.T) {
+	for _, name := range []string{"truncated.gz", "plain.gz"} {
+		path := filepath.Join("testdata", "gzip", name)
+		configs := testConfigs(t, "")
+		opts := options{eventsPaths: []string{path}, format: "text", mode: modeStrict, checkpointEvery: 1000}
+		err := processFiles(context.Background(), opts, race.NewSet(configs), newProcessStats(opts.mode, configs))
+		if err == nil || !strings.Contains(err.Error(), path) {
+			t.Errorf("%s: ошибка %v, ожидалась ошибка с именем файла", name, err)
+		}
+	}
+}
+
+func TestWindowsLineEndings(t *testing.T) {
+	// testdata/crlf/events — events с BOM, окончаниями \r\n, пустыми строками и комментариями
+	configs := testConfigs(t, "")
+	want := processPaths(t, configs, "events")
+	if got := processPaths(t, configs, filepath.Join("testdata", "crlf", "events")); got != want {
+		t.Errorf("таблица по файлу Windows:\n%s\nпо events:\n%s", got, want)
+	}
+
+	r := newEventReader(strings.NewReader(utf8BOM+"# range 2 restarted\r\n\r\n[09:05:00.000] 11 1 Lost in the forest\r\n"), parser.ParseEvent)
+	ev, err := r.next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Extra != "Lost in the forest" || ev.Line != 3 {
+		t.Errorf("строка %d, комментарий %q, ожидалась строка 3 и Lost in the forest", ev.Line, ev.Extra)
+	}
+	if _, err := r.next(); err != io.EOF {
+		t.Errorf("ошибка %v, ожидался конец файла", err)
+	}
+	if r.comments != 1 {
+		t.Errorf("строк комментариев %d, ожидалась 1", r.comments)
+	}
+}
+
+// longLineFeed записывает во временный файл ленту events со строкой
+// комментария длиной size байт после строки 50 и возвращает путь к нему
+func longLineFeed(t *testing.T, size int) string {
+	t.Helper()
+
+	data, err := os.ReadFile("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	feed := strings.Join(lines[:50], "") + "# " + strings.Repeat("[10:00:00.000] 1 1 ", size/19) + "\n" + strings.Join(lines[50:], "")
+
+	path := filepath.Join(t.TempDir(), "events")
+	if err := os.WriteFile(path, []byte(feed), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLongLine(t *testing.T) {
+	path := longLineFeed(t, 3<<20)
+	want := runTable(t, testOptions(t, "events"))
+
+	err := run(context.Background(), testOptions(t, path))
+	if exitCode(err) != exitInput || !errors.Is(err, errLineTooLong) || !strings.Contains(err.Error(), "строка 51") {
+		t.Errorf("strict: ошибка %v с кодом %d, ожидалась ошибка длины строки 51", err, exitCode(err))
+	}
+
+	opts := testOptions(t, path)
+	opts.mode = modeLenient
+	opts.raceOptions.Strict = false
+	err = run(context.Background(), opts)
+	if exitCode(err) != exitIncomplete {
+		t.Errorf("lenient: ошибка %v с кодом %d, ожидался код %d", err, exitCode(err), exitIncomplete)
+	}
+	if table, err := os.ReadFile(opts.outPath); err != nil || string(table) != want {
+		t.Errorf("lenient: таблица %q (%v), ожидалась таблица по events", table, err)
+	}
+
+	defer func(size int) { maxLineSize = size }(maxLineSize)
+	maxLineSize = 4 << 20
+	if got := runTable(t, testOptions(t, path)); got != want {
+		t.Errorf("-max-line 4 МБ: таблица\n%s\nпо events:\n%s", got, want)
+	}
+}
+
+func TestMixedPrecision(t *testing.T) {
+	// В testdata/precision/events время без миллисекунд там, где в events .000
+	configs := testConfigs(t, "")
+	if got, want := processPaths(t, configs, filepath.Join("testdata", "precision", "events")), processPaths(t, configs, "events"); got != want {
+		t.Errorf("таблица по времени без миллисекунд:\n%s\nпо events:\n%s", got, want)
+	}
+}
+
+func TestMidnightCrossing(t *testing.T) {
+	// Старт 10 марта в 23:50, второй круг и финиш — после полуночи
+	configs := testConfigs(t, `{"start": "2024-03-10 23:50:00.000"}`)
+	got := processPaths(t, configs, filepath.Join("testdata", "midnight", "events"))
+	want := "{00:25:20.000} 1 [{00:12:39.500, 4.608}, {00:12:40.000, 4.605}] [{00:01:50.000, 1.364}] 9/10\n"
+	if got != want {
+		t.Errorf("таблица гонки через полночь:\n%s\nожидалось:\n%s", got, want)
+	}
+}
+
+func TestDuplicateEvents(t *testing.T) {
+	// В testdata/duplicates/events повторены регистрация, жеребьёвка и два старта
+	configs := testConfigs(t, "")
+	if got, want := processPaths(t, configs, filepath.Join("testdata", "duplicates", "events")), processPaths(t, configs, "events"); got != want {
+		t.Errorf("таблица по ленте с повторами:\n%s\nпо events:\n%s", got, want)
+	}
+}
+
+func TestEventsStdin(t *testing.T) {
+	configs := testConfigs(t, "")
+	want := processPaths(t, configs, "events")
+
+	tests := []struct {
+		stdin string
+		err   string
+	}{
+		{stdin: "events"},
+		{stdin: filepath.Join("testdata", "gzip", "events.gz")},
+		// Терминал вместо данных — понятная ошибка, а не ожидание ввода
+		{stdin: os.DevNull, err: "на стандартный ввод не поданы данные"},
+	}
+	defer func(stdin *os.File) { os.Stdin = stdin }(os.Stdin)
+	for _, tt := range tests {
+		file, err := os.Open(tt.stdin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.Stdin = file
+
+		opts := options{eventsPaths: []string{"-"}, format: "text", mode: modeStrict, checkpointEvery: 1000}
+		races := race.NewSet(configs)
+		err = processFiles(context.Background(), opts, races, newProcessStats(opts.mode, configs))
+		file.Close()
+		switch {
+		case tt.err != "":
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("stdin %s: ошибка %v, ожидалась «%s»", tt.stdin, err, tt.err)
+			}
+		case err != nil:
+			t.Errorf("stdin %s: %v", tt.stdin, err)
+		default:
+			if got := reportText(t, races.Race("")); got != want {
+				t.Errorf("stdin %s: таблица\n%s\nпо events:\n%s", tt.stdin, got, want)
+			}
+		}
+	}
+}
+
+// processFormat обрабатывает ленту text в формате format и возвращает
+// текстовый отчёт гонки по умолчанию
+func processFormat(t *testing.T, configs race.Configs, format, text string) (string, error) {
+	t.Helper()
+
+	opts := options{eventsPaths: []string{writeTemp(t, text)}, format: format, mode: modeStrict, checkpointEvery: 1000}
+	races := race.NewSet(configs)
+	if err := processFiles(context.Background(), opts, races, newProcessStats(opts.mode, configs)); err != nil {
+		return "", err
+	}
+	return reportText(t, races.Race("")), nil
+}
+
+func TestJSONLInput(t *testing.T) {
+	configs := testConfigs(t, "")
+	feed, err := os.ReadFile("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(string(feed)), "\n") {
+		lines = append(lines, jsonEventLine(t, line))
+	}
+	// Неизвестные поля игнорируются
+	lines[0] = strings.TrimSuffix(lines[0], "}") + `,"source":"gateway"}`
+	got, err := processFormat(t, configs, "jsonl", strings.Join(lines, "\n")+"\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := processPaths(t, configs, "events"); got != want {
+		t.Errorf("таблица по jsonl:\n%s\nпо events:\n%s", got, want)
+	}
+
+	tests := []struct {
+		line string
+		kind error
+	}{
+		{`{"time":"09:05:59.867","event":1}`, parser.ErrFields},
+		{`{"event":1,"competitor":"2"}`, parser.ErrFields},
+		{`{"time":"09:05","event":1,"competitor":"2"}`, parser.ErrTime},
+		{`{"time":"09:05:59.867","event":1,"competitor":"2"`, parser.ErrSyntax},
+	}
+	for _, tt := range tests {
+		_, err := processFormat(t, configs, "jsonl", `{"time":"09:05:00.000","event":1,"competitor":"1"}`+"\n"+tt.line+"\n")
+		if !errors.Is(err, tt.kind) || !strings.Contains(err.Error(), "строка 2") {
+			t.Errorf("%s: ошибка %v, ожидалась %v в строке 2", tt.line, err, tt.kind)
+		}
+	}
+}
+
+func TestCSVInput(t *testing.T) {
+	configs := testConfigs(t, "")
+	const body = `09:05:00.000,1,1
+09:10:00.000,2,1,10:00:00.000
+10:00:01.000,4,1
+10:05:00.000,11,1,"Lost in the forest, radio silent"
+`
+	tests := []struct {
+		name, feed, want string
+	}{
+		{"без заголовка", body, "Lost in the forest, radio silent"},
+		{"с заголовком", "time,eventID,competitorID,extraParams\n" + body, "Lost in the forest, radio silent"},
+		{"незакавыченные запятые", strings.Replace(body, `"Lost in the forest, radio silent"`, "Lost, radio silent", 1), "Lost, radio silent"},
+	}
+	for _, tt := range tests {
+		got, err := processFormat(t, configs, "csv", tt.feed)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !strings.Contains(got, "[NotFinished] 1") || !strings.Contains(got, "("+tt.want+")") {
+			t.Errorf("%s: таблица %q, ожидался сход участника 1 с комментарием %q", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := processFormat(t, configs, "csv", "09:05:00.000,1,1\n09:06:00.000,1\n"); err == nil || !strings.Contains(err.Error(), "строка 2: ожидалось не менее 3 колонок") {
+		t.Errorf("строка из двух колонок: ошибка %v", err)
+	}
+}
+
+func TestFollowMode(t *testing.T) {
+	configs := testConfigs(t, "")
+	want := processPaths(t, configs, "events")
+	feed, err := os.ReadFile("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(strings.TrimSpace(string(feed)), "\n")
+	lines[len(lines)-1] += "\n"
+
+	// Отчёт формируется по отмене (SIGINT) или по служебному событию 99
+	for _, stop := range []string{"cancel", "stop-event"} {
+		path := writeTemp(t, strings.Join(lines[:50], ""))
+		opts := options{eventsPaths: []string{path}, format: "text", mode: modeStrict, checkpointEvery: 1000,
+			follow: true, raceOptions: race.Options{Clock: race.SystemClock{}}}
+		if stop == "stop-event" {
+			opts.stopEvent = 99
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		races := race.NewSet(configs)
+		done := make(chan error, 1)
+		go func() { done <- processFiles(ctx, opts, races, newProcessStats(opts.mode, configs)) }()
+
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Последняя строка приходит в два приёма: до перевода строки она не
+		// разбирается
+		last := lines[len(lines)-1]
+		tail := strings.Join(lines[50:len(lines)-1], "") + last[:10]
+		if _, err := file.WriteString(tail); err != nil {
+			t.Fatal(err)
+		}
+		waitApplied(t, races, len(lines)-1)
+		time.Sleep(2 * followPollInterval)
+		if _, applied := races.Race("").Standings(); applied != len(lines)-1 {
+			t.Fatalf("%s: обработано %d событий до конца строки, ожидалось %d", stop, applied, len(lines)-1)
+		}
+		rest := last[10:]
+		if stop == "stop-event" {
+			rest += "[10:59:00.000] 99 1\n"
+		}
+		if _, err := file.WriteString(rest); err != nil {
+			t.Fatal(err)
+		}
+		file.Close()
+		if stop == "cancel" {
+			waitApplied(t, races, len(lines))
+			cancel()
+		}
+
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("%s: %v", stop, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: слежение за файлом не завершилось", stop)
+		}
+		if got := reportText(t, races.Race("")); got != want {
+			t.Errorf("%s: таблица\n%s\nпо events:\n%s", stop, got, want)
+		}
+	}
+}
+
+// waitApplied ждёт, пока гонка по умолчанию обработает n событий
+func waitApplied(t *testing.T, races *race.Set, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, applied := races.Race("").Standings(); applied >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("за 5 секунд не обработано %d событий", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestUDPDatagrams(t *testing.T) {
+	configs := testConfigs(t, "")
+	want := processPaths(t, configs, "events")
+	feed, err := os.ReadFile("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(feed)), "\n")
+	remote := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9999}
+
+	var logs bytes.Buffer
+	logrus.SetOutput(&logs)
+	defer logrus.SetOutput(io.Discard)
+
+	tests := []struct {
+		name string
+		// send возвращает датаграммы для строки ленты
+		send func(line string) []string
+		gap  string
+	}{
+		// Повторно переданные датаграммы отбрасываются
+		{name: "повторы", send: func(line string) []string { return []string{line, line} }},
+		// Потерянный старт участника 1 — предупреждение, приём продолжается
+		{name: "потеря старта", send: func(line string) []string {
+			if strings.HasSuffix(line, "] 4 1") {
+				return nil
+			}
+			return []string{line}
+		}, gap: "Пропуск в данных участника 1: окончание круга без события старта"},
+	}
+	for _, tt := range tests {
+		logs.Reset()
+		races := race.NewSet(configs)
+		seen := newEventDedup()
+		for _, line := range lines {
+			for _, datagram := range tt.send(line) {
+				handleDatagram([]byte(datagram), remote, races, eventFormats["text"], seen)
+			}
+		}
+		if tt.gap == "" {
+			if got := reportText(t, races.Race("")); got != want {
+				t.Errorf("%s: таблица\n%s\nпо events:\n%s", tt.name, got, want)
+			}
+		} else if !strings.Contains(logs.String(), tt.gap) {
+			t.Errorf("%s: нет предупреждения «%s» в журнале:\n%s", tt.name, tt.gap, logs.String())
+		}
+	}
+}
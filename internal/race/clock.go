@@ -0,0 +1,26 @@
+package race
+
+import "time"
+
+// Clock — часы для решений по настоящему времени, а не по времени событий:
+// лимит времени в режиме -follow, ожидание новых строк и т.п.
+type Clock interface {
+	Now() time.Time
+	// After, как time.After, возвращает канал, в который время придёт через d
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock — системные часы
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time                         { return time.Now() }
+func (SystemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// clock возвращает часы гонки: Options.Clock или, если они не заданы,
+// системные часы
+func (c Config) clock() Clock {
+	if c.Clock == nil {
+		return SystemClock{}
+	}
+	return c.Clock
+}
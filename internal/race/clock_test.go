@@ -0,0 +1,101 @@
+package race
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock — часы, время которых передвигает тест
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeTimer
+}
+
+type fakeTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, fakeTimer{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// advance передвигает часы на d и срабатывает наступившие таймеры
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	waiting := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			waiting = append(waiting, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = waiting
+}
+
+// waitTimer ждёт, пока кто-нибудь не станет ждать срабатывания часов
+func (c *fakeClock) waitTimer(t *testing.T) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		c.mu.Lock()
+		n := len(c.waiters)
+		c.mu.Unlock()
+		if n > 0 {
+			return
+		}
+	}
+	t.Fatal("никто не ждёт срабатывания часов")
+}
+
+func TestWatchTimeLimitsFakeClock(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 3, 10, 10, 29, 0, 0, time.UTC)}
+	configs := testConfigs(t, `{"timeLimit": "00:30:00"}`)
+	configs = configs.WithOptions(Options{Logger: NopLogger{}, Clock: clock})
+	races := NewSet(configs)
+	for _, ev := range parseEvents(t, "[09:05:00.000] 1 1\n[09:10:00.000] 2 1 10:00:00.000\n[10:00:01.000] 4 1") {
+		if err := races.Apply(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		races.WatchTimeLimits(time.Minute, done)
+		close(stopped)
+	}()
+	defer func() {
+		close(done)
+		<-stopped
+	}()
+	notFinished := func() bool { return resultOf(t, races.Race("").Results(), "1").NotFinished }
+
+	// В 10:30:00 участник на дистанции ровно 30 минут — лимит не превышен
+	clock.waitTimer(t)
+	clock.advance(time.Minute)
+	clock.waitTimer(t)
+	if notFinished() {
+		t.Fatal("участник снят с дистанции в пределах лимита")
+	}
+
+	clock.advance(time.Minute)
+	clock.waitTimer(t)
+	if !notFinished() {
+		t.Error("участник не снят с дистанции после лимита времени")
+	}
+}
@@ -0,0 +1,124 @@
+package race
+
+import "time"
+
+type competitorStat struct {
+	registered  bool
+	startTime   time.Time
+	actualStart time.Time
+	lapsTime    [][2]time.Time
+	penaltyTime [][2]time.Time
+	bouts       []shootingBout
+	notStarted  bool
+	notFinished bool
+	finishTime  time.Time
+	comment     string
+	// notes — дополнительные данные из обработчиков Options.Handlers
+	notes map[string]string
+	// provisional — результат предварительный: штрафные круги не сходятся
+	// с промахами (-strict-officiating)
+	provisional bool
+	// exchangeFrom — участник предыдущего этапа эстафеты, от которого принята
+	// эстафета событием 12
+	exchangeFrom string
+	// Результаты, рассчитанные computeResults по отметкам времени
+	totalTime      time.Duration
+	lapResults     []LapResult
+	penaltyResults []LapResult
+	// penaltyLoops и penaltyTotal — число штрафных кругов в завершённых
+	// заходах на штрафной круг и суммарное время на нём
+	penaltyLoops int
+	penaltyTotal time.Duration
+	// rangeResults и rangeTotal — время каждого посещения огневого рубежа и
+	// суммарное время завершённых посещений
+	rangeResults []LapResult
+	rangeTotal   time.Duration
+}
+
+// shootingBout — одно посещение огневого рубежа: от события 5 до события 7
+type shootingBout struct {
+	firingRange int   // номер огневого рубежа; 0 — неизвестен
+	lap         int   // круг, на котором участник вышел на рубеж
+	targets     []int // поражённые мишени в порядке попаданий
+	closed      bool
+	penalties   []int // индексы в penaltyTime заходов на штрафной круг после рубежа
+	// interval — [приход, уход] с рубежа; без события 7 уход не заполнен
+	interval [2]time.Time
+}
+
+func (b *shootingBout) hit(target int) bool {
+	for _, t := range b.targets {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
+
+// hits — общее число попаданий по всем огневым рубежам
+func (s *competitorStat) hits() int {
+	hits := 0
+	for _, bout := range s.bouts {
+		hits += len(bout.targets)
+	}
+	return hits
+}
+
+// misses — число промахов на огневом рубеже с targets мишенями
+func (b *shootingBout) misses(targets int) int {
+	return targets - len(b.targets)
+}
+
+// loopsOwed — сколько штрафных кругов положено за промахи на рубеже
+func (b *shootingBout) loopsOwed(cfg Config) int {
+	return b.misses(cfg.Targets) * cfg.PenaltyLoopsPerMiss
+}
+
+// addPenalty добавляет заход на штрафной круг и относит его к последнему
+// посещению огневого рубежа
+func (s *competitorStat) addPenalty(interval [2]time.Time) {
+	if len(s.bouts) > 0 {
+		bout := &s.bouts[len(s.bouts)-1]
+		bout.penalties = append(bout.penalties, len(s.penaltyTime))
+	}
+	s.penaltyTime = append(s.penaltyTime, interval)
+}
+
+// loopsSkied — сколько штрафных кругов участник прошёл после рубежа: каждый
+// заход на штрафной круг (события 8 и 9) — один круг
+func (b *shootingBout) loopsSkied() int {
+	return len(b.penalties)
+}
+
+// loopsOwed — сколько штрафных кругов положено участнику за все промахи
+func (s *competitorStat) loopsOwed(cfg Config) int {
+	owed := 0
+	for i := range s.bouts {
+		owed += s.bouts[i].loopsOwed(cfg)
+	}
+	return owed
+}
+
+// loopsSkied — сколько штрафных кругов участник прошёл, включая незавершённые
+func (s *competitorStat) loopsSkied() int {
+	return len(s.penaltyTime)
+}
+
+// shotOnLap сообщает, выходил ли участник на огневой рубеж на круге lap
+func (s *competitorStat) shotOnLap(lap int) bool {
+	for _, bout := range s.bouts {
+		if bout.lap == lap {
+			return true
+		}
+	}
+	return false
+}
+
+// openBout возвращает текущее посещение огневого рубежа или nil, если
+// участник сейчас не на рубеже
+func (s *competitorStat) openBout() *shootingBout {
+	if len(s.bouts) == 0 || s.bouts[len(s.bouts)-1].closed {
+		return nil
+	}
+	return &s.bouts[len(s.bouts)-1]
+}
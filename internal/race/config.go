@@ -0,0 +1,622 @@
+package race
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"biathlon_system/parser"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// Configs — параметры гонок из файла конфигурации: общие и
+// переопределения для отдельных гонок из секции races
+type Configs struct {
+	Base   Config
+	byRace map[string]Config
+}
+
+// ForRace возвращает параметры гонки с идентификатором raceID
+func (c Configs) ForRace(raceID string) Config {
+	// viper приводит ключи к нижнему регистру
+	if cfg, ok := c.byRace[strings.ToLower(raceID)]; ok {
+		return cfg
+	}
+	return c.Base
+}
+
+// EventTime возвращает время события ev на дате старта его гонки, чтобы
+// события с датой и без неё сравнивались в одной шкале времени
+func (c Configs) EventTime(ev parser.Event) time.Time {
+	return parser.WithDate(ev.Time, c.ForRace(ev.Race).Start)
+}
+
+// WithOptions возвращает те же параметры гонок с параметрами обработки opts
+func (c Configs) WithOptions(opts Options) Configs {
+	out := Configs{Base: c.Base, byRace: make(map[string]Config, len(c.byRace))}
+	out.Base.Options = opts
+	for raceID, cfg := range c.byRace {
+		cfg.Options = opts
+		out.byRace[raceID] = cfg
+	}
+	return out
+}
+
+func LoadConfigs(v *viper.Viper) (Configs, error) {
+	base, err := loadRaceConfig(v)
+	if err != nil {
+		return Configs{}, err
+	}
+
+	configs := Configs{Base: base, byRace: make(map[string]Config)}
+	for raceID := range v.GetStringMap("races") {
+		cfg, err := overrideRaceConfig(base, v.Sub("races."+raceID))
+		if err != nil {
+			return Configs{}, fmt.Errorf("гонка %s: %w", raceID, err)
+		}
+		configs.byRace[raceID] = cfg
+	}
+
+	return configs, nil
+}
+
+// overrideRaceConfig заменяет в cfg только те параметры, которые заданы в v
+func overrideRaceConfig(cfg Config, v *viper.Viper) (Config, error) {
+	if v == nil {
+		return cfg, nil
+	}
+
+	if problems := loadParams(&cfg, v); len(problems) > 0 {
+		return Config{}, &InvalidConfigError{Problems: problems}
+	}
+	return cfg, cfg.Validate()
+}
+
+// defaultTargets — число мишеней на огневом рубеже, если в конфигурации не
+// задан параметр targetsPerLine (targets)
+const defaultTargets = 5
+
+// loadRaceConfig извлекает параметры гонки из прочитанной конфигурации
+func loadRaceConfig(v *viper.Viper) (Config, error) {
+	cfg := Config{
+		RaceType: RaceSprint,
+		Targets:  defaultTargets,
+		// По умолчанию за каждый промах положен штрафной круг
+		CheckPenalties:      true,
+		PenaltyLoopsPerMiss: 1,
+		TotalTimeBase:       TotalTimeScheduled,
+		MissPenalty:         time.Minute,
+		GapPrecision:        time.Second,
+		Qualifiers:          30,
+		TeamScorers:         3,
+	}
+
+	var problems []error
+	for _, key := range []string{"start", "startDelta"} {
+		if !v.IsSet(key) {
+			problems = append(problems, fmt.Errorf("%s: параметр не задан", key))
+		}
+	}
+	problems = append(problems, loadParams(&cfg, v)...)
+	if len(problems) > 0 {
+		return Config{}, &InvalidConfigError{Problems: problems}
+	}
+	return cfg, cfg.Validate()
+}
+
+// loadParams заменяет в cfg параметры, заданные в v, и возвращает ошибки
+// разбора всех параметров сразу
+func loadParams(cfg *Config, v *viper.Viper) []error {
+	problems := loadInts(cfg, v)
+
+	if v.IsSet("start") {
+		start, err := parseStart(v.GetString("start"))
+		if err != nil {
+			problems = append(problems, fmt.Errorf("start: Ошибка парсинга времени старта: %w", err))
+		}
+		cfg.Start = start
+	}
+	if v.IsSet("finalStart") {
+		start, err := parseStart(v.GetString("finalStart"))
+		if err != nil {
+			problems = append(problems, fmt.Errorf("finalStart: Ошибка парсинга времени старта финала: %w", err))
+		}
+		cfg.FinalStart = start
+	}
+	if v.IsSet("startDelta") {
+		startDelta, err := ParseDuration(v.GetString("startDelta"))
+		if err != nil {
+			problems = append(problems, fmt.Errorf("startDelta: Ошибка парсинга времени интервала между стартами: %w", err))
+		}
+		cfg.StartDelta = startDelta
+	}
+	if err := loadCheckPenalties(cfg, v); err != nil {
+		problems = append(problems, err)
+	}
+	if v.IsSet("raceType") {
+		raceType, err := parseRaceType(v.GetString("raceType"))
+		if err != nil {
+			problems = append(problems, err)
+		}
+		cfg.RaceType = raceType
+	}
+	if v.IsSet("totalTimeBase") {
+		cfg.TotalTimeBase = v.GetString("totalTimeBase")
+	}
+	if err := checkTotalTimeBase(cfg.TotalTimeBase); err != nil {
+		problems = append(problems, err)
+	}
+	if v.IsSet("timeLimit") {
+		timeLimit, err := ParseDuration(v.GetString("timeLimit"))
+		if err != nil {
+			problems = append(problems, fmt.Errorf("timeLimit: Ошибка парсинга лимита времени: %w", err))
+		}
+		cfg.TimeLimit = timeLimit
+	}
+	if v.IsSet("missPenalty") {
+		missPenalty, err := ParseDuration(v.GetString("missPenalty"))
+		if err != nil {
+			problems = append(problems, fmt.Errorf("missPenalty: Ошибка парсинга штрафа за промах: %w", err))
+		}
+		cfg.MissPenalty = missPenalty
+	}
+	if v.IsSet("gapPrecision") {
+		precision, err := ParseDuration(v.GetString("gapPrecision"))
+		if err != nil {
+			problems = append(problems, fmt.Errorf("gapPrecision: Ошибка парсинга точности отставаний: %w", err))
+		}
+		cfg.GapPrecision = precision
+	}
+	if v.IsSet("falseStartTolerance") {
+		tolerance, err := ParseDuration(v.GetString("falseStartTolerance"))
+		if err != nil {
+			problems = append(problems, fmt.Errorf("falseStartTolerance: Ошибка парсинга допуска фальстарта: %w", err))
+		}
+		cfg.FalseStartTolerance = tolerance
+	}
+	if err := loadIDPattern(cfg, v); err != nil {
+		problems = append(problems, err)
+	}
+	if err := loadFiringSchedule(cfg, v); err != nil {
+		problems = append(problems, err)
+	}
+	if err := loadTeams(cfg, v); err != nil {
+		problems = append(problems, err)
+	}
+	if err := loadLegs(cfg, v); err != nil {
+		problems = append(problems, err)
+	}
+	if err := loadPoints(cfg, v); err != nil {
+		problems = append(problems, err)
+	}
+
+	return problems
+}
+
+// InvalidConfigError — все ошибки в параметрах гонки, найденные при загрузке
+// конфигурации или Config.Validate
+type InvalidConfigError struct {
+	Problems []error
+}
+
+func (e *InvalidConfigError) Error() string {
+	msgs := make([]string, 0, len(e.Problems))
+	for _, problem := range e.Problems {
+		msgs = append(msgs, problem.Error())
+	}
+	return "некорректная конфигурация: " + strings.Join(msgs, "; ")
+}
+
+// Validate проверяет, что параметры гонки допустимы: без кругов и их длины
+// гонка завершается сразу, а скорость не определена. Возвращает
+// *InvalidConfigError со всеми найденными ошибками.
+func (c Config) Validate() error {
+	var problems []error
+	if c.Laps < 1 {
+		problems = append(problems, fmt.Errorf("laps: ожидается не меньше 1, получено %d", c.Laps))
+	}
+	if c.LapLen <= 0 {
+		problems = append(problems, fmt.Errorf("lapLen: ожидается положительное число, получено %d", c.LapLen))
+	}
+	if c.PenaltyLen <= 0 {
+		problems = append(problems, fmt.Errorf("penaltyLen: ожидается положительное число, получено %d", c.PenaltyLen))
+	}
+	if c.FiringLines < 1 {
+		problems = append(problems, fmt.Errorf("firingLines: ожидается не меньше 1, получено %d", c.FiringLines))
+	}
+	if c.FiringSchedule != nil {
+		if len(c.FiringSchedule) != c.FiringLines {
+			problems = append(problems, fmt.Errorf("firingSchedule: по расписанию %d рубежей, а firingLines = %d", len(c.FiringSchedule), c.FiringLines))
+		}
+		problems = append(problems, scheduleProblems("firingSchedule", c.FiringSchedule, c.Laps)...)
+	}
+	problems = append(problems, c.legProblems()...)
+	seen := make(map[string]string)
+	for _, team := range c.Teams {
+		if len(team.Members) == 0 {
+			problems = append(problems, fmt.Errorf("teams: в команде %s нет участников", team.Name))
+		}
+		for _, id := range team.Members {
+			if other, ok := seen[id]; ok {
+				problems = append(problems, fmt.Errorf("teams: участник %s указан в командах %s и %s", id, other, team.Name))
+			}
+			seen[id] = team.Name
+		}
+	}
+	if c.RaceType == RaceRelay && len(c.Teams) == 0 {
+		problems = append(problems, errors.New("teams: для эстафеты нужны команды в teams или teamsFile"))
+	}
+	if c.Targets < 1 {
+		problems = append(problems, fmt.Errorf("targetsPerLine: ожидается не меньше 1, получено %d", c.Targets))
+	}
+	if c.Points != nil && len(c.Points) == 0 {
+		problems = append(problems, errors.New("points: не заданы очки за 1-е место"))
+	}
+	for i, n := range c.Points {
+		if n < 0 {
+			problems = append(problems, fmt.Errorf("points: очки за место %d не могут быть отрицательными, получено %d", i+1, n))
+		}
+	}
+	if c.TeamScorers < 1 {
+		problems = append(problems, fmt.Errorf("teamScorers: ожидается не меньше 1, получено %d", c.TeamScorers))
+	}
+	if c.Qualifiers < 1 {
+		problems = append(problems, fmt.Errorf("qualifiers: ожидается не меньше 1, получено %d", c.Qualifiers))
+	}
+	if c.PenaltyLoopsPerMiss < 0 {
+		problems = append(problems, fmt.Errorf("penaltyLoopsPerMiss: ожидается не меньше 0, получено %d", c.PenaltyLoopsPerMiss))
+	}
+	if c.Start.IsZero() {
+		problems = append(problems, errors.New("start: время старта не задано"))
+	}
+	if c.StartDelta <= 0 {
+		problems = append(problems, errors.New("startDelta: ожидается положительный интервал между стартами"))
+	}
+	if c.MissPenalty < 0 {
+		problems = append(problems, errors.New("missPenalty: штраф за промах не может быть отрицательным"))
+	}
+	if c.GapPrecision < 0 {
+		problems = append(problems, errors.New("gapPrecision: точность отставаний не может быть отрицательной"))
+	}
+	if c.FalseStartTolerance < 0 {
+		problems = append(problems, errors.New("falseStartTolerance: допуск фальстарта не может быть отрицательным"))
+	}
+	if c.TimeLimit < 0 {
+		problems = append(problems, errors.New("timeLimit: лимит времени не может быть отрицательным"))
+	}
+	if len(problems) > 0 {
+		return &InvalidConfigError{Problems: problems}
+	}
+	return nil
+}
+
+// scheduleProblems проверяет, что круги расписания стрельбы лежат в
+// пределах 1..laps и не повторяются
+func scheduleProblems(key string, schedule []int, laps int) []error {
+	var problems []error
+	for i, lap := range schedule {
+		if lap < 1 || lap > laps {
+			problems = append(problems, fmt.Errorf("%s: круг %d вне диапазона 1..%d", key, lap, laps))
+		} else if i > 0 && lap == schedule[i-1] {
+			problems = append(problems, fmt.Errorf("%s: круг %d указан дважды", key, lap))
+		}
+	}
+	return problems
+}
+
+// legProblems проверяет параметры этапов эстафеты: номер этапа есть в
+// командах, а расписание стрельбы этапа сходится с его числом кругов
+func (c Config) legProblems() []error {
+	if len(c.Legs) == 0 {
+		return nil
+	}
+	if c.RaceType != RaceRelay {
+		return []error{fmt.Errorf("legs: параметры этапов задаются только для raceType %s", RaceRelay)}
+	}
+	maxLeg := 0
+	for _, team := range c.Teams {
+		maxLeg = max(maxLeg, len(team.Members))
+	}
+	numbers := make([]int, 0, len(c.Legs))
+	for n := range c.Legs {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+
+	var problems []error
+	for _, n := range numbers {
+		leg := c.Legs[n]
+		key := fmt.Sprintf("legs.%d", n)
+		if n < 1 || n > maxLeg {
+			problems = append(problems, fmt.Errorf("legs: этап %d вне диапазона 1..%d", n, maxLeg))
+		}
+		if leg.Laps < 0 {
+			problems = append(problems, fmt.Errorf("%s.laps: ожидается не меньше 1, получено %d", key, leg.Laps))
+		}
+		if leg.LapLen < 0 {
+			problems = append(problems, fmt.Errorf("%s.lapLen: ожидается положительное число, получено %d", key, leg.LapLen))
+		}
+		if legCfg := c.forLeg(n); legCfg.FiringSchedule != nil {
+			problems = append(problems, scheduleProblems(key+".firingSchedule", legCfg.FiringSchedule, legCfg.Laps)...)
+		}
+	}
+	return problems
+}
+
+// loadInts читает заданные в v целые параметры гонки. Значение, не
+// являющееся числом, например BIATHLON_LAPS=three, — ошибка, а не 0.
+func loadInts(cfg *Config, v *viper.Viper) []error {
+	var problems []error
+	for _, param := range []struct {
+		key   string
+		field *int
+	}{
+		{"laps", &cfg.Laps},
+		{"lapLen", &cfg.LapLen},
+		{"penaltyLen", &cfg.PenaltyLen},
+		{"firingLines", &cfg.FiringLines},
+		{"targets", &cfg.Targets},
+		// targetsPerLine — то же, что targets; при обоих задан приоритет у него
+		{"targetsPerLine", &cfg.Targets},
+		{"penaltyLoopsPerMiss", &cfg.PenaltyLoopsPerMiss},
+		{"qualifiers", &cfg.Qualifiers},
+		{"teamScorers", &cfg.TeamScorers},
+	} {
+		if !v.IsSet(param.key) {
+			continue
+		}
+		n, err := cast.ToIntE(v.Get(param.key))
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s: ожидается целое число, получено %q", param.key, v.GetString(param.key)))
+			continue
+		}
+		*param.field = n
+	}
+	return problems
+}
+
+// loadFiringSchedule читает firingSchedule: отметки 0/1 для каждого круга,
+// например [1,1,1,1,0], или список номеров кругов со стрельбой. Без
+// firingLines число рубежей берётся из расписания.
+func loadFiringSchedule(cfg *Config, v *viper.Viper) error {
+	if !v.IsSet("firingSchedule") {
+		return nil
+	}
+	raw := v.Get("firingSchedule")
+	if s, ok := raw.(string); ok {
+		// Из переменной окружения: BIATHLON_FIRING_SCHEDULE=1,1,1,1,0
+		raw = strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' })
+	}
+	values, err := cast.ToIntSliceE(raw)
+	if err != nil {
+		return fmt.Errorf("firingSchedule: ожидается список целых чисел, получено %q", v.GetString("firingSchedule"))
+	}
+
+	schedule := parseFiringSchedule(values, cfg.Laps)
+	cfg.FiringSchedule = schedule
+	if !v.IsSet("firingLines") {
+		cfg.FiringLines = len(schedule)
+	}
+	return nil
+}
+
+// parseFiringSchedule приводит расписание стрельбы к номерам кругов: values —
+// отметки 0/1 для каждого из laps кругов или сами номера кругов
+func parseFiringSchedule(values []int, laps int) []int {
+	flags := len(values) == laps
+	for _, n := range values {
+		if n != 0 && n != 1 {
+			flags = false
+		}
+	}
+	schedule := []int{}
+	if flags {
+		for i, n := range values {
+			if n == 1 {
+				schedule = append(schedule, i+1)
+			}
+		}
+	} else {
+		schedule = append(schedule, values...)
+		sort.Ints(schedule)
+	}
+	return schedule
+}
+
+// loadLegs читает параметры этапов эстафеты legs — объект с номерами этапов
+// в ключах: {"1": {"laps": 3, "lapLen": 1500, "firingSchedule": [1, 2]}}.
+// Не заданные для этапа параметры берутся из общих.
+func loadLegs(cfg *Config, v *viper.Viper) error {
+	if !v.IsSet("legs") {
+		return nil
+	}
+	var raw map[string]struct {
+		Laps           int
+		LapLen         int
+		FiringSchedule []int
+	}
+	if err := v.UnmarshalKey("legs", &raw); err != nil {
+		return fmt.Errorf("legs: ожидаются параметры этапов laps, lapLen и firingSchedule: %w", err)
+	}
+
+	legs := make(map[int]Leg, len(raw))
+	for key, params := range raw {
+		n, err := strconv.Atoi(key)
+		if err != nil {
+			return fmt.Errorf("legs: номер этапа должен быть целым числом, получено %q", key)
+		}
+		leg := Leg{Laps: params.Laps, LapLen: params.LapLen}
+		if params.FiringSchedule != nil {
+			laps := cfg.Laps
+			if leg.Laps > 0 {
+				laps = leg.Laps
+			}
+			leg.FiringSchedule = parseFiringSchedule(params.FiringSchedule, laps)
+		}
+		legs[n] = leg
+	}
+	cfg.Legs = legs
+	return nil
+}
+
+// loadTeams читает команды эстафеты: список teams вида
+// [{"name": "A", "members": [1, 2, 3]}] или файл teamsFile, где каждая строка —
+// название команды, двоеточие и участники по этапам: "A: 1 2 3"
+func loadTeams(cfg *Config, v *viper.Viper) error {
+	var teams []Team
+	if v.IsSet("teams") {
+		var raw []struct {
+			Name    string
+			Members []string
+		}
+		if err := v.UnmarshalKey("teams", &raw); err != nil {
+			return fmt.Errorf("teams: ожидается список команд с name и members: %w", err)
+		}
+		for _, team := range raw {
+			teams = append(teams, Team{Name: team.Name, Members: team.Members})
+		}
+	}
+	if v.IsSet("teamsFile") {
+		fromFile, err := readTeamsFile(v.GetString("teamsFile"))
+		if err != nil {
+			return fmt.Errorf("teamsFile: %w", err)
+		}
+		teams = append(teams, fromFile...)
+	}
+	if teams == nil {
+		return nil
+	}
+
+	for i := range teams {
+		for j, id := range teams[i].Members {
+			canonical, err := cfg.CompetitorID(id)
+			if err != nil {
+				return fmt.Errorf("команда %s: %w", teams[i].Name, err)
+			}
+			teams[i].Members[j] = canonical
+		}
+	}
+	cfg.Teams = teams
+	return nil
+}
+
+func readTeamsFile(path string) ([]Team, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var teams []Team
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, members, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: ожидается \"команда: участники\", получено %q", path, n+1, line)
+		}
+		teams = append(teams, Team{
+			Name:    strings.TrimSpace(name),
+			Members: strings.FieldsFunc(members, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }),
+		})
+	}
+	return teams, nil
+}
+
+// loadPoints читает таблицу очков points: список очков по местам, например
+// [60, 54, 48], или объект с местами в ключах: {"1": 60, "2": 54}. В объекте
+// места идут подряд с 1-го.
+func loadPoints(cfg *Config, v *viper.Viper) error {
+	if !v.IsSet("points") {
+		return nil
+	}
+	raw := v.Get("points")
+	if byPlace, ok := raw.(map[string]any); ok {
+		points := make([]int, len(byPlace))
+		for key, value := range byPlace {
+			place, err := strconv.Atoi(key)
+			if err != nil || place < 1 || place > len(byPlace) {
+				return fmt.Errorf("points: места должны идти подряд с 1-го, получено место %q", key)
+			}
+			n, err := cast.ToIntE(value)
+			if err != nil {
+				return fmt.Errorf("points: очки за место %d должны быть целым числом, получено %v", place, value)
+			}
+			points[place-1] = n
+		}
+		cfg.Points = points
+		return nil
+	}
+	if s, ok := raw.(string); ok {
+		// Из переменной окружения: BIATHLON_POINTS=60,54,48
+		raw = strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' })
+	}
+	points, err := cast.ToIntSliceE(raw)
+	if err != nil {
+		return fmt.Errorf("points: ожидается список целых чисел, получено %q", v.GetString("points"))
+	}
+	cfg.Points = points
+	return nil
+}
+
+func loadCheckPenalties(cfg *Config, v *viper.Viper) error {
+	if !v.IsSet("checkPenalties") {
+		return nil
+	}
+	check, err := cast.ToBoolE(v.Get("checkPenalties"))
+	if err != nil {
+		return fmt.Errorf("checkPenalties: ожидается true или false, получено %q", v.GetString("checkPenalties"))
+	}
+	cfg.CheckPenalties = check
+	return nil
+}
+
+// ParseDuration разбирает интервалы из конфигурации, например startDelta: в формате Go
+// ("90s", "1m30s") или, как раньше, HH:MM:SS с необязательными долями секунды
+func ParseDuration(s string) (time.Duration, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, nil
+	}
+	t, err := time.Parse(parser.TimeFormat[:8], s)
+	if err != nil {
+		return 0, fmt.Errorf("ожидается интервал вида 90s, 1m30s или HH:MM:SS, получено %q", s)
+	}
+	return t.Sub(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())), nil
+}
+
+func checkTotalTimeBase(base string) error {
+	if base != TotalTimeScheduled && base != TotalTimeActual {
+		return fmt.Errorf("totalTimeBase: ожидается %s или %s, получено %q", TotalTimeScheduled, TotalTimeActual, base)
+	}
+	return nil
+}
+
+// loadIDPattern читает шаблон ID участников competitorIdPattern для федераций
+// с буквенно-цифровыми номерами. Шаблон должен совпадать с ID целиком.
+func loadIDPattern(cfg *Config, v *viper.Viper) error {
+	if !v.IsSet("competitorIdPattern") {
+		return nil
+	}
+	pattern, err := regexp.Compile("^(?:" + v.GetString("competitorIdPattern") + ")$")
+	if err != nil {
+		return fmt.Errorf("Ошибка в шаблоне ID участников: %w", err)
+	}
+	cfg.IDPattern = pattern
+	return nil
+}
+
+// parseStart разбирает время старта HH:MM:SS, перед которым может быть
+// указана дата гонки 2006-01-02. Дата назначается событиям без даты.
+func parseStart(s string) (time.Time, error) {
+	if len(s) > len(parser.DateFormat) && s[4] == '-' {
+		return time.Parse(parser.DateFormat+parser.TimeFormat[:8], s)
+	}
+	return time.Parse(parser.TimeFormat[:8], s)
+}
@@ -0,0 +1,81 @@
+package race
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"30s", 30 * time.Second},
+		{"90s", 90 * time.Second},
+		{"1m30s", 90 * time.Second},
+		{"1h15m", 75 * time.Minute},
+		{"1.5s", 1500 * time.Millisecond},
+		{"00:00:30", 30 * time.Second},
+		{"00:01:30", 90 * time.Second},
+		{"01:30:00", 90 * time.Minute},
+		{"00:01:30.500", 90*time.Second + 500*time.Millisecond},
+	}
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.in)
+		if err != nil {
+			t.Errorf("ParseDuration(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseDuration(%q) = %s, ожидалось %s", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "90", "1:30", "half a minute", "00:61:00"} {
+		if _, err := ParseDuration(in); err == nil {
+			t.Errorf("ParseDuration(%q): нет ошибки", in)
+		}
+	}
+}
+
+func TestStartDeltaFormats(t *testing.T) {
+	for _, delta := range []string{`"90s"`, `"1m30s"`, `"00:01:30"`} {
+		if got := testConfig(t, `{"startDelta": `+delta+`}`).StartDelta; got != 90*time.Second {
+			t.Errorf("startDelta %s: %s, ожидалось 1m30s", delta, got)
+		}
+	}
+}
+
+func TestPointsTable(t *testing.T) {
+	for _, points := range []string{`[60, 54, 48]`, `{"1": 60, "2": 54, "3": 48}`} {
+		if got := testConfig(t, `{"points": `+points+`}`).Points; !reflect.DeepEqual(got, []int{60, 54, 48}) {
+			t.Errorf("points %s: %v, ожидалось [60 54 48]", points, got)
+		}
+	}
+
+	tests := map[string]string{
+		`[]`:                 "не заданы очки за 1-е место",
+		`[60, -1]`:           "очки за место 2 не могут быть отрицательными",
+		`{"2": 54}`:          "места должны идти подряд с 1-го",
+		`{"1": 60, "x": 54}`: "места должны идти подряд с 1-го",
+		`{"1": "много"}`:     "очки за место 1 должны быть целым числом",
+		`["шестьдесят"]`:     "ожидается список целых чисел",
+	}
+	for points, want := range tests {
+		v := viper.New()
+		v.SetConfigType("json")
+		if err := v.ReadConfig(strings.NewReader(testConfigJSON)); err != nil {
+			t.Fatal(err)
+		}
+		if err := v.MergeConfig(strings.NewReader(`{"points": ` + points + `}`)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadConfigs(v); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("points %s: ошибка %v, ожидалась %q", points, err, want)
+		}
+	}
+}
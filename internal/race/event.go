@@ -0,0 +1,405 @@
+package race
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"biathlon_system/parser"
+)
+
+// Handler обрабатывает событие ev участника c. Встроенные события 1–12
+// обрабатываются так же; Options.Handlers дополняет или заменяет их.
+type Handler func(c *Competitor, ev parser.Event) error
+
+// builtinHandlers — обработчики встроенных событий по ID. Не изменяется
+// после инициализации пакета, поэтому читается без блокировки.
+var builtinHandlers = map[int]Handler{
+	1:  handleRegistered,
+	2:  handleStartDraw,
+	3:  handleStartLine,
+	4:  handleStarted,
+	5:  handleRangeEntered,
+	6:  handleTargetHit,
+	7:  handleRangeLeft,
+	8:  handlePenaltyEntered,
+	9:  handlePenaltyLeft,
+	10: handleLapEnded,
+	11: handleCannotContinue,
+	12: handleExchange,
+}
+
+// handler возвращает обработчик событий с ID id: из Options.Handlers, а
+// если его там нет — встроенный
+func (c Config) handler(id int) (Handler, bool) {
+	if h, ok := c.Handlers[id]; ok {
+		return h, true
+	}
+	h, ok := builtinHandlers[id]
+	return h, ok
+}
+
+// IsKnownEvent сообщает, есть ли обработчик событий с таким ID
+func (c Config) IsKnownEvent(id int) bool {
+	_, ok := c.handler(id)
+	return ok
+}
+
+// Competitor — участник гонки, к которому относится обрабатываемое событие
+type Competitor struct {
+	ID   string
+	cfg  Config
+	stat *competitorStat
+}
+
+// Config возвращает параметры гонки участника
+func (c *Competitor) Config() Config {
+	return c.cfg
+}
+
+// Note возвращает дополнительные данные участника с ключом key
+func (c *Competitor) Note(key string) string {
+	return c.stat.notes[key]
+}
+
+// SetNote записывает дополнительные данные участника, они выводятся в
+// Result.Notes
+func (c *Competitor) SetNote(key, value string) {
+	if c.stat.notes == nil {
+		c.stat.notes = make(map[string]string)
+	}
+	c.stat.notes[key] = value
+}
+
+// handleEvent применяет событие к состоянию его участника
+func (r *Race) handleEvent(ev parser.Event) error {
+	if err := r.cfg.checkRaceStart(ev); err != nil {
+		return err
+	}
+
+	// Неизвестное событие пропускается и не заводит участника в таблице
+	handler, ok := r.cfg.handler(ev.ID)
+	if !ok {
+		r.cfg.log().Warnf("Неизвестный ID события: %d, событие: %s", ev.ID, ev.Raw)
+		return nil
+	}
+
+	if _, ok := r.stats[ev.CompetitorID]; !ok {
+		stat := &competitorStat{
+			lapsTime:    make([][2]time.Time, 0),
+			penaltyTime: make([][2]time.Time, 0),
+		}
+		if _, leg, _ := r.cfg.teamOf(ev.CompetitorID); r.cfg.rules().sharedStart || r.cfg.rules().relay && leg == 1 {
+			// Масс-старт и первый этап эстафеты: жеребьёвки нет, все
+			// стартуют по выстрелу
+			stat.startTime = r.cfg.Start
+		} else if start, ok := r.cfg.pursuitStart(ev.CompetitorID); ok {
+			stat.startTime = start
+		} else if r.cfg.PursuitBasis != nil {
+			r.cfg.log().Warnf("Строка %d: участник %s не финишировал в квалификации гонки преследования", ev.Line, ev.CompetitorID)
+		}
+		r.stats[ev.CompetitorID] = stat
+	}
+
+	return handler(&Competitor{ID: ev.CompetitorID, cfg: r.cfg.forCompetitor(ev.CompetitorID), stat: r.stats[ev.CompetitorID]}, ev)
+}
+
+// handleRegistered — событие 1: участник зарегистрирован
+func handleRegistered(c *Competitor, ev parser.Event) error {
+	cfg, stat := c.cfg, c.stat
+	timeStr := ev.TimeStr
+	idComp := c.ID
+
+	if stat.registered {
+		cfg.log().Warnf("Строка %d: повторная регистрация участника %s, событие: %s", ev.Line, idComp, ev.Raw)
+	}
+	stat.registered = true
+	cfg.log().Infof("%s The competitor(%s) registered", timeStr, idComp)
+	return nil
+}
+
+// handleStartDraw — событие 2: жеребьёвка старта
+func handleStartDraw(c *Competitor, ev parser.Event) error {
+	cfg, stat := c.cfg, c.stat
+	timeStr := ev.TimeStr
+	idComp := c.ID
+	start := cfg.Start
+
+	// В одних источниках время старта записано в скобках, как и метка
+	// события, в других — без них
+	startTimeStr := strings.TrimSpace(ev.Extra)
+	if strings.HasPrefix(startTimeStr, "[") && strings.HasSuffix(startTimeStr, "]") {
+		startTimeStr = startTimeStr[1 : len(startTimeStr)-1]
+	}
+	startTime, err := parser.ParseClock(startTimeStr)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Ошибка парсинга времени старта из события: %s, событие: %s", err, ev.Raw))
+	}
+	if !cfg.IgnoreRaceStart && parser.WithDate(startTime, start).Before(start) {
+		msg := fmt.Sprintf("время старта участника %s по жеребьёвке %s раньше старта гонки %s, событие: %s", idComp, startTimeStr, start.Format(parser.TimeFormat), ev.Raw)
+		if cfg.Strict {
+			return errors.New(msg)
+		}
+		cfg.log().Warnf("Строка %d: %s", ev.Line, msg)
+	}
+	if cfg.rules().sharedStart {
+		if drawn := parser.WithDate(startTime, start); !drawn.Equal(start) {
+			cfg.log().Warnf("Строка %d: время старта участника %s %s проигнорировано: в масс-старте все стартуют в %s", ev.Line, idComp, startTimeStr, start.Format(parser.TimeFormat))
+		}
+		cfg.log().Infof("%s The start time for the competitor(%s) was set by a draw to %s", timeStr, idComp, startTimeStr)
+		return nil
+	}
+	if _, ok := cfg.pursuitStart(idComp); ok {
+		checkPursuitDraw(c, ev, parser.WithDate(startTime, start))
+		cfg.log().Infof("%s The start time for the competitor(%s) was set by a draw to %s", timeStr, idComp, startTimeStr)
+		return nil
+	}
+	if !stat.startTime.IsZero() {
+		if cfg.Strict {
+			return fmt.Errorf("повторная жеребьёвка участника %s: время старта уже назначено на %s, событие: %s", idComp, stat.startTime.Format(parser.TimeFormat), ev.Raw)
+		}
+		cfg.log().Warnf("Строка %d: повторная жеребьёвка участника %s: время старта %s заменено на %s", ev.Line, idComp, stat.startTime.Format(parser.TimeFormat), startTimeStr)
+	}
+	stat.startTime = parser.WithDate(startTime, start)
+	cfg.log().Infof("%s The start time for the competitor(%s) was set by a draw to %s", timeStr, idComp, startTimeStr)
+	return nil
+}
+
+// handleStartLine — событие 3: участник на стартовой линии
+func handleStartLine(c *Competitor, ev parser.Event) error {
+	cfg := c.cfg
+	timeStr := ev.TimeStr
+	idComp := c.ID
+
+	cfg.log().Infof("%s The competitor(%s) is on the start line", timeStr, idComp)
+	return nil
+}
+
+// handleStarted — событие 4: участник стартовал
+func handleStarted(c *Competitor, ev parser.Event) error {
+	cfg, stat := c.cfg, c.stat
+	timeStr := ev.TimeStr
+	timeEv := parser.WithDate(ev.Time, cfg.Start)
+	idComp := c.ID
+
+	if len(stat.lapsTime) > 0 {
+		cfg.log().Warnf("Строка %d: повторный старт участника %s в %s проигнорирован, первый старт в %s", ev.Line, idComp, timeStr, stat.actualStart.Format(parser.TimeFormat))
+		return nil
+	}
+	stat.actualStart = timeEv
+	stat.lapsTime = append(stat.lapsTime, [2]time.Time{timeEv})
+	cfg.log().Infof("%s The competitor(%s) has started", timeStr, idComp)
+
+	if _, ok := cfg.pursuitStart(idComp); ok && stat.actualStart.Before(stat.startTime) {
+		cfg.log().Warnf("Строка %d: участник %s стартовал в %s раньше времени по квалификации %s", ev.Line, idComp, timeStr, stat.startTime.Format(parser.TimeFormat))
+	}
+	if cfg.rules().sharedStart {
+		checkFalseStart(c, ev)
+		return nil
+	}
+	if cfg.rules().relay {
+		checkRelayStart(c, ev)
+		return nil
+	}
+	if stat.startTime.IsZero() {
+		// Без жеребьёвки опоздание не определить: время гонки считается от
+		// фактического старта
+		cfg.log().Warnf("Строка %d: участник %s стартовал без времени старта по жеребьёвке, время гонки считается от фактического старта %s", ev.Line, idComp, timeStr)
+		return nil
+	}
+	deadline := stat.startTime.Add(cfg.StartDelta)
+	if stat.actualStart.After(deadline) {
+		stat.notStarted = true
+		stat.comment = "Дисквалифицирован: старт после допустимого времени"
+		cfg.log().Warnf("Участник %s дисквалифицирован: старт после допустимого времени (%s > %s).", idComp, stat.actualStart.Format(parser.TimeFormat), deadline.Format(parser.TimeFormat))
+	}
+	return nil
+}
+
+// handleRangeEntered — событие 5: участник на огневом рубеже
+func handleRangeEntered(c *Competitor, ev parser.Event) error {
+	cfg, stat := c.cfg, c.stat
+	timeStr := ev.TimeStr
+	timeEv := parser.WithDate(ev.Time, cfg.Start)
+	idComp := c.ID
+
+	firingRange := ev.Extra
+	n, err := strconv.Atoi(firingRange)
+	if err != nil || n < 1 || n > cfg.FiringLines {
+		msg := fmt.Sprintf("номер огневого рубежа %s вне диапазона 1..%d, событие: %s", firingRange, cfg.FiringLines, ev.Raw)
+		if cfg.Strict {
+			return errors.New(msg)
+		}
+		// Посещение рубежа всё равно учитывается: попадания и штрафные
+		// круги после него не должны потеряться
+		cfg.log().Warnf("Строка %d: %s, рубеж считается неизвестным", ev.Line, msg)
+		n = 0
+	}
+	if bout := stat.openBout(); bout != nil {
+		cfg.log().Warnf("Строка %d: участник %s не покинул огневой рубеж %d перед выходом на следующий", ev.Line, idComp, bout.firingRange)
+		bout.closed = true
+	}
+	lap := max(len(stat.lapsTime), 1)
+	if cfg.FiringSchedule != nil && !cfg.firingLap(lap) {
+		cfg.log().Warnf("Строка %d: участник %s вышел на огневой рубеж на круге %d, на котором стрельба расписанием не предусмотрена", ev.Line, idComp, lap)
+	}
+	stat.bouts = append(stat.bouts, shootingBout{firingRange: n, lap: lap, interval: [2]time.Time{timeEv, {}}})
+	cfg.log().Infof("%s The competitor(%s) is on the firing range(%s)", timeStr, idComp, firingRange)
+	return nil
+}
+
+// handleTargetHit — событие 6: попадание в цель
+func handleTargetHit(c *Competitor, ev parser.Event) error {
+	cfg, stat := c.cfg, c.stat
+	timeStr := ev.TimeStr
+	idComp := c.ID
+
+	target := ev.Extra
+	n, err := strconv.Atoi(target)
+	if err != nil || n < 1 || n > cfg.Targets {
+		return fmt.Errorf("номер мишени %s вне диапазона 1..%d, событие: %s", target, cfg.Targets, ev.Raw)
+	}
+	bout := stat.openBout()
+	if bout == nil {
+		// Шум датчиков вне огневого рубежа не должен влиять на результат
+		return fmt.Errorf("попадание участника %s вне огневого рубежа, событие: %s", idComp, ev.Raw)
+	}
+	switch {
+	case bout.hit(n):
+		// Повторное срабатывание датчика той же мишени
+		cfg.log().Warnf("Строка %d: повторное попадание участника %s в мишень %d проигнорировано, событие: %s", ev.Line, idComp, n, ev.Raw)
+	case len(bout.targets) >= cfg.Targets:
+		cfg.log().Errorf("Строка %d: у участника %s на рубеже %d больше %d попаданий, событие: %s", ev.Line, idComp, bout.firingRange, cfg.Targets, ev.Raw)
+	default:
+		bout.targets = append(bout.targets, n)
+		cfg.log().Infof("%s The target(%s) has been hit by competitor(%s)", timeStr, target, idComp)
+	}
+	return nil
+}
+
+// handleRangeLeft — событие 7: участник покинул огневой рубеж
+func handleRangeLeft(c *Competitor, ev parser.Event) error {
+	cfg, stat := c.cfg, c.stat
+	timeStr := ev.TimeStr
+	timeEv := parser.WithDate(ev.Time, cfg.Start)
+	idComp := c.ID
+
+	if bout := stat.openBout(); bout != nil {
+		bout.closed = true
+		bout.interval[1] = timeEv
+		if cfg.rules().shootingDNF {
+			checkShootingFailure(c, ev, bout)
+		}
+	} else {
+		cfg.log().Warnf("Строка %d: участник %s покинул огневой рубеж, не заходя на него, событие: %s", ev.Line, idComp, ev.Raw)
+	}
+	cfg.log().Infof("%s The competitor(%s) left the firing range", timeStr, idComp)
+	return nil
+}
+
+// handlePenaltyEntered — событие 8: участник зашел на штрафной круг
+func handlePenaltyEntered(c *Competitor, ev parser.Event) error {
+	cfg, stat := c.cfg, c.stat
+	timeStr := ev.TimeStr
+	timeEv := parser.WithDate(ev.Time, cfg.Start)
+	idComp := c.ID
+
+	stat.addPenalty([2]time.Time{timeEv, {}}) // Начало штрафного круга
+	cfg.log().Infof("%s The competitor(%s) entered the penalty laps", timeStr, idComp)
+	return nil
+}
+
+// handlePenaltyLeft — событие 9: участник покинул штрафной круг
+func handlePenaltyLeft(c *Competitor, ev parser.Event) error {
+	cfg, stat := c.cfg, c.stat
+	timeStr := ev.TimeStr
+	timeEv := parser.WithDate(ev.Time, cfg.Start)
+	idComp := c.ID
+
+	// Интервал проверяется до записи: отклонённое событие не меняет состояние
+	n := len(stat.penaltyTime)
+	entered := n > 0 && stat.penaltyTime[n-1][1].IsZero()
+	var enteredAt time.Time
+	if entered {
+		enteredAt = stat.penaltyTime[n-1][0]
+	}
+	if err := cfg.checkInterval(ev, "штрафной круг", [2]time.Time{enteredAt, timeEv}); err != nil {
+		return err
+	}
+	if !entered {
+		// Вход на штрафной круг потерян: интервал без начала выводится в отчёте как {,}
+		cfg.log().Warnf("Выход участника %s со штрафного круга без входа на него, событие: %s", idComp, ev.Raw)
+		stat.addPenalty([2]time.Time{})
+	}
+	stat.penaltyTime[len(stat.penaltyTime)-1][1] = timeEv // Конец штрафного круга
+	cfg.log().Infof("%s The competitor(%s) left the penalty laps", timeStr, idComp)
+	return nil
+}
+
+// handleLapEnded — событие 10: участник закончил круг
+func handleLapEnded(c *Competitor, ev parser.Event) error {
+	cfg, stat := c.cfg, c.stat
+	timeStr := ev.TimeStr
+	timeEv := parser.WithDate(ev.Time, cfg.Start)
+	idComp := c.ID
+	laps := cfg.Laps
+
+	if !stat.finishTime.IsZero() {
+		// Повторное срабатывание финишного створа не должно ухудшать результат
+		cfg.log().Warnf("Строка %d: лишнее окончание круга участника %s в %s после финиша в %s проигнорировано", ev.Line, idComp, timeStr, stat.finishTime.Format(parser.TimeFormat))
+		return nil
+	}
+	// Круг и время гонки проверяются до записи: отклонённое событие не
+	// меняет состояние
+	started := len(stat.lapsTime) > 0
+	lapStart := stat.startTime
+	if started {
+		lapStart = stat.lapsTime[len(stat.lapsTime)-1][0]
+	}
+	if err := cfg.checkInterval(ev, "круг", [2]time.Time{lapStart, timeEv}); err != nil {
+		return err
+	}
+	finished := max(len(stat.lapsTime), 1) >= laps
+	if finished {
+		probe := *stat
+		if !started {
+			probe.actualStart = stat.startTime
+		}
+		if err := cfg.checkInterval(ev, "время гонки", [2]time.Time{probe.raceStart(cfg), timeEv}); err != nil {
+			return err
+		}
+	}
+
+	if !started {
+		// Событие старта потеряно: круг начинается по времени старта из жеребьёвки
+		cfg.log().Warnf("Окончание круга участника %s без события старта, используется время старта по жеребьёвке, событие: %s", idComp, ev.Raw)
+		stat.actualStart = stat.startTime
+		stat.lapsTime = append(stat.lapsTime, [2]time.Time{stat.startTime})
+	}
+	stat.lapsTime[len(stat.lapsTime)-1][1] = timeEv
+	cfg.log().Infof("%s The competitor(%s) ended the main lap", timeStr, idComp)
+	if lap := len(stat.lapsTime); cfg.firingLap(lap) && !stat.shotOnLap(lap) {
+		cfg.log().Warnf("Строка %d: участник %s закончил круг %d, пропустив стрельбу по расписанию", ev.Line, idComp, lap)
+	}
+	if finished {
+		stat.finishTime = timeEv
+	} else {
+		stat.lapsTime = append(stat.lapsTime, [2]time.Time{timeEv})
+	}
+	return nil
+}
+
+// handleCannotContinue — событие 11: участник не может продолжать
+func handleCannotContinue(c *Competitor, ev parser.Event) error {
+	cfg, stat := c.cfg, c.stat
+	timeStr := ev.TimeStr
+	idComp := c.ID
+
+	comment := ev.Extra
+	stat.notFinished = true
+	stat.comment = comment
+	cfg.log().Infof("%s The competitor(%s) can`t continue: %s", timeStr, idComp, comment)
+	return nil
+}
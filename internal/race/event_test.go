@@ -0,0 +1,434 @@
+package race
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"biathlon_system/parser"
+)
+
+func TestCustomHandler(t *testing.T) {
+	// Событие 33 — погода на огневом рубеже от системы хронометража
+	cfg := testConfig(t, "")
+	cfg.Handlers = map[int]Handler{
+		33: func(c *Competitor, ev parser.Event) error {
+			c.SetNote("weather", ev.Extra)
+			return nil
+		},
+	}
+	lines := `
+[09:05:00.000] 1 1
+[09:10:00.000] 2 1 10:00:00.000
+[10:00:01.000] 4 1
+[10:05:00.000] 33 1 snow
+`
+	row := resultOf(t, runRace(t, cfg, lines).Results(), "1")
+	if got := row.Notes["weather"]; got != "snow" {
+		t.Errorf("Notes[weather] = %q, ожидалось snow", got)
+	}
+
+	if !cfg.IsKnownEvent(33) || !cfg.IsKnownEvent(1) {
+		t.Error("IsKnownEvent: нет обработчика события 33 или встроенного события 1")
+	}
+	if testConfig(t, "").IsKnownEvent(33) {
+		t.Error("обработчик события 33 виден в гонке без него")
+	}
+}
+
+// outOfRangeRecord — выход на огневой рубеж 4 при двух рубежах в конфигурации
+const outOfRangeRecord = `
+[09:05:00.000] 1 1
+[09:10:00.000] 2 1 10:00:00.000
+[10:00:01.000] 4 1
+[10:10:00.000] 5 1 4
+[10:10:01.000] 6 1 1
+[10:10:02.000] 6 1 2
+[10:10:10.000] 7 1
+`
+
+func TestRangeOutOfBounds(t *testing.T) {
+	t.Run("lenient", func(t *testing.T) {
+		// Посещение учитывается с неизвестным рубежом, попадания не теряются
+		log := &recordLogger{}
+		cfg := testConfig(t, "")
+		cfg.Logger = log
+		row := resultOf(t, runRace(t, cfg, outOfRangeRecord).Results(), "1")
+		if row.Hits != 2 || len(row.BoutHits) != 1 {
+			t.Errorf("попаданий %d по рубежам %v, ожидалось 2 на одном рубеже", row.Hits, row.BoutHits)
+		}
+		if !log.contains("рубеж считается неизвестным") {
+			t.Errorf("нет предупреждения о номере рубежа: %q", log.warnings)
+		}
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		cfg := testConfig(t, "")
+		cfg.Strict = true
+		r := New(cfg)
+		var err error
+		for _, ev := range parseEvents(t, outOfRangeRecord) {
+			if err = r.Apply(ev); err != nil {
+				break
+			}
+		}
+		if err == nil || !strings.Contains(err.Error(), "вне диапазона 1..2") {
+			t.Errorf("ошибка %v, ожидалась ошибка номера рубежа", err)
+		}
+	})
+}
+
+func TestMissedRangesUnknown(t *testing.T) {
+	bouts := []shootingBout{{firingRange: 1}, {firingRange: 0}}
+	if missed := missedRanges(bouts, 2); missed != nil {
+		t.Errorf("missedRanges = %v: посещение неизвестного рубежа могло быть на рубеже 2", missed)
+	}
+	if missed := missedRanges(bouts, 3); len(missed) != 2 {
+		t.Errorf("missedRanges = %v, ожидались рубежи 2 и 3", missed)
+	}
+}
+
+func TestPenaltyLeftWithoutEntry(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines string
+		want  []bool // Incomplete для каждого штрафного интервала
+	}{
+		{"без события 8", `
+[09:05:00.000] 1 1
+[09:10:00.000] 2 1 10:00:00.000
+[10:00:01.000] 4 1
+[10:12:00.000] 9 1
+`, []bool{true}},
+		{"событие 9 дважды", `
+[09:05:00.000] 1 1
+[09:10:00.000] 2 1 10:00:00.000
+[10:00:01.000] 4 1
+[10:10:20.000] 8 1
+[10:12:00.000] 9 1
+[10:12:05.000] 9 1
+`, []bool{false, true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := &recordLogger{}
+			cfg := testConfig(t, "")
+			cfg.Logger = log
+			row := resultOf(t, runRace(t, cfg, tt.lines).Results(), "1")
+			if len(row.Penalties) != len(tt.want) {
+				t.Fatalf("штрафных интервалов %d, ожидалось %d", len(row.Penalties), len(tt.want))
+			}
+			for i, incomplete := range tt.want {
+				if row.Penalties[i].Incomplete != incomplete {
+					t.Errorf("интервал %d: Incomplete = %v, ожидалось %v", i+1, row.Penalties[i].Incomplete, incomplete)
+				}
+			}
+			if !tt.want[0] && row.Penalties[0].Time != 100*time.Second {
+				t.Errorf("первый интервал %s перезаписан, ожидалось 1m40s", row.Penalties[0].Time)
+			}
+			if !log.contains("без входа на него") {
+				t.Errorf("нет предупреждения о потерянном событии 8: %q", log.warnings)
+			}
+		})
+	}
+}
+
+// duplicateSensorRecord — датчик мишени 3 на первом рубеже срабатывает дважды
+const duplicateSensorRecord = `
+[09:05:00.000] 1 1
+[09:10:00.000] 2 1 10:00:00.000
+[10:00:01.000] 4 1
+[10:10:00.000] 5 1 1
+[10:10:01.000] 6 1 1
+[10:10:02.000] 6 1 2
+[10:10:03.000] 6 1 3
+[10:10:03.050] 6 1 3
+[10:10:04.000] 6 1 4
+[10:10:05.000] 6 1 5
+[10:10:10.000] 7 1
+`
+
+func TestDuplicateTargetHit(t *testing.T) {
+	log := &recordLogger{}
+	cfg := testConfig(t, "")
+	cfg.Logger = log
+	row := resultOf(t, runRace(t, cfg, duplicateSensorRecord).Results(), "1")
+	if row.Hits != 5 || row.Shots != 10 {
+		t.Errorf("попаданий %d/%d, ожидалось 5/10", row.Hits, row.Shots)
+	}
+	if !log.contains("Строка 8: повторное попадание участника 1 в мишень 3") {
+		t.Errorf("нет предупреждения о повторном попадании: %q", log.warnings)
+	}
+
+	r := New(testConfig(t, ""))
+	for _, ev := range parseEvents(t, duplicateSensorRecord)[:4] {
+		if err := r.Apply(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, target := range []string{"0", "6", "x"} {
+		ev := parseEvents(t, "[10:10:06.000] 6 1 "+target)[0]
+		if err := r.Apply(ev); err == nil || !strings.Contains(err.Error(), "вне диапазона 1..5") {
+			t.Errorf("мишень %s: ошибка %v, ожидалась ошибка номера мишени", target, err)
+		}
+	}
+}
+
+// strayHitRecord — попадание через две минуты после ухода с рубежа и уход
+// с рубежа без выхода на него
+const strayHitRecord = `
+[09:05:00.000] 1 1
+[09:10:00.000] 2 1 10:00:00.000
+[10:00:01.000] 4 1
+[10:10:00.000] 5 1 1
+[10:10:01.000] 6 1 1
+[10:10:10.000] 7 1
+[10:12:10.000] 6 1 2
+[10:20:00.000] 7 1
+`
+
+func TestHitOutsideRange(t *testing.T) {
+	log := &recordLogger{}
+	cfg := testConfig(t, "")
+	cfg.Logger = log
+	r := New(cfg)
+	for _, ev := range parseEvents(t, strayHitRecord) {
+		err := r.Apply(ev)
+		if ev.Line == 7 {
+			if err == nil || !strings.Contains(err.Error(), "вне огневого рубежа") {
+				t.Errorf("строка 7: ошибка %v, ожидалась ошибка попадания вне рубежа", err)
+			}
+		} else if err != nil {
+			t.Fatalf("строка %d: %v", ev.Line, err)
+		}
+	}
+	r.Finalize()
+
+	if row := resultOf(t, r.Results(), "1"); row.Hits != 1 {
+		t.Errorf("попаданий %d, ожидалось 1", row.Hits)
+	}
+	if !log.contains("Строка 8: участник 1 покинул огневой рубеж, не заходя на него") {
+		t.Errorf("нет предупреждения о событии 7 без события 5: %q", log.warnings)
+	}
+}
+
+// lapRecord — три круга участника 1 с повторным срабатыванием финишного
+// створа и два круга участника 2
+const lapRecord = `
+[09:05:00.000] 1 1
+[09:05:01.000] 1 2
+[09:10:00.000] 2 1 10:00:00.000
+[09:10:01.000] 2 2 10:01:30.000
+[10:00:01.000] 4 1
+[10:01:31.000] 4 2
+[10:12:00.000] 10 1
+[10:13:30.000] 10 2
+[10:24:00.000] 10 1
+[10:25:30.000] 10 2
+[10:36:00.000] 10 1
+[10:36:00.400] 10 1
+`
+
+func TestLapCountMismatch(t *testing.T) {
+	log := &recordLogger{}
+	cfg := testConfig(t, `{"laps": 3}`)
+	cfg.Logger = log
+	rows := runRace(t, cfg, lapRecord).Results()
+
+	surplus := resultOf(t, rows, "1")
+	if !surplus.Finished || surplus.NotFinished || surplus.TotalTime != 36*time.Minute || len(surplus.Laps) != 3 {
+		t.Errorf("участник 1: время %s, кругов %d, ожидалось 36m0s и 3", surplus.TotalTime, len(surplus.Laps))
+	}
+	if !log.contains("лишнее окончание круга участника 1 в [10:36:00.400] после финиша в 10:36:00.000") {
+		t.Errorf("нет предупреждения о лишнем окончании круга: %q", log.warnings)
+	}
+
+	short := resultOf(t, rows, "2")
+	if !short.NotFinished || short.Comment != "Данные неполны: завершено кругов 2 из 3" {
+		t.Errorf("участник 2: NotFinished = %v, комментарий %q, ожидались неполные данные", short.NotFinished, short.Comment)
+	}
+}
+
+func TestRepeatedStartDraw(t *testing.T) {
+	lines := `
+[09:05:00.000] 1 1
+[09:10:00.000] 2 1 10:00:00.000
+[09:11:00.000] 2 1 10:01:30.000
+`
+	log := &recordLogger{}
+	cfg := testConfig(t, "")
+	cfg.Logger = log
+	r := New(cfg)
+	for _, ev := range parseEvents(t, lines) {
+		if err := r.Apply(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !log.contains("время старта 10:00:00.000 заменено на 10:01:30.000") {
+		t.Errorf("нет предупреждения о повторной жеребьёвке: %q", log.warnings)
+	}
+
+	cfg = testConfig(t, "")
+	cfg.Strict = true
+	r = New(cfg)
+	var err error
+	for _, ev := range parseEvents(t, lines) {
+		if err = r.Apply(ev); err != nil {
+			break
+		}
+	}
+	if err == nil || !strings.Contains(err.Error(), "повторная жеребьёвка участника 1") {
+		t.Errorf("strict: ошибка %v, ожидалась ошибка повторной жеребьёвки", err)
+	}
+}
+
+func TestStartWithoutDraw(t *testing.T) {
+	// Жеребьёвка потеряна: старт не считается опозданием, время гонки
+	// отсчитывается от фактического старта
+	lines := `
+[09:05:00.000] 1 1
+[10:30:00.000] 4 1
+[10:50:00.000] 10 1
+`
+	log := &recordLogger{}
+	cfg := testConfig(t, `{"laps": 1}`)
+	cfg.Logger = log
+	row := resultOf(t, runRace(t, cfg, lines).Results(), "1")
+
+	if row.NotStarted || !row.Finished || row.TotalTime != 20*time.Minute {
+		t.Errorf("участник 1: %+v, ожидался финиш со временем 20m0s", row)
+	}
+	if !log.contains("участник 1 стартовал без времени старта по жеребьёвке") {
+		t.Errorf("нет предупреждения о старте без жеребьёвки: %q", log.warnings)
+	}
+}
+
+func TestNegativeIntervalStrict(t *testing.T) {
+	tests := map[string]string{
+		"круг": `
+[09:05:00.000] 1 1
+[09:10:00.000] 2 1 10:00:00.000
+[10:00:01.000] 4 1
+[10:00:00.500] 10 1
+`,
+		"штрафной круг": `
+[09:05:00.000] 1 1
+[09:10:00.000] 2 1 10:00:00.000
+[10:00:01.000] 4 1
+[10:20:00.000] 8 1
+[10:19:00.000] 9 1
+`,
+	}
+	for what, lines := range tests {
+		cfg := testConfig(t, "")
+		cfg.Strict = true
+		r := New(cfg)
+		events := parseEvents(t, lines)
+		for _, ev := range events[:len(events)-1] {
+			if err := r.Apply(ev); err != nil {
+				t.Fatal(err)
+			}
+		}
+		rows, n := r.Standings()
+		before := fmt.Sprintf("%d %+v", n, rows)
+
+		err := r.Apply(events[len(events)-1])
+		if err == nil || !strings.Contains(err.Error(), "участник 1: "+what+" заканчивается раньше, чем начинается") {
+			t.Errorf("%s: ошибка %v, ожидалась ошибка отрицательного интервала", what, err)
+		}
+		// Отклонённое событие не должно оставлять следов в таблице
+		rows, n = r.Standings()
+		if after := fmt.Sprintf("%d %+v", n, rows); after != before {
+			t.Errorf("%s: таблица изменилась после отклонённого события:\n%s\nожидалось\n%s", what, after, before)
+		}
+	}
+}
+
+func TestTargetsPerLineRange(t *testing.T) {
+	cfg := testConfig(t, `{"targetsPerLine": 4}`)
+	r := New(cfg)
+	for _, ev := range parseEvents(t, duplicateSensorRecord)[:4] {
+		if err := r.Apply(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Apply(parseEvents(t, "[10:10:01.000] 6 1 4")[0]); err != nil {
+		t.Errorf("мишень 4: %v", err)
+	}
+	if err := r.Apply(parseEvents(t, "[10:10:02.000] 6 1 5")[0]); err == nil || !strings.Contains(err.Error(), "вне диапазона 1..4") {
+		t.Errorf("мишень 5: ошибка %v, ожидалась ошибка номера мишени", err)
+	}
+}
+
+func TestMassStartDisqualifications(t *testing.T) {
+	// Участник 2 ушёл со старта на 2 минуты позже жеребьёвки, участник 3 — за
+	// 2 секунды до выстрела
+	lines := `
+[09:05:00.000] 1 1
+[09:05:01.000] 1 2
+[09:05:02.000] 1 3
+[09:10:00.000] 2 1 10:00:00.000
+[09:10:01.000] 2 2 10:00:00.000
+[09:10:02.000] 2 3 10:00:00.000
+[09:59:58.000] 4 3
+[10:00:01.000] 4 1
+[10:02:00.000] 4 2
+`
+	// Для каждого участника — комментарий дисквалификации, пустой — допущен
+	tests := []struct {
+		raceType string
+		dsq      map[string]string
+	}{
+		{"sprint", map[string]string{"1": "", "2": "Дисквалифицирован: старт после допустимого времени", "3": ""}},
+		{"massstart", map[string]string{"1": "", "2": "", "3": "Дисквалифицирован: фальстарт"}},
+	}
+	for _, tt := range tests {
+		rows := runRace(t, testConfig(t, `{"raceType": "`+tt.raceType+`"}`), lines).Results()
+		for id, want := range tt.dsq {
+			row := resultOf(t, rows, id)
+			if want == "" && (row.NotStarted || strings.HasPrefix(row.Comment, "Дисквалифицирован")) {
+				t.Errorf("%s: участник %s дисквалифицирован: %s", tt.raceType, id, row.Comment)
+			}
+			if want != "" && (!row.NotStarted || row.Comment != want) {
+				t.Errorf("%s: участник %s: комментарий %q, ожидалась дисквалификация %q", tt.raceType, id, row.Comment, want)
+			}
+		}
+	}
+}
+
+func TestStartDrawFormats(t *testing.T) {
+	tests := []struct {
+		extra string
+		want  string // время старта; пустое — ошибка разбора
+	}{
+		{"[10:00:00.000]", "10:00:00.000"},
+		{"10:00:00.000", "10:00:00.000"},
+		{" [10:01:30.000] ", "10:01:30.000"},
+		{"10:01:30", "10:01:30.000"},
+		{"[10:00:00.000", ""},
+		{"10 часов", ""},
+	}
+	for _, tt := range tests {
+		r := New(testConfig(t, ""))
+		if err := r.Apply(parseEvents(t, "[09:05:00.000] 1 1")[0]); err != nil {
+			t.Fatal(err)
+		}
+		draw := parseEvents(t, "[09:10:00.000] 2 1 "+tt.extra)[0]
+		err := r.Apply(draw)
+		if tt.want == "" {
+			if err == nil || !strings.Contains(err.Error(), "Ошибка парсинга времени старта") {
+				t.Errorf("%q: ошибка %v, ожидалась ошибка разбора времени старта", tt.extra, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.extra, err)
+			continue
+		}
+		if got := r.stats["1"].startTime.Format(parser.TimeFormat); got != tt.want {
+			t.Errorf("%q: время старта %s, ожидалось %s", tt.extra, got, tt.want)
+		}
+	}
+}
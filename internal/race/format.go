@@ -0,0 +1,112 @@
+package race
+
+import (
+	"fmt"
+	"time"
+
+	"biathlon_system/parser"
+)
+
+// Форматы гонки, параметр raceType
+const (
+	RaceSprint     = "sprint" // раздельный старт, штрафные круги за промахи
+	RaceIndividual = "individual"
+	RacePursuit    = "pursuit"
+	RaceMassStart  = "massstart"
+	RaceRelay      = "relay"
+	// RaceSuperSprint — квалификация с раздельным стартом и финал лучших
+	// Qualifiers участников с общим стартом
+	RaceSuperSprint = "supersprint"
+)
+
+// raceRules — правила формата гонки: как считается время гонки, чем
+// наказываются промахи и как распределяются места
+type raceRules struct {
+	// penaltyLoops — за промахи положены штрафные круги
+	penaltyLoops bool
+	// missPenalty — за каждый промах к времени гонки прибавляется
+	// Config.MissPenalty
+	missPenalty bool
+	// finishOrder — места по порядку пересечения финиша, а не по времени гонки
+	finishOrder bool
+	// sharedStart — все стартуют одновременно во время старта гонки
+	sharedStart bool
+	// relay — эстафета: первые этапы стартуют по выстрелу, остальные — по
+	// передаче эстафеты событием 12
+	relay bool
+	// shootingDNF — незакрытые мишени на рубеже означают сход
+	shootingDNF bool
+}
+
+var raceFormats = map[string]raceRules{
+	RaceSprint:     {penaltyLoops: true},
+	RaceIndividual: {missPenalty: true},
+	RacePursuit:    {penaltyLoops: true, finishOrder: true},
+	RaceMassStart:  {penaltyLoops: true, finishOrder: true, sharedStart: true},
+	RaceRelay:      {penaltyLoops: true, relay: true},
+	// Квалификация суперспринта; правила финала — superSprintFinal
+	RaceSuperSprint: {penaltyLoops: true},
+}
+
+// raceTypeAliases — другие названия форматов в конфигурации
+var raceTypeAliases = map[string]string{
+	"interval": RaceSprint,
+}
+
+// parseRaceType проверяет значение raceType и приводит его к одному из
+// форматов Race*
+func parseRaceType(s string) (string, error) {
+	if alias, ok := raceTypeAliases[s]; ok {
+		s = alias
+	}
+	if _, ok := raceFormats[s]; !ok {
+		return "", fmt.Errorf("raceType: ожидается %s, %s, %s, %s, %s или %s, получено %q", RaceSprint, RaceIndividual, RacePursuit, RaceMassStart, RaceRelay, RaceSuperSprint, s)
+	}
+	return s, nil
+}
+
+// rules возвращает правила формата гонки; без raceType — правила спринта.
+// Гонка суперспринта с отобранными участниками — финал.
+func (c Config) rules() raceRules {
+	if c.RaceType == RaceSuperSprint && c.Qualified != nil {
+		return superSprintFinal
+	}
+	if rules, ok := raceFormats[c.RaceType]; ok {
+		return rules
+	}
+	return raceFormats[RaceSprint]
+}
+
+// rankTime — время, по которому ранжируются финишировавшие: время гонки
+// или, в форматах с порядком финиша, момент пересечения финиша
+func (s *competitorStat) rankTime(cfg Config) time.Duration {
+	if cfg.rules().finishOrder {
+		return s.finishTime.Sub(cfg.Start)
+	}
+	return s.totalTime
+}
+
+// checkFalseStart дисквалифицирует участника масс-старта, пересёкшего
+// стартовую линию раньше выстрела больше чем на FalseStartTolerance
+func checkFalseStart(c *Competitor, ev parser.Event) {
+	cfg, stat := c.cfg, c.stat
+	gun := cfg.Start.Add(-cfg.FalseStartTolerance)
+	if !stat.actualStart.Before(gun) {
+		return
+	}
+	stat.notStarted = true
+	stat.comment = "Дисквалифицирован: фальстарт"
+	cfg.log().Warnf("Участник %s дисквалифицирован: фальстарт (%s < %s).", c.ID, stat.actualStart.Format(parser.TimeFormat), cfg.Start.Format(parser.TimeFormat))
+}
+
+// missPenalty — штраф временем за промахи финишировавшего участника
+func (s *competitorStat) missPenalty(cfg Config) time.Duration {
+	if !cfg.rules().missPenalty || s.finishTime.IsZero() {
+		return 0
+	}
+	misses := 0
+	for _, hits := range s.boutHits(cfg.FiringLines) {
+		misses += cfg.Targets - hits
+	}
+	return time.Duration(misses) * cfg.MissPenalty
+}
@@ -0,0 +1,95 @@
+package race
+
+import (
+	"slices"
+
+	"biathlon_system/parser"
+)
+
+// Hook получает событие ev участника id и snapshot — копию строки итоговой
+// таблицы участника после обработки события, без места и отставания
+type Hook func(id string, ev parser.Event, snapshot Result)
+
+// Hooks — обработчики событий участника, например для табло комментатора.
+// Вызываются синхронно из Apply после обработки события, но вне блокировки
+// гонки, так что из обработчика можно запрашивать Results. Паника в
+// обработчике записывается в журнал и не прерывает обработку событий.
+type Hooks struct {
+	OnStart          Hook // старт, событие 4
+	OnLapComplete    Hook // окончание круга, событие 10
+	OnFinish         Hook // окончание последнего круга
+	OnPenaltyEntered Hook // заход на штрафной круг, событие 8
+	OnPenaltyLeft    Hook // выход со штрафного круга, событие 9
+	OnDisqualify     Hook // дисквалификация за опоздание на старт
+	OnCannotContinue Hook // участник не может продолжать, событие 11
+}
+
+// hookCall — отложенный до снятия блокировки вызов обработчика
+type hookCall struct {
+	name     string
+	hook     Hook
+	ev       parser.Event
+	snapshot Result
+}
+
+// hookCalls возвращает вызовы обработчиков для события ev: before —
+// состояние участника до его обработки
+func (r *Race) hookCalls(ev parser.Event, before competitorStat) []hookCall {
+	hooks := r.cfg.Hooks
+	stat, ok := r.stats[ev.CompetitorID]
+	if hooks == nil || !ok {
+		return nil
+	}
+
+	var calls []hookCall
+	add := func(name string, hook Hook) {
+		if hook == nil {
+			return
+		}
+		snapshot := stat.result(ev.CompetitorID, r.cfg.forCompetitor(ev.CompetitorID))
+		snapshot.Laps = slices.Clone(snapshot.Laps)
+		snapshot.Penalties = slices.Clone(snapshot.Penalties)
+		snapshot.RangeVisits = slices.Clone(snapshot.RangeVisits)
+		calls = append(calls, hookCall{name: name, hook: hook, ev: ev, snapshot: snapshot})
+	}
+
+	switch ev.ID {
+	case 4, 12:
+		// Повторный старт игнорируется и обработчики не вызывает
+		if len(before.lapsTime) == 0 && len(stat.lapsTime) > 0 {
+			add("OnStart", hooks.OnStart)
+		}
+		if !before.notStarted && stat.notStarted {
+			add("OnDisqualify", hooks.OnDisqualify)
+		}
+	case 8:
+		add("OnPenaltyEntered", hooks.OnPenaltyEntered)
+	case 9:
+		add("OnPenaltyLeft", hooks.OnPenaltyLeft)
+	case 10:
+		// Лишнее окончание круга после финиша игнорируется
+		if before.finishTime.IsZero() {
+			add("OnLapComplete", hooks.OnLapComplete)
+		}
+		if before.finishTime.IsZero() && !stat.finishTime.IsZero() {
+			add("OnFinish", hooks.OnFinish)
+		}
+	case 11:
+		add("OnCannotContinue", hooks.OnCannotContinue)
+	}
+	return calls
+}
+
+// runHooks вызывает обработчики; паника одного из них не мешает остальным
+func (r *Race) runHooks(calls []hookCall) {
+	for _, call := range calls {
+		func() {
+			defer func() {
+				if p := recover(); p != nil {
+					r.cfg.log().Errorf("Паника в обработчике %s участника %s: %v", call.name, call.ev.CompetitorID, p)
+				}
+			}()
+			call.hook(call.ev.CompetitorID, call.ev, call.snapshot)
+		}()
+	}
+}
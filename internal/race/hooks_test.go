@@ -0,0 +1,75 @@
+package race
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"biathlon_system/parser"
+)
+
+func TestHooksSequence(t *testing.T) {
+	// Участник 1 проходит штрафной круг и финиширует, участник 2 сходит
+	lines := `
+[09:05:00.000] 1 1
+[09:05:01.000] 1 2
+[09:10:00.000] 2 1 10:00:00.000
+[09:10:01.000] 2 2 10:01:30.000
+[10:00:01.000] 4 1
+[10:01:31.000] 4 2
+[10:10:20.000] 8 1
+[10:12:00.000] 9 1
+[10:13:00.000] 10 1
+[10:14:00.000] 10 2
+[10:20:00.000] 11 2 Broken ski
+[10:26:00.000] 10 1
+`
+	var calls []string
+	record := func(name string) Hook {
+		return func(id string, ev parser.Event, snapshot Result) {
+			calls = append(calls, fmt.Sprintf("%s %s %d laps=%d", name, id, ev.ID, len(snapshot.Laps)))
+			// Изменение копии не должно затрагивать состояние гонки
+			if len(snapshot.Laps) > 0 {
+				snapshot.Laps[0].Time = time.Hour
+			}
+		}
+	}
+	log := &recordLogger{}
+	cfg := testConfig(t, "")
+	cfg.Logger = log
+	cfg.Hooks = &Hooks{
+		OnStart:          record("OnStart"),
+		OnLapComplete:    record("OnLapComplete"),
+		OnFinish:         record("OnFinish"),
+		OnPenaltyEntered: record("OnPenaltyEntered"),
+		OnPenaltyLeft:    record("OnPenaltyLeft"),
+		OnDisqualify:     record("OnDisqualify"),
+		OnCannotContinue: func(id string, ev parser.Event, snapshot Result) {
+			record("OnCannotContinue")(id, ev, snapshot)
+			panic("табло недоступно")
+		},
+	}
+	r := runRace(t, cfg, lines)
+
+	want := []string{
+		"OnStart 1 4 laps=1",
+		"OnStart 2 4 laps=1",
+		"OnPenaltyEntered 1 8 laps=1",
+		"OnPenaltyLeft 1 9 laps=1",
+		"OnLapComplete 1 10 laps=2",
+		"OnLapComplete 2 10 laps=2",
+		"OnCannotContinue 2 11 laps=2",
+		"OnLapComplete 1 10 laps=2",
+		"OnFinish 1 10 laps=2",
+	}
+	if got := strings.Join(calls, "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("вызовы обработчиков:\n%s\nожидалось:\n%s", got, strings.Join(want, "\n"))
+	}
+	if !log.contains("Паника в обработчике OnCannotContinue участника 2: табло недоступно") {
+		t.Errorf("паника обработчика не записана в журнал: %q", log.warnings)
+	}
+	if row := resultOf(t, r.Results(), "1"); row.TotalTime != 26*time.Minute || row.Laps[0].Time == time.Hour {
+		t.Errorf("участник 1: время %s, первый круг %s — обработчик изменил состояние гонки", row.TotalTime, row.Laps[0].Time)
+	}
+}
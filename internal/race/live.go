@@ -0,0 +1,66 @@
+package race
+
+import (
+	"sort"
+	"time"
+)
+
+// LiveResult — строка промежуточной таблицы по ходу гонки
+type LiveResult struct {
+	Place    int
+	ID       string
+	Finished bool
+	Laps     int // завершённых кругов
+	// Elapsed — время гонки финишировавшего или время на отметке последнего
+	// завершённого круга
+	Elapsed time.Duration
+}
+
+// LiveStandings рассчитывает промежуточную таблицу по строкам итоговой
+// таблицы rows: финишировавшие — по местам, за ними участники на дистанции
+// по числу завершённых кругов и времени на последней отметке. Не стартовавшие
+// и сошедшие в таблицу не попадают.
+func LiveStandings(rows []Result) []LiveResult {
+	var finished, running []LiveResult
+	for _, row := range rows {
+		switch row.Status() {
+		case "Finished":
+			if row.Place > 0 {
+				finished = append(finished, LiveResult{ID: row.ID, Finished: true, Laps: len(row.Laps), Elapsed: row.TotalTime})
+			}
+		case "Running":
+			if len(row.Laps) == 0 {
+				continue // ещё не стартовал
+			}
+			live := LiveResult{ID: row.ID}
+			for _, lap := range row.Laps {
+				if lap.Incomplete || lap.Invalid {
+					continue
+				}
+				live.Laps++
+				live.Elapsed += lap.Time
+			}
+			running = append(running, live)
+		}
+	}
+
+	sort.SliceStable(running, func(i, j int) bool {
+		if running[i].Laps != running[j].Laps {
+			return running[i].Laps > running[j].Laps
+		}
+		if running[i].Elapsed != running[j].Elapsed {
+			return running[i].Elapsed < running[j].Elapsed
+		}
+		return LessCompetitorID(running[i].ID, running[j].ID)
+	})
+
+	standings := append(finished, running...)
+	for i := range standings {
+		standings[i].Place = i + 1
+		if i > 0 && standings[i-1].Finished == standings[i].Finished &&
+			standings[i-1].Laps == standings[i].Laps && standings[i-1].Elapsed == standings[i].Elapsed {
+			standings[i].Place = standings[i-1].Place
+		}
+	}
+	return standings
+}
@@ -0,0 +1,86 @@
+package race
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// lapsOf — завершённые круги со временем times и, если open, незавершённый
+// круг после них
+func lapsOf(open bool, times ...time.Duration) []LapResult {
+	var laps []LapResult
+	for _, lap := range times {
+		laps = append(laps, LapResult{Time: lap})
+	}
+	if open {
+		laps = append(laps, LapResult{Incomplete: true})
+	}
+	return laps
+}
+
+func TestLiveStandings(t *testing.T) {
+	rows := []Result{
+		{ID: "1", Finished: true, Place: 1, TotalTime: 25 * time.Minute, Laps: lapsOf(false, 12*time.Minute, 13*time.Minute)},
+		{ID: "2", Finished: true, Place: 2, TotalTime: 26 * time.Minute, Laps: lapsOf(false, 13*time.Minute, 13*time.Minute)},
+		// На дистанции: больше кругов — выше, при равном числе — меньше время
+		{ID: "3", Laps: lapsOf(true, 12*time.Minute)},
+		{ID: "4", Laps: lapsOf(true, 11*time.Minute)},
+		{ID: "5", Laps: lapsOf(true)},
+		// Ещё не стартовал, не стартовал и сошёл — вне таблицы
+		{ID: "6"},
+		{ID: "7", NotStarted: true},
+		{ID: "8", NotFinished: true, Laps: lapsOf(true, 10*time.Minute)},
+	}
+
+	var got []string
+	for _, live := range LiveStandings(rows) {
+		got = append(got, fmt.Sprintf("%d:%s:%d:%s", live.Place, live.ID, live.Laps, live.Elapsed))
+	}
+	want := "1:1:2:25m0s 2:2:2:26m0s 3:4:1:11m0s 4:3:1:12m0s 5:5:0:0s"
+	if strings.Join(got, " ") != want {
+		t.Errorf("промежуточная таблица %s, ожидалось %s", strings.Join(got, " "), want)
+	}
+}
+
+func TestLiveStandingsTie(t *testing.T) {
+	// Равные круги и время делят место, порядок — по номеру участника;
+	// следующий получает место по счёту
+	rows := []Result{
+		{ID: "10", Laps: lapsOf(true, 12*time.Minute)},
+		{ID: "9", Laps: lapsOf(true, 12*time.Minute)},
+		{ID: "3", Laps: lapsOf(true, 12*time.Minute+time.Second)},
+	}
+
+	var got []string
+	for _, live := range LiveStandings(rows) {
+		got = append(got, fmt.Sprintf("%d:%s", live.Place, live.ID))
+	}
+	if want := "1:9 1:10 3:3"; strings.Join(got, " ") != want {
+		t.Errorf("промежуточная таблица %s, ожидалось %s", strings.Join(got, " "), want)
+	}
+}
+
+func TestLiveStandingsMidRace(t *testing.T) {
+	// Участник 2 закрыл первый круг, участник 1 ещё на нём
+	lines := `
+[09:05:00.000] 1 1
+[09:05:01.000] 1 2
+[09:10:00.000] 2 1 10:00:00.000
+[09:10:01.000] 2 2 10:01:30.000
+[10:00:01.000] 4 1
+[10:01:31.000] 4 2
+[10:13:00.000] 10 2
+`
+	r := New(testConfig(t, ""))
+	for _, ev := range parseEvents(t, lines) {
+		if err := r.Apply(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	standings := LiveStandings(r.Results())
+	if len(standings) != 2 || standings[0].ID != "2" || standings[0].Laps != 1 || standings[1].ID != "1" || standings[1].Laps != 0 {
+		t.Errorf("промежуточная таблица %+v, ожидались участник 2 с одним кругом и участник 1 без кругов", standings)
+	}
+}
@@ -0,0 +1,28 @@
+package race
+
+import "github.com/sirupsen/logrus"
+
+// Logger — журнал, в который гонка пишет ход обработки событий и
+// замечания к данным
+type Logger interface {
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// NopLogger отбрасывает все сообщения, например при использовании пакета
+// как библиотеки
+type NopLogger struct{}
+
+func (NopLogger) Infof(string, ...interface{})  {}
+func (NopLogger) Warnf(string, ...interface{})  {}
+func (NopLogger) Errorf(string, ...interface{}) {}
+
+// log возвращает журнал гонки: Options.Logger или, если он не задан,
+// глобальный журнал logrus
+func (c Config) log() Logger {
+	if c.Logger == nil {
+		return logrus.StandardLogger()
+	}
+	return c.Logger
+}
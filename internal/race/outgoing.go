@@ -0,0 +1,62 @@
+package race
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"biathlon_system/parser"
+)
+
+// Исходящие события, которые формирует система
+const (
+	eventDisqualified = 32 // Участник дисквалифицирован
+	eventFinished     = 33 // Участник финишировал
+)
+
+// OutgoingEvent — исходящее событие в формате входного потока:
+// [time] eventID competitorID
+type OutgoingEvent struct {
+	time       time.Time
+	timeStr    string
+	id         int
+	competitor string
+}
+
+func (e OutgoingEvent) String() string {
+	return e.timeStr + " " + strconv.Itoa(e.id) + " " + e.competitor
+}
+
+// emitOutgoing добавляет исходящие события, вызванные событием ev: before —
+// состояние участника до его обработки
+func (r *Race) emitOutgoing(ev parser.Event, before competitorStat) {
+	stat, ok := r.stats[ev.CompetitorID]
+	if !ok {
+		return
+	}
+
+	out := OutgoingEvent{time: parser.WithDate(ev.Time, r.cfg.Start), timeStr: ev.TimeStr, competitor: ev.CompetitorID}
+	switch {
+	case ev.ID == 4 && !before.notStarted && stat.notStarted:
+		out.id = eventDisqualified
+		r.cfg.log().Infof("%s The competitor(%s) is disqualified", ev.TimeStr, ev.CompetitorID)
+	case ev.ID == 10 && before.finishTime.IsZero() && !stat.finishTime.IsZero():
+		out.id = eventFinished
+		r.cfg.log().Infof("%s The competitor(%s) has finished", ev.TimeStr, ev.CompetitorID)
+	default:
+		return
+	}
+	r.outgoing = append(r.outgoing, out)
+}
+
+// Outgoing возвращает исходящие события гонки в хронологическом порядке
+func (r *Race) Outgoing() []OutgoingEvent {
+	r.mu.Lock()
+	events := append([]OutgoingEvent(nil), r.outgoing...)
+	r.mu.Unlock()
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].time.Before(events[j].time)
+	})
+	return events
+}
@@ -0,0 +1,45 @@
+package race
+
+import (
+	"time"
+
+	"biathlon_system/parser"
+)
+
+// pursuitStart — время старта участника id в гонке преследования: старт
+// гонки плюс отставание в квалификации, округлённое до GapPrecision.
+// ok = false, если участника нет в Options.PursuitBasis.
+func (c Config) pursuitStart(id string) (start time.Time, ok bool) {
+	gap, ok := c.PursuitBasis[id]
+	if !ok {
+		return time.Time{}, false
+	}
+	if c.GapPrecision > 0 {
+		gap = gap.Round(c.GapPrecision)
+	}
+	return c.Start.Add(gap), true
+}
+
+// checkPursuitDraw сверяет время старта из события 2 с отставанием участника
+// в квалификации. Время старта остаётся рассчитанным по квалификации.
+func checkPursuitDraw(c *Competitor, ev parser.Event, drawn time.Time) {
+	expected, _ := c.cfg.pursuitStart(c.ID)
+	if !drawn.Equal(expected) {
+		c.cfg.log().Warnf("Строка %d: время старта участника %s %s не совпадает с отставанием в квалификации, ожидалось %s",
+			ev.Line, c.ID, drawn.Format(parser.TimeFormat), expected.Format(parser.TimeFormat))
+	}
+}
+
+// addPursuitNonStarters добавляет участников квалификации, от которых не было
+// ни одного события: в итоговой таблице они не стартовавшие
+func (r *Race) addPursuitNonStarters() {
+	for id := range r.cfg.PursuitBasis {
+		if _, ok := r.stats[id]; ok {
+			continue
+		}
+		start, _ := r.cfg.pursuitStart(id)
+		stat := &competitorStat{startTime: start}
+		stat.computeResults(r.cfg)
+		r.stats[id] = stat
+	}
+}
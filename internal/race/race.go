@@ -0,0 +1,607 @@
+// Package race ведёт состояние гонки по входным событиям и рассчитывает
+// строки итоговой таблицы
+package race
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"biathlon_system/parser"
+)
+
+type Config struct {
+	RaceType    string // формат гонки, одна из констант Race*
+	Laps        int
+	LapLen      int
+	PenaltyLen  int
+	FiringLines int
+	Targets     int // мишеней на огневом рубеже
+	// FiringSchedule — номера кругов со стрельбой по возрастанию; nil —
+	// FiringLines рубежей без привязки к кругам
+	FiringSchedule []int
+	// Teams — команды эстафеты с участниками в порядке этапов; в остальных
+	// форматах — команды для командного зачёта
+	Teams []Team
+	// TeamScorers — сколько лучших участников команды идут в командный зачёт
+	TeamScorers int
+	// Points — очки за места начиная с 1-го; nil — без очков
+	Points []int
+	// Legs — параметры этапов эстафеты по номеру этапа, отличающиеся от
+	// общих, например в смешанной эстафете
+	Legs map[int]Leg
+	// CheckPenalties — после рубежа с промахами положен заход на штрафной
+	// круг; отключается для форматов, где промахи не переводятся в штрафные круги
+	CheckPenalties bool
+	// PenaltyLoopsPerMiss — штрафных кругов за промах; 0 — штрафные круги не
+	// положены, заходы на них записываются, но не проверяются
+	PenaltyLoopsPerMiss int
+	Start               time.Time
+	StartDelta          time.Duration  // допустимое опоздание на старт относительно жеребьёвки
+	IDPattern           *regexp.Regexp // шаблон ID участников; nil — только числовые ID
+	// TotalTimeBase — от чего отсчитывается время гонки: TotalTimeScheduled
+	// или TotalTimeActual
+	TotalTimeBase string
+	TimeLimit     time.Duration // лимит времени на дистанции; 0 — без лимита
+	MissPenalty   time.Duration // штраф за промах в индивидуальной гонке
+	GapPrecision  time.Duration // округление отставаний для стартов гонки преследования
+	// FalseStartTolerance — насколько раньше выстрела масс-старта участник
+	// может пересечь стартовую линию без фальстарта
+	FalseStartTolerance time.Duration
+	Qualifiers          int       // участников финала суперспринта
+	FinalStart          time.Time // старт финала суперспринта; нулевое — Start
+	// Options — параметры обработки из параметров запуска, общие для всех гонок
+	Options
+}
+
+// Options — параметры обработки событий, не зависящие от конфигурации гонки
+type Options struct {
+	// Strict — режим -mode strict: противоречивые повторы событий, например
+	// повторная жеребьёвка, считаются ошибкой, а не предупреждением
+	Strict bool
+	// AllowUnregistered отключает карантин событий участников без события
+	// регистрации (параметр -allow-unregistered)
+	AllowUnregistered bool
+	// EnforcePenalties дисквалифицирует участников, пропустивших положенный
+	// заход на штрафной круг (параметр -enforce-penalties)
+	EnforcePenalties bool
+	// StrictOfficiating отмечает результат предварительным, если заходы на
+	// штрафной круг не сходятся с промахами (параметр -strict-officiating)
+	StrictOfficiating bool
+	// IgnoreRaceStart отключает проверку событий по времени старта гонки из
+	// конфигурации, например для тренировок (параметр -ignore-race-start)
+	IgnoreRaceStart bool
+	// Logger — журнал обработки; nil — глобальный журнал logrus
+	Logger Logger
+	// Clock — часы для проверок по настоящему времени; nil — системные часы
+	Clock Clock
+	// Hooks — обработчики событий участника; nil — без обработчиков
+	Hooks *Hooks
+	// Handlers — обработчики дополнительных событий системы хронометража по
+	// ID, например погоды или отметок телекамер; заменяют встроенные
+	// обработчики тех же ID. Не изменяется во время обработки событий.
+	Handlers map[int]Handler
+	// PursuitBasis — отставания участников от победителя квалификации для
+	// гонки преследования (параметр -pursuit-basis); nil — без квалификации
+	PursuitBasis map[string]time.Duration
+	// Qualified — участники финала суперспринта с результатами квалификации
+	// (параметр -final); nil — не финал
+	Qualified map[string]Qualification
+	// QualifyTop — сколько лучших финишировавших отмечаются прошедшими
+	// квалификацию (параметр -qualify-top); 0 — без отметки
+	QualifyTop int
+}
+
+const (
+	TotalTimeScheduled = "scheduled"
+	TotalTimeActual    = "actual"
+)
+
+// CompetitorID проверяет ID участника и приводит его к каноническому виду.
+// Числовые ID записываются без ведущих нулей, чтобы "03" и "3" были одним
+// участником.
+func (c Config) CompetitorID(id string) (string, error) {
+	if c.IDPattern != nil {
+		if !c.IDPattern.MatchString(id) {
+			return "", fmt.Errorf("ID участника %q не соответствует шаблону %s", id, c.IDPattern)
+		}
+		return id, nil
+	}
+
+	if id == "" || strings.Trim(id, "0123456789") != "" {
+		return "", fmt.Errorf("ID участника %q должен быть числом", id)
+	}
+	if id = strings.TrimLeft(id, "0"); id == "" {
+		id = "0"
+	}
+	return id, nil
+}
+
+// LessCompetitorID сравнивает ID участников: числовые — как числа
+func LessCompetitorID(a, b string) bool {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	if errA == nil && errB == nil && na != nb {
+		return na < nb
+	}
+	return a < b
+}
+
+// Set разделяет поток событий с идентификаторами гонок на отдельные
+// гонки. События без идентификатора относятся к гонке по умолчанию "".
+type Set struct {
+	// mu защищает races и order от проверки лимита времени в режиме -follow
+	mu      sync.Mutex
+	configs Configs
+	races   map[string]*Race
+	order   []string
+}
+
+func NewSet(configs Configs) *Set {
+	return &Set{configs: configs, races: make(map[string]*Race)}
+}
+
+func (s *Set) Apply(ev parser.Event) error {
+	s.mu.Lock()
+	race, ok := s.races[ev.Race]
+	if !ok {
+		race = New(s.configs.ForRace(ev.Race))
+		s.races[ev.Race] = race
+		s.order = append(s.order, ev.Race)
+	}
+	s.mu.Unlock()
+
+	return race.Apply(ev)
+}
+
+// IDs возвращает идентификаторы гонок в порядке их появления в потоке
+func (s *Set) IDs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.order...)
+}
+
+// Race возвращает гонку raceID, а если событий этой гонки не было — пустую
+// гонку с её параметрами
+func (s *Set) Race(raceID string) *Race {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if race, ok := s.races[raceID]; ok {
+		return race
+	}
+	return New(s.configs.ForRace(raceID))
+}
+
+// CheckTimeLimits снимает с дистанции участников всех гонок, превысивших
+// лимит времени к моменту now
+func (s *Set) CheckTimeLimits(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, raceID := range s.order {
+		s.races[raceID].checkTimeLimit(now)
+	}
+}
+
+// WatchTimeLimits каждые interval по часам Options.Clock проверяет лимит
+// времени всех гонок, пока не закрыт done
+func (s *Set) WatchTimeLimits(interval time.Duration, done <-chan struct{}) {
+	clock := s.configs.Base.clock()
+	for {
+		select {
+		case <-done:
+			return
+		case <-clock.After(interval):
+			s.CheckTimeLimits(clock.Now())
+		}
+	}
+}
+
+// firingLap сообщает, предусмотрена ли расписанием стрельба на круге lap
+func (c Config) firingLap(lap int) bool {
+	for _, n := range c.FiringSchedule {
+		if n == lap {
+			return true
+		}
+	}
+	return false
+}
+
+// checksPenalties сообщает, сверяются ли заходы на штрафной круг с промахами.
+// В форматах со штрафом временем штрафные круги не проверяются.
+func (c Config) checksPenalties() bool {
+	return c.CheckPenalties && c.PenaltyLoopsPerMiss > 0 && c.rules().penaltyLoops
+}
+
+// checkRaceStart проверяет, что событие гонки произошло не раньше её
+// официального старта. Регистрация, жеребьёвка и выход на стартовую линию
+// допустимы и до старта.
+func (c Config) checkRaceStart(ev parser.Event) error {
+	if c.IgnoreRaceStart || ev.ID <= 3 {
+		return nil
+	}
+	if ev.ID == 4 && (c.rules().sharedStart || c.rules().relay) {
+		// Ранний старт в масс-старте — фальстарт, см. checkFalseStart
+		return nil
+	}
+	if !parser.WithDate(ev.Time, c.Start).Before(c.Start) {
+		return nil
+	}
+
+	msg := fmt.Sprintf("событие раньше старта гонки %s, событие: %s", c.Start.Format(parser.TimeFormat), ev.Raw)
+	if c.Strict {
+		return errors.New(msg)
+	}
+	c.log().Warnf("Строка %d: %s", ev.Line, msg)
+	return nil
+}
+
+// checkInterval проверяет, что интервал, закрытый событием ev, не
+// отрицателен. В отчёте такой интервал выводится как недействительный,
+// в строгом режиме он считается ошибкой данных.
+func (c Config) checkInterval(ev parser.Event, what string, interval [2]time.Time) error {
+	if interval[0].IsZero() || !interval[1].Before(interval[0]) {
+		return nil
+	}
+
+	msg := fmt.Sprintf("участник %s: %s заканчивается раньше, чем начинается (%s-%s), событие: %s",
+		ev.CompetitorID, what, interval[0].Format(parser.TimeFormat), interval[1].Format(parser.TimeFormat), ev.Raw)
+	if c.Strict {
+		return errors.New(msg)
+	}
+	c.log().Warnf("Строка %d: %s", ev.Line, msg)
+	return nil
+}
+
+// Race — состояние гонки, общее для всех источников событий.
+// Источники, принимающие события конкурентно, обращаются к нему через Apply.
+type Race struct {
+	mu    sync.Mutex
+	cfg   Config
+	stats map[string]*competitorStat
+	// quarantined — число отклонённых событий незарегистрированных участников
+	quarantined map[string]int
+	outgoing    []OutgoingEvent
+	lastTime    time.Time // время самого позднего события гонки
+	applied     int       // обработанных событий
+}
+
+func New(cfg Config) *Race {
+	return &Race{
+		cfg:         cfg,
+		stats:       make(map[string]*competitorStat),
+		quarantined: make(map[string]int),
+	}
+}
+
+// Config возвращает параметры гонки
+func (r *Race) Config() Config {
+	return r.cfg
+}
+
+func (r *Race) Apply(ev parser.Event) error {
+	r.mu.Lock()
+	calls, err := r.apply(ev)
+	r.mu.Unlock()
+
+	r.runHooks(calls)
+	return err
+}
+
+// apply применяет событие под блокировкой гонки и возвращает вызовы
+// обработчиков Hooks, которые нужно сделать после её снятия
+func (r *Race) apply(ev parser.Event) ([]hookCall, error) {
+	id, err := r.cfg.CompetitorID(ev.CompetitorID)
+	if err != nil {
+		return nil, fmt.Errorf("%s, событие: %s", err, ev.Raw)
+	}
+	ev.CompetitorID = id
+
+	// Опечатка в ID участника не должна порождать в отчёте лишнего спортсмена
+	if stat, ok := r.stats[ev.CompetitorID]; !r.cfg.AllowUnregistered && ev.ID != 1 && (!ok || !stat.registered) {
+		r.quarantined[ev.CompetitorID]++
+		r.cfg.log().Warnf("Строка %d: событие участника %s без регистрации отклонено, событие: %s", ev.Line, ev.CompetitorID, ev.Raw)
+		return nil, nil
+	}
+	if _, ok := r.cfg.Qualified[ev.CompetitorID]; r.cfg.Qualified != nil && !ok {
+		r.cfg.log().Warnf("Строка %d: участник %s не прошёл в финал, событие отклонено: %s", ev.Line, ev.CompetitorID, ev.Raw)
+		return nil, nil
+	}
+
+	if t := parser.WithDate(ev.Time, r.cfg.Start); r.lastTime.IsZero() || t.After(r.lastTime) {
+		r.lastTime = t
+	}
+
+	var before competitorStat
+	if stat, ok := r.stats[ev.CompetitorID]; ok {
+		before = *stat
+	}
+	if err := r.handleEvent(ev); err != nil {
+		return nil, err
+	}
+	r.applied++
+	if stat, ok := r.stats[ev.CompetitorID]; ok {
+		stat.computeResults(r.cfg.forCompetitor(ev.CompetitorID))
+	}
+	r.emitOutgoing(ev, before)
+	r.markMissedStarts(parser.WithDate(ev.Time, r.cfg.Start))
+	return r.hookCalls(ev, before), nil
+}
+
+// markMissedStarts отмечает как не стартовавших участников, чьё время старта
+// по жеребьёвке с учётом допустимого опоздания уже прошло к моменту now
+func (r *Race) markMissedStarts(now time.Time) {
+	if r.cfg.rules().sharedStart || r.cfg.rules().relay {
+		// В масс-старте и эстафете опоздавшие стартуют с общего старта или
+		// передачи эстафеты, а не по жеребьёвке
+		return
+	}
+	for id, stat := range r.stats {
+		if stat.notStarted || stat.startTime.IsZero() || len(stat.lapsTime) > 0 {
+			continue
+		}
+		deadline := stat.startTime.Add(r.cfg.StartDelta)
+		if now.After(deadline) {
+			stat.notStarted = true
+			stat.comment = "Не стартовал: нет события старта до " + deadline.Format(parser.TimeFormat)
+			r.cfg.log().Warnf("Участник %s не стартовал до %s", id, deadline.Format(parser.TimeFormat))
+		}
+	}
+}
+
+// Finalize подводит итог гонки: участники без события старта отмечаются как
+// не стартовавшие, а участники, у которых записано меньше кругов, чем задано
+// в конфигурации, — как не финишировавшие
+func (r *Race) Finalize() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.addPursuitNonStarters()
+	r.addQualifiedNonStarters()
+	for _, id := range r.competitorIDs() {
+		stat := r.stats[id]
+		if len(stat.lapsTime) == 0 && !stat.notStarted {
+			stat.notStarted = true
+			stat.comment = "Не стартовал: нет события старта"
+			if stat.startTime.IsZero() {
+				stat.comment = "Не стартовал: время старта не назначено"
+			}
+			r.cfg.log().Warnf("Участник %s: %s", id, stat.comment)
+		}
+		if open := stat.openPenalties(); open > 0 {
+			r.cfg.log().Warnf("Участник %s: незавершённых заходов на штрафной круг %d, в суммарное штрафное время они не входят", id, open)
+		}
+		if len(stat.lapsTime) == 0 || stat.notStarted || stat.notFinished {
+			continue
+		}
+		if r.exceedsTimeLimit(id, stat, r.lastTime) {
+			continue
+		}
+		// В эстафете число кругов может зависеть от этапа
+		laps := r.cfg.forCompetitor(id).Laps
+		if completed := stat.completedLaps(); completed < laps {
+			stat.notFinished = true
+			stat.comment = fmt.Sprintf("Данные неполны: завершено кругов %d из %d", completed, laps)
+			r.cfg.log().Warnf("Участник %s: завершено кругов %d из %d, данные отмечены как неполные", id, completed, laps)
+			continue
+		}
+
+		if r.cfg.TotalTimeBase == TotalTimeScheduled && stat.startTime.IsZero() && stat.exchangeFrom == "" {
+			r.cfg.log().Warnf("Участник %s: нет времени старта по жеребьёвке, время гонки отсчитывается от фактического старта", id)
+		}
+
+		if !r.cfg.checksPenalties() {
+			continue
+		}
+		stat.provisional = r.checkBoutPenalties(id, stat) && r.cfg.StrictOfficiating
+		if skied, owed := stat.loopsSkied(), stat.loopsOwed(r.cfg); skied != owed {
+			r.cfg.log().Warnf("ВНИМАНИЕ: участник %s прошёл штрафных кругов %d, положено по числу промахов: %d", id, skied, owed)
+			if r.cfg.EnforcePenalties && skied < owed {
+				stat.notFinished = true
+				stat.comment = fmt.Sprintf("Дисквалифицирован: штрафных кругов %d из %d", skied, owed)
+			}
+		}
+	}
+	r.checkExchanges()
+	r.checkQualifyQuota()
+}
+
+// checkQualifyQuota предупреждает, что из-за равного времени на последнем
+// проходном месте квалификацию прошло больше QualifyTop участников
+func (r *Race) checkQualifyQuota() {
+	if r.cfg.QualifyTop == 0 {
+		return
+	}
+	if qualified := Qualify(computeStandings(r.stats, r.cfg), r.cfg.QualifyTop); len(qualified) > r.cfg.QualifyTop {
+		r.cfg.log().Warnf("Квалификацию прошли %d участников при квоте %d: равное время на %d-м месте", len(qualified), r.cfg.QualifyTop, r.cfg.QualifyTop)
+	}
+}
+
+// checkTimeLimit снимает с дистанции участников, превысивших лимит времени
+// к моменту now по часам: в режиме -follow лимит срабатывает, не дожидаясь
+// следующего события
+func (r *Race) checkTimeLimit(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	clock := time.Date(0, 1, 1, now.Hour(), now.Minute(), now.Second(), now.Nanosecond(), time.UTC)
+	for _, id := range r.competitorIDs() {
+		r.exceedsTimeLimit(id, r.stats[id], parser.WithDate(clock, r.cfg.Start))
+	}
+}
+
+// exceedsTimeLimit отмечает как не финишировавшего участника, который всё
+// ещё на дистанции и находится на ней дольше лимита к моменту now
+func (r *Race) exceedsTimeLimit(id string, stat *competitorStat, now time.Time) bool {
+	if r.cfg.TimeLimit == 0 || len(stat.lapsTime) == 0 || stat.notStarted || stat.notFinished || !stat.finishTime.IsZero() {
+		return false
+	}
+	if now.Sub(stat.raceStart(r.cfg)) <= r.cfg.TimeLimit {
+		return false
+	}
+
+	stat.notFinished = true
+	stat.comment = "Превышен лимит времени"
+	r.cfg.log().Warnf("Участник %s: превышен лимит времени %s, участник снят с дистанции", id, time.Time{}.Add(r.cfg.TimeLimit).Format(parser.TimeFormat))
+	return true
+}
+
+// checkBoutPenalties сверяет штрафные круги после каждого рубежа с
+// промахами на нём и сообщает, найдены ли расхождения. Штрафной круг без
+// промахов или сверх положенного указывает на сбой датчика или ошибку
+// судейства; промахи без штрафного круга и недостающие круги проверяются
+// только в режиме -strict-officiating.
+func (r *Race) checkBoutPenalties(id string, stat *competitorStat) bool {
+	mismatch := false
+	for i, bout := range stat.bouts {
+		misses, owed, skied := bout.misses(r.cfg.Targets), bout.loopsOwed(r.cfg), bout.loopsSkied()
+		loops := make([]string, 0, len(bout.penalties))
+		for _, idx := range bout.penalties {
+			loops = append(loops, formatInterval(stat.penaltyTime[idx]))
+		}
+		switch {
+		case misses == 0 && len(bout.penalties) > 0:
+			r.cfg.log().Warnf("Штрафной круг без промахов на рубеже: участник %s, рубеж %d (огневой рубеж %d), штрафные круги %s",
+				id, i+1, bout.firingRange, strings.Join(loops, ", "))
+			mismatch = true
+		case skied > owed:
+			r.cfg.log().Warnf("Штрафных кругов больше, чем положено: участник %s, рубеж %d (огневой рубеж %d), промахов %d, положено штрафных кругов %d, пройдено %d (%s)",
+				id, i+1, bout.firingRange, misses, owed, skied, strings.Join(loops, ", "))
+			mismatch = true
+		case owed > 0 && len(bout.penalties) == 0 && r.cfg.StrictOfficiating:
+			r.cfg.log().Warnf("Промахи на рубеже без захода на штрафной круг: участник %s, рубеж %d (огневой рубеж %d), промахов %d, положено штрафных кругов %d",
+				id, i+1, bout.firingRange, misses, owed)
+			mismatch = true
+		case skied < owed && r.cfg.StrictOfficiating:
+			r.cfg.log().Warnf("Штрафных кругов меньше, чем положено: участник %s, рубеж %d (огневой рубеж %d), промахов %d, положено штрафных кругов %d, пройдено %d (%s)",
+				id, i+1, bout.firingRange, misses, owed, skied, strings.Join(loops, ", "))
+			mismatch = true
+		}
+	}
+	return mismatch
+}
+
+// formatInterval выводит интервал как начало-конец; отсутствующая отметка
+// остаётся пустой
+func formatInterval(interval [2]time.Time) string {
+	var start, end string
+	if !interval[0].IsZero() {
+		start = interval[0].Format(parser.TimeFormat)
+	}
+	if !interval[1].IsZero() {
+		end = interval[1].Format(parser.TimeFormat)
+	}
+	return start + "-" + end
+}
+
+// LogDataIssues выводит в лог проблемы данных, замеченные за гонку:
+// отклонённые события незарегистрированных участников и пропущенные
+// финишировавшими участниками огневые рубежи
+func (r *Race) LogDataIssues() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.quarantined) > 0 {
+		ids := make([]string, 0, len(r.quarantined))
+		for id, n := range r.quarantined {
+			ids = append(ids, fmt.Sprintf("%s (%d)", id, n))
+		}
+		sort.Strings(ids)
+		r.cfg.log().Warnf("Отклонены события незарегистрированных участников: %s", strings.Join(ids, ", "))
+	}
+
+	for _, id := range r.competitorIDs() {
+		stat := r.stats[id]
+		if stat.finishTime.IsZero() || stat.notStarted || stat.notFinished {
+			continue
+		}
+		if missed := missedRanges(stat.bouts, r.cfg.forCompetitor(id).FiringLines); len(missed) > 0 {
+			r.cfg.log().Warnf("Участник %s финишировал, не пройдя огневые рубежи %v", id, missed)
+		}
+	}
+}
+
+// competitorIDs возвращает ID участников в порядке сортировки
+func (r *Race) competitorIDs() []string {
+	ids := make([]string, 0, len(r.stats))
+	for id := range r.stats {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return LessCompetitorID(ids[i], ids[j])
+	})
+	return ids
+}
+
+// missedRanges возвращает номера рубежей 1..firingLines, на которых не было
+// ни одного посещения. Посещения неизвестного рубежа могли быть на любом из
+// них, поэтому рубежи считаются пропущенными, только если их больше.
+func missedRanges(bouts []shootingBout, firingLines int) []int {
+	seen := make(map[int]bool, len(bouts))
+	unknown := 0
+	for _, bout := range bouts {
+		if bout.firingRange == 0 {
+			unknown++
+		}
+		seen[bout.firingRange] = true
+	}
+	var missed []int
+	for n := 1; n <= firingLines; n++ {
+		if !seen[n] {
+			missed = append(missed, n)
+		}
+	}
+	if len(missed) <= unknown {
+		return nil
+	}
+	return missed
+}
+
+// SequenceGap проверяет, согласуется ли событие с уже полученными событиями
+// участника. Используется источниками, которые могут терять события.
+// skip означает, что событие обработать нельзя.
+func (r *Race) SequenceGap(ev parser.Event) (gap string, skip bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if id, err := r.cfg.CompetitorID(ev.CompetitorID); err == nil {
+		ev.CompetitorID = id
+	}
+	stat, ok := r.stats[ev.CompetitorID]
+	switch {
+	case ev.ID == 10 && (!ok || len(stat.lapsTime) == 0):
+		return "окончание круга без события старта", true
+	case ev.ID == 9 && (!ok || len(stat.penaltyTime) == 0 || !stat.penaltyTime[len(stat.penaltyTime)-1][1].IsZero()):
+		return "выход со штрафного круга без входа на него", true
+	case ev.ID != 1 && !ok:
+		return "нет события регистрации", false
+	case (ev.ID == 5 || ev.ID == 8) && len(stat.lapsTime) == 0:
+		return "событие на дистанции без события старта", false
+	}
+
+	return "", false
+}
+
+// Results возвращает текущие строки таблицы результатов
+func (r *Race) Results() []Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return computeStandings(r.stats, r.cfg)
+}
+
+// Standings возвращает строки итоговой таблицы и число обработанных событий
+// гонки одним согласованным снимком
+func (r *Race) Standings() ([]Result, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return computeStandings(r.stats, r.cfg), r.applied
+}
@@ -0,0 +1,338 @@
+package race
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"biathlon_system/parser"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// testConfigJSON — параметры гонки из примера README
+const testConfigJSON = `{
+	"laps": 2,
+	"lapLen": 3500,
+	"penaltyLen": 150,
+	"firingLines": 2,
+	"start": "10:00:00.000",
+	"startDelta": "00:01:30"
+}`
+
+// testConfigs загружает параметры гонок из JSON так же, как из файла
+// конфигурации; extra дополняет или заменяет параметры testConfigJSON
+func testConfigs(t *testing.T, extra string) Configs {
+	t.Helper()
+
+	v := viper.New()
+	v.SetConfigType("json")
+	if err := v.ReadConfig(strings.NewReader(testConfigJSON)); err != nil {
+		t.Fatal(err)
+	}
+	if extra != "" {
+		if err := v.MergeConfig(strings.NewReader(extra)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configs, err := LoadConfigs(v)
+	if err != nil {
+		t.Fatalf("LoadConfigs: %v", err)
+	}
+	return configs.WithOptions(Options{Logger: NopLogger{}})
+}
+
+// testConfig — параметры гонки по умолчанию из testConfigs
+func testConfig(t *testing.T, extra string) Config {
+	t.Helper()
+	return testConfigs(t, extra).Base
+}
+
+// recordLogger запоминает сообщения журнала для проверки в тестах
+type recordLogger struct {
+	mu       sync.Mutex
+	warnings []string
+}
+
+func (l *recordLogger) Infof(string, ...interface{}) {}
+
+func (l *recordLogger) Warnf(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warnings = append(l.warnings, fmt.Sprintf(format, args...))
+}
+
+func (l *recordLogger) Errorf(format string, args ...interface{}) {
+	l.Warnf(format, args...)
+}
+
+// contains сообщает, есть ли предупреждение, содержащее substr
+func (l *recordLogger) contains(substr string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, w := range l.warnings {
+		if strings.Contains(w, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseEvents разбирает строки событий в формате входного файла
+func parseEvents(t *testing.T, lines string) []parser.Event {
+	t.Helper()
+
+	var events []parser.Event
+	for n, line := range strings.Split(strings.TrimSpace(lines), "\n") {
+		ev, err := parser.ParseEvent(strings.TrimSpace(line))
+		if err != nil {
+			t.Fatalf("строка %d: %v", n+1, err)
+		}
+		ev.Line = n + 1
+		events = append(events, ev)
+	}
+	return events
+}
+
+// runRace применяет события к новой гонке и подводит её итог
+func runRace(t *testing.T, cfg Config, lines string) *Race {
+	t.Helper()
+
+	r := New(cfg)
+	for _, ev := range parseEvents(t, lines) {
+		if err := r.Apply(ev); err != nil {
+			t.Fatalf("Apply(%s): %v", ev.Raw, err)
+		}
+	}
+	r.Finalize()
+	return r
+}
+
+// resultOf возвращает строку таблицы участника id
+func resultOf(t *testing.T, rows []Result, id string) Result {
+	t.Helper()
+
+	for _, row := range rows {
+		if row.ID == id {
+			return row
+		}
+	}
+	t.Fatalf("нет строки участника %s", id)
+	return Result{}
+}
+
+// penaltyRecord — 3/5 на первом рубеже с одним заходом на штрафной круг и
+// 4/5 на втором с двумя заходами
+const penaltyRecord = `
+[09:05:00.000] 1 1
+[09:10:00.000] 2 1 10:00:00.000
+[09:59:00.000] 3 1
+[10:00:01.000] 4 1
+[10:10:00.000] 5 1 1
+[10:10:01.000] 6 1 1
+[10:10:02.000] 6 1 2
+[10:10:03.000] 6 1 3
+[10:10:10.000] 7 1
+[10:10:20.000] 8 1
+[10:12:00.000] 9 1
+[10:13:00.000] 10 1
+[10:23:00.000] 5 1 2
+[10:23:01.000] 6 1 1
+[10:23:02.000] 6 1 2
+[10:23:03.000] 6 1 3
+[10:23:04.000] 6 1 4
+[10:23:10.000] 7 1
+[10:23:20.000] 8 1
+[10:24:10.000] 9 1
+[10:24:15.000] 8 1
+[10:25:05.000] 9 1
+[10:26:00.000] 10 1
+`
+
+func TestPenaltyLoopsPerMiss(t *testing.T) {
+	tests := []struct {
+		perMiss     int
+		skied, owed int
+		// extra и short — предупреждения о лишних и недостающих кругах
+		extra, short bool
+	}{
+		// Без штрафных кругов заходы записываются, но не проверяются
+		{perMiss: 0, skied: 3, owed: -1},
+		// В сумме круги сходятся, но после первого рубежа круга не хватает,
+		// а после второго он лишний
+		{perMiss: 1, skied: 3, owed: 3, extra: true, short: true},
+		// Каждый заход — один круг: 1 из 4 после первого рубежа и 2 из 2 после второго
+		{perMiss: 2, skied: 3, owed: 6, short: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("perMiss=%d", tt.perMiss), func(t *testing.T) {
+			log := &recordLogger{}
+			cfg := testConfig(t, fmt.Sprintf(`{"penaltyLoopsPerMiss": %d}`, tt.perMiss))
+			cfg.Logger = log
+			cfg.StrictOfficiating = true
+
+			row := resultOf(t, runRace(t, cfg, penaltyRecord).Results(), "1")
+			if row.PenaltySkied != tt.skied || row.PenaltyOwed != tt.owed {
+				t.Errorf("штрафные круги %d/%d, ожидалось %d/%d", row.PenaltySkied, row.PenaltyOwed, tt.skied, tt.owed)
+			}
+			if provisional := tt.extra || tt.short; row.Provisional != provisional {
+				t.Errorf("Provisional = %v, ожидалось %v", row.Provisional, provisional)
+			}
+			if warned := log.contains("Штрафных кругов больше"); warned != tt.extra {
+				t.Errorf("предупреждение о лишних кругах: %v, ожидалось %v; журнал: %q", warned, tt.extra, log.warnings)
+			}
+			if warned := log.contains("Штрафных кругов меньше"); warned != tt.short {
+				t.Errorf("предупреждение о недостающих кругах: %v, ожидалось %v; журнал: %q", warned, tt.short, log.warnings)
+			}
+		})
+	}
+}
+
+func TestEnforcePenaltiesCountsLoops(t *testing.T) {
+	// Один заход после рубежа с двумя промахами при двух кругах за промах —
+	// один круг из четырёх положенных
+	cfg := testConfig(t, `{"penaltyLoopsPerMiss": 2}`)
+	cfg.EnforcePenalties = true
+
+	lines := strings.Replace(penaltyRecord, "[10:24:15.000] 8 1\n[10:25:05.000] 9 1\n", "", 1)
+	lines = strings.Replace(lines, "[10:23:20.000] 8 1\n[10:24:10.000] 9 1\n", "", 1)
+	row := resultOf(t, runRace(t, cfg, lines).Results(), "1")
+	if !row.NotFinished || row.Comment != "Дисквалифицирован: штрафных кругов 1 из 6" {
+		t.Errorf("NotFinished = %v, комментарий %q", row.NotFinished, row.Comment)
+	}
+}
+
+func TestShortPenaltyFlagged(t *testing.T) {
+	// 3/5 на первом рубеже и один заход на штрафной круг вместо двух, второй
+	// рубеж чистый
+	lines := `
+[09:05:00.000] 1 1
+[09:10:00.000] 2 1 10:00:00.000
+[10:00:01.000] 4 1
+[10:10:00.000] 5 1 1
+[10:10:01.000] 6 1 1
+[10:10:02.000] 6 1 2
+[10:10:03.000] 6 1 3
+[10:10:10.000] 7 1
+[10:10:20.000] 8 1
+[10:11:10.000] 9 1
+[10:13:00.000] 10 1
+[10:23:00.000] 5 1 2
+[10:23:01.000] 6 1 1
+[10:23:02.000] 6 1 2
+[10:23:03.000] 6 1 3
+[10:23:04.000] 6 1 4
+[10:23:05.000] 6 1 5
+[10:23:10.000] 7 1
+[10:26:00.000] 10 1
+`
+	log := &recordLogger{}
+	cfg := testConfig(t, "")
+	cfg.Logger = log
+	cfg.StrictOfficiating = true
+	row := resultOf(t, runRace(t, cfg, lines).Results(), "1")
+	if row.PenaltySkied != 1 || row.PenaltyOwed != 2 || !row.Provisional || row.NotFinished {
+		t.Errorf("штрафные круги %d/%d, Provisional = %v, NotFinished = %v, ожидалось 1/2 и предварительный результат",
+			row.PenaltySkied, row.PenaltyOwed, row.Provisional, row.NotFinished)
+	}
+	if !log.contains("Штрафных кругов меньше, чем положено: участник 1, рубеж 1") || !log.contains("прошёл штрафных кругов 1, положено по числу промахов: 2") {
+		t.Errorf("нет предупреждения о недостающем круге: %q", log.warnings)
+	}
+
+	cfg = testConfig(t, "")
+	cfg.EnforcePenalties = true
+	row = resultOf(t, runRace(t, cfg, lines).Results(), "1")
+	if !row.NotFinished || row.Comment != "Дисквалифицирован: штрафных кругов 1 из 2" {
+		t.Errorf("-enforce-penalties: NotFinished = %v, комментарий %q", row.NotFinished, row.Comment)
+	}
+}
+
+func TestEventBeforeRaceStart(t *testing.T) {
+	// Старт за минуту до официального старта гонки в 10:00:00.000
+	lines := `
+[09:05:00.000] 1 1
+[09:10:00.000] 2 1 10:00:00.000
+[09:59:00.000] 4 1
+`
+	apply := func(cfg Config) error {
+		r := New(cfg)
+		for _, ev := range parseEvents(t, lines) {
+			if err := r.Apply(ev); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	log := &recordLogger{}
+	cfg := testConfig(t, "")
+	cfg.Logger = log
+	if err := apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !log.contains("Строка 3: событие раньше старта гонки 10:00:00.000") {
+		t.Errorf("нет предупреждения о событии до старта: %q", log.warnings)
+	}
+
+	cfg = testConfig(t, "")
+	cfg.Strict = true
+	if err := apply(cfg); err == nil || !strings.Contains(err.Error(), "раньше старта гонки") {
+		t.Errorf("strict: ошибка %v, ожидалась ошибка события до старта", err)
+	}
+
+	log = &recordLogger{}
+	cfg.Logger, cfg.IgnoreRaceStart = log, true
+	if err := apply(cfg); err != nil || log.contains("раньше старта гонки") {
+		t.Errorf("-ignore-race-start: ошибка %v, предупреждения %q", err, log.warnings)
+	}
+}
+
+func TestStartDrawBeforeRaceStart(t *testing.T) {
+	cfg := testConfig(t, "")
+	cfg.Strict = true
+	r := New(cfg)
+	events := parseEvents(t, "[09:05:00.000] 1 1\n[09:10:00.000] 2 1 09:59:00.000")
+	if err := r.Apply(events[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Apply(events[1]); err == nil || !strings.Contains(err.Error(), "по жеребьёвке 09:59:00.000 раньше старта гонки") {
+		t.Errorf("ошибка %v, ожидалась ошибка времени жеребьёвки", err)
+	}
+}
+
+func TestNopLoggerSilent(t *testing.T) {
+	var global bytes.Buffer
+	logrus.SetOutput(&global)
+	defer logrus.SetOutput(os.Stderr)
+
+	// Ленты с замечаниями к данным на каждом этапе обработки
+	process := func(cfg Config) {
+		for _, feed := range []string{penaltyRecord, duplicateSensorRecord, strayHitRecord, lapRecord, outOfRangeRecord} {
+			r := New(cfg)
+			for _, ev := range parseEvents(t, feed) {
+				r.Apply(ev)
+			}
+			r.Standings()
+			r.Finalize()
+			r.LogDataIssues()
+			r.Results()
+		}
+	}
+
+	process(testConfig(t, ""))
+	if global.Len() != 0 {
+		t.Errorf("с NopLogger записано в глобальный журнал:\n%s", global.String())
+	}
+
+	cfg := testConfig(t, "")
+	cfg.Logger = nil
+	process(cfg)
+	if global.Len() == 0 {
+		t.Error("без Logger нет записей в глобальном журнале")
+	}
+}
@@ -0,0 +1,249 @@
+package race
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"biathlon_system/parser"
+)
+
+// Team — команда эстафеты: участники в порядке этапов
+type Team struct {
+	Name    string
+	Members []string
+}
+
+// Leg — параметры этапа эстафеты; нулевые значения берутся из общих
+// параметров гонки
+type Leg struct {
+	Laps           int
+	LapLen         int
+	FiringSchedule []int
+}
+
+// forCompetitor возвращает параметры гонки участника id: в эстафете — с
+// параметрами его этапа из Legs
+func (c Config) forCompetitor(id string) Config {
+	if !c.rules().relay || len(c.Legs) == 0 {
+		return c
+	}
+	if _, leg, ok := c.teamOf(id); ok {
+		return c.forLeg(leg)
+	}
+	return c
+}
+
+// forLeg возвращает параметры гонки этапа n
+func (c Config) forLeg(n int) Config {
+	leg, ok := c.Legs[n]
+	if !ok {
+		return c
+	}
+	if leg.Laps > 0 {
+		c.Laps = leg.Laps
+	}
+	if leg.LapLen > 0 {
+		c.LapLen = leg.LapLen
+	}
+	if leg.FiringSchedule != nil {
+		c.FiringSchedule = leg.FiringSchedule
+		c.FiringLines = len(leg.FiringSchedule)
+	}
+	return c
+}
+
+// teamOf возвращает команду участника id и номер его этапа, начиная с 1
+func (c Config) teamOf(id string) (team string, leg int, ok bool) {
+	for _, t := range c.Teams {
+		for i, member := range t.Members {
+			if member == id {
+				return t.Name, i + 1, true
+			}
+		}
+	}
+	return "", 0, false
+}
+
+// handleExchange — событие 12: участник принял эстафету от участника
+// предыдущего этапа, ID которого указан в extraParams. Передача начинает
+// этап участника так же, как событие 4.
+func handleExchange(c *Competitor, ev parser.Event) error {
+	cfg, stat := c.cfg, c.stat
+	timeStr := ev.TimeStr
+	timeEv := parser.WithDate(ev.Time, cfg.Start)
+	idComp := c.ID
+
+	if strings.TrimSpace(ev.Extra) == "" {
+		return fmt.Errorf("не указан участник, передавший эстафету, событие: %s", ev.Raw)
+	}
+	from, err := cfg.CompetitorID(strings.TrimSpace(ev.Extra))
+	if err != nil {
+		return fmt.Errorf("%s, событие: %s", err, ev.Raw)
+	}
+	team, leg, ok := cfg.teamOf(idComp)
+	fromTeam, fromLeg, fromOK := cfg.teamOf(from)
+	if !ok || !fromOK || team != fromTeam || fromLeg != leg-1 {
+		cfg.log().Warnf("Строка %d: передача эстафеты участнику %s от %s не по порядку этапов команды, событие: %s", ev.Line, idComp, from, ev.Raw)
+	}
+	if len(stat.lapsTime) > 0 {
+		cfg.log().Warnf("Строка %d: повторная передача эстафеты участнику %s в %s проигнорирована, этап начат в %s", ev.Line, idComp, timeStr, stat.actualStart.Format(parser.TimeFormat))
+		return nil
+	}
+
+	stat.exchangeFrom = from
+	stat.actualStart = timeEv
+	stat.lapsTime = append(stat.lapsTime, [2]time.Time{timeEv})
+	cfg.log().Infof("%s The competitor(%s) took over from the competitor(%s)", timeStr, idComp, from)
+	return nil
+}
+
+// checkRelayStart проверяет событие 4 в эстафете: первый этап стартует по
+// выстрелу, остальные — по передаче эстафеты
+func checkRelayStart(c *Competitor, ev parser.Event) {
+	if _, leg, ok := c.cfg.teamOf(c.ID); ok && leg > 1 {
+		c.cfg.log().Warnf("Строка %d: старт участника %s %d-го этапа без передачи эстафеты", ev.Line, c.ID, leg)
+		return
+	}
+	checkFalseStart(c, ev)
+}
+
+// checkExchanges отмечает предварительным результат участника, принявшего
+// эстафету раньше, чем участник предыдущего этапа закончил последний круг
+func (r *Race) checkExchanges() {
+	for _, id := range r.competitorIDs() {
+		stat := r.stats[id]
+		if stat.exchangeFrom == "" {
+			continue
+		}
+		prev, ok := r.stats[stat.exchangeFrom]
+		if ok && !prev.finishTime.IsZero() && !prev.finishTime.After(stat.actualStart) {
+			continue
+		}
+		r.cfg.log().Warnf("Участник %s принял эстафету в %s до финиша последнего круга участника %s", id, stat.actualStart.Format(parser.TimeFormat), stat.exchangeFrom)
+		stat.provisional = true
+	}
+}
+
+// TeamResult — строка командной таблицы эстафеты
+type TeamResult struct {
+	Team string
+	Legs []string // участники по этапам
+	// Splits — результаты этапов в порядке Legs
+	Splits []LegSplit
+	// Place — место финишировавшей команды, 0 — без места
+	Place int
+	// TotalTime — от старта гонки до финиша последнего этапа
+	TotalTime time.Duration
+	Behind    time.Duration
+	LegsDone  int // этапов с финишем
+	Finished  bool
+	// NotFinished — участник одного из этапов не стартовал или не финишировал
+	NotFinished bool
+	Provisional bool
+	Comment     string
+}
+
+// LegSplit — результат этапа эстафеты
+type LegSplit struct {
+	ID       string
+	Finished bool
+	Time     time.Duration // от старта этапа до финиша
+	// Speed — средняя скорость по дистанции этапа с его штрафными кругами
+	Speed float64
+}
+
+// Status — состояние команды в таблице
+func (t TeamResult) Status() string {
+	switch {
+	case t.NotFinished:
+		return "NotFinished"
+	case !t.Finished:
+		return "Running"
+	default:
+		return "Finished"
+	}
+}
+
+// TeamStandings рассчитывает командную таблицу эстафеты по строкам итоговой
+// таблицы участников rows
+func TeamStandings(rows []Result, cfg Config) []TeamResult {
+	byID := make(map[string]Result, len(rows))
+	for _, row := range rows {
+		byID[row.ID] = row
+	}
+
+	teams := make([]TeamResult, 0, len(cfg.Teams))
+	for _, team := range cfg.Teams {
+		result := TeamResult{Team: team.Name, Legs: team.Members}
+		for i, id := range team.Members {
+			row, ok := byID[id]
+			split := LegSplit{ID: id}
+			switch {
+			case !ok || row.NotStarted || row.NotFinished:
+				result.NotFinished = true
+				if result.Comment == "" {
+					result.Comment = fmt.Sprintf("этап %d: %s", i+1, legComment(row, ok))
+				}
+			case row.Finished && !row.InvalidTotal:
+				result.LegsDone++
+				result.TotalTime = row.Finish.Sub(cfg.Start)
+				split = LegSplit{ID: id, Finished: true, Time: row.TotalTime, Speed: row.AvgSpeed}
+			}
+			result.Splits = append(result.Splits, split)
+			result.Provisional = result.Provisional || row.Provisional
+		}
+		result.Finished = !result.NotFinished && result.LegsDone == len(team.Members)
+		teams = append(teams, result)
+	}
+
+	sort.SliceStable(teams, func(i, j int) bool {
+		if rankI, rankJ := teams[i].rank(), teams[j].rank(); rankI != rankJ {
+			return rankI < rankJ
+		}
+		if teams[i].Finished && teams[i].TotalTime != teams[j].TotalTime {
+			return teams[i].TotalTime < teams[j].TotalTime
+		}
+		if teams[i].LegsDone != teams[j].LegsDone {
+			return teams[i].LegsDone > teams[j].LegsDone
+		}
+		return teams[i].Team < teams[j].Team
+	})
+	for i := range teams {
+		if !teams[i].Finished {
+			continue
+		}
+		teams[i].Place = i + 1
+		if i > 0 && teams[i-1].TotalTime == teams[i].TotalTime {
+			teams[i].Place = teams[i-1].Place
+		}
+		teams[i].Behind = teams[i].TotalTime - teams[0].TotalTime
+	}
+	return teams
+}
+
+func (t TeamResult) rank() int {
+	switch {
+	case t.NotFinished:
+		return rankNotFinished
+	case !t.Finished:
+		return rankRunning
+	default:
+		return rankFinished
+	}
+}
+
+// legComment — почему этап не засчитан
+func legComment(row Result, ok bool) string {
+	switch {
+	case !ok:
+		return "нет событий участника"
+	case row.Comment != "":
+		return row.ID + " — " + row.Comment
+	case row.NotStarted:
+		return row.ID + " не стартовал"
+	default:
+		return row.ID + " не финишировал"
+	}
+}
@@ -0,0 +1,188 @@
+package race
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// relayConfig — эстафета двух команд по два этапа: этап — один круг с одним
+// огневым рубежом
+const relayConfig = `{"raceType": "relay", "laps": 1, "firingLines": 1,
+	"teams": [{"name": "A", "members": [1, 2]}, {"name": "B", "members": [3, 4]}]}`
+
+// relayRecord — команда B первой передаёт эстафету, но команда A финиширует
+// на 30 секунд раньше; участник 1 промахнулся один раз, участник 4 — дважды
+const relayRecord = `
+[09:05:00.000] 1 1
+[09:05:01.000] 1 2
+[09:05:02.000] 1 3
+[09:05:03.000] 1 4
+[10:00:00.500] 4 1
+[10:00:00.700] 4 3
+[10:05:00.000] 5 1 1
+[10:05:02.000] 6 1 1
+[10:05:04.000] 6 1 2
+[10:05:06.000] 6 1 3
+[10:05:08.000] 6 1 4
+[10:05:10.000] 7 1
+[10:05:15.000] 8 1
+[10:05:40.000] 5 3 1
+[10:05:42.000] 6 3 1
+[10:05:44.000] 6 3 2
+[10:05:45.000] 9 1
+[10:05:46.000] 6 3 3
+[10:05:48.000] 6 3 4
+[10:05:50.000] 6 3 5
+[10:05:55.000] 7 3
+[10:11:30.000] 10 3
+[10:11:30.200] 12 4 3
+[10:12:00.000] 10 1
+[10:12:00.500] 12 2 1
+[10:17:00.000] 5 4 1
+[10:17:02.000] 6 4 1
+[10:17:04.000] 6 4 2
+[10:17:06.000] 6 4 3
+[10:17:10.000] 7 4
+[10:17:15.000] 8 4
+[10:17:30.000] 5 2 1
+[10:17:32.000] 6 2 1
+[10:17:34.000] 6 2 2
+[10:17:36.000] 6 2 3
+[10:17:38.000] 6 2 4
+[10:17:40.000] 6 2 5
+[10:17:45.000] 7 2
+[10:18:15.000] 9 4
+[10:24:00.000] 10 2
+[10:24:30.000] 10 4
+`
+
+func TestRelayTeamStandings(t *testing.T) {
+	r := runRace(t, testConfig(t, relayConfig), relayRecord)
+	rows := r.Results()
+
+	// Этап участника 2 начинается с передачи эстафеты, а не с выстрела
+	leg2 := resultOf(t, rows, "2")
+	if want := 11*time.Minute + 59500*time.Millisecond; leg2.TotalTime != want || leg2.Provisional {
+		t.Errorf("этап участника 2: %s, Provisional = %v, ожидалось %s", leg2.TotalTime, leg2.Provisional, want)
+	}
+
+	teams := TeamStandings(rows, r.Config())
+	if len(teams) != 2 {
+		t.Fatalf("команд %d, ожидалось 2", len(teams))
+	}
+	a, b := teams[0], teams[1]
+	if a.Team != "A" || a.Place != 1 || a.TotalTime != 24*time.Minute || a.LegsDone != 2 || a.Status() != "Finished" {
+		t.Errorf("первая команда %+v, ожидалась A на 1-м месте с временем 24m0s", a)
+	}
+	if b.Team != "B" || b.Place != 2 || b.TotalTime != 24*time.Minute+30*time.Second || b.Behind != 30*time.Second {
+		t.Errorf("вторая команда %+v, ожидалась B на 2-м месте с отставанием 30s", b)
+	}
+	var splits []string
+	for _, split := range a.Splits {
+		splits = append(splits, split.ID)
+	}
+	if !reflect.DeepEqual(splits, []string{"1", "2"}) || a.Splits[0].Time != 12*time.Minute || a.Splits[1].Time != leg2.TotalTime {
+		t.Errorf("этапы команды A %+v", a.Splits)
+	}
+}
+
+func TestRelayUnfinishedLeg(t *testing.T) {
+	// Без финиша участника 4 команда B на дистанции и без места
+	lines := relayRecord[:len(relayRecord)-len("[10:24:30.000] 10 4\n")]
+	r := New(testConfig(t, relayConfig))
+	for _, ev := range parseEvents(t, lines) {
+		if err := r.Apply(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	teams := TeamStandings(r.Results(), r.Config())
+	if b := teams[1]; b.Team != "B" || b.Status() != "Running" || b.Place != 0 || b.LegsDone != 1 {
+		t.Errorf("команда B %+v, ожидалась на дистанции после одного этапа", b)
+	}
+}
+
+func TestRelayEarlyExchange(t *testing.T) {
+	// Эстафета принята раньше финиша участника предыдущего этапа
+	log := &recordLogger{}
+	cfg := testConfig(t, relayConfig)
+	cfg.Logger = log
+	lines := strings.Replace(relayRecord, "[10:12:00.000] 10 1\n[10:12:00.500] 12 2 1\n", "[10:11:59.000] 12 2 1\n[10:12:00.000] 10 1\n", 1)
+	rows := runRace(t, cfg, lines).Results()
+	if !resultOf(t, rows, "2").Provisional || !log.contains("принял эстафету в 10:11:59.000 до финиша последнего круга участника 1") {
+		t.Errorf("ранняя передача не отмечена: %q", log.warnings)
+	}
+}
+
+func TestTeamsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "teams")
+	if err := os.WriteFile(path, []byte("# команды эстафеты\nA: 1 2\n\nB: 3, 4\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fromFile := testConfig(t, `{"raceType": "relay", "teamsFile": "`+path+`"}`).Teams
+	fromConfig := testConfig(t, relayConfig).Teams
+	if !reflect.DeepEqual(fromFile, fromConfig) {
+		t.Errorf("команды из файла %+v, из конфигурации %+v", fromFile, fromConfig)
+	}
+}
+
+func TestRelayLegOverrides(t *testing.T) {
+	// Второй этап короче: скорость его участников считается по 2000 м
+	short := strings.TrimSuffix(relayConfig, "}") + `, "legs": {"2": {"lapLen": 2000}}}`
+	r := runRace(t, testConfig(t, short), relayRecord)
+	teams := TeamStandings(r.Results(), r.Config())
+	leg2 := teams[0].Splits[1]
+	if want := 2000 / (11*time.Minute + 59500*time.Millisecond).Seconds(); leg2.ID != "2" || leg2.Speed != want {
+		t.Errorf("этап участника 2 %+v, ожидалась скорость %.3f", leg2, want)
+	}
+	if leg1 := teams[0].Splits[0]; leg1.Speed != (3500+150)/(12*time.Minute).Seconds() {
+		t.Errorf("этап участника 1 %+v, ожидалась скорость по общим параметрам", leg1)
+	}
+
+	// Число кругов сверяется с параметрами этапа, а не с общими
+	long := strings.TrimSuffix(relayConfig, "}") + `, "legs": {"2": {"laps": 2}}}`
+	rows := runRace(t, testConfig(t, long), relayRecord).Results()
+	if row := resultOf(t, rows, "2"); !row.NotFinished || row.Comment != "Данные неполны: завершено кругов 1 из 2" {
+		t.Errorf("участник 2 %+v, ожидались неполные данные: 1 круг из 2", row)
+	}
+	if row := resultOf(t, rows, "1"); !row.Finished {
+		t.Errorf("участник 1 %+v, ожидался финиш по общему числу кругов", row)
+	}
+}
+
+func TestTeamScores(t *testing.T) {
+	cfg := testConfig(t, `{"teamScorers": 2, "teams": [
+		{"name": "NOR", "members": [1, 2, 3]},
+		{"name": "GER", "members": [4, 5]},
+		{"name": "FRA", "members": [6, 7]}]}`)
+	// Строки уже в порядке мест; участник 8 без команды в зачёт не идёт
+	rows := []Result{
+		{ID: "4", Place: 1, TotalTime: 20 * time.Minute, Hits: 10, Shots: 10},
+		{ID: "1", Place: 2, TotalTime: 21 * time.Minute, Hits: 9, Shots: 10, PenaltyLoops: 1},
+		{ID: "8", Place: 3, TotalTime: 21 * time.Minute},
+		{ID: "2", Place: 4, TotalTime: 22 * time.Minute, Hits: 8, Shots: 10, PenaltyLoops: 2},
+		{ID: "5", Place: 5, TotalTime: 24 * time.Minute, Hits: 7, Shots: 10, PenaltyLoops: 3},
+		{ID: "3", Place: 6, TotalTime: 25 * time.Minute, Hits: 10, Shots: 10},
+		{ID: "6", Place: 7, TotalTime: 26 * time.Minute, Hits: 6, Shots: 10, PenaltyLoops: 4},
+		{ID: "7", NotFinished: true, Hits: 3, Shots: 5, PenaltyLoops: 2},
+	}
+
+	var got []string
+	for _, s := range TeamScores(rows, cfg) {
+		got = append(got, fmt.Sprintf("%d %s %v %s %s %d %d/%d %d %v",
+			s.Place, s.Team, s.Scorers, s.TotalTime, s.Behind, s.Finishers, s.Hits, s.Shots, s.PenaltyLoops, s.Complete))
+	}
+	want := []string{
+		"1 NOR [1 2] 43m0s 0s 3 27/30 3 true",
+		"2 GER [4 5] 44m0s 1m0s 2 17/20 3 true",
+		// Один финишировавший из двух в зачёте — без места
+		"0 FRA [6] 26m0s 0s 1 9/15 6 false",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("командный зачёт:\n%s\nожидалось\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
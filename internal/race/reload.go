@@ -0,0 +1,106 @@
+package race
+
+import (
+	"fmt"
+
+	"biathlon_system/parser"
+)
+
+// reloadParams — параметры гонки, которые сравниваются при перезагрузке
+// конфигурации. Параметры с apply влияют только на скорости и лимит времени
+// и меняются и во время гонки; остальные меняют саму гонку и применяются,
+// только пока событий не было.
+var reloadParams = []struct {
+	key   string
+	value func(Config) any
+	apply func(dst *Config, src Config)
+}{
+	{key: "raceType", value: func(c Config) any { return c.RaceType }},
+	{key: "laps", value: func(c Config) any { return c.Laps }},
+	{
+		key:   "lapLen",
+		value: func(c Config) any { return c.LapLen },
+		apply: func(dst *Config, src Config) { dst.LapLen = src.LapLen },
+	},
+	{
+		key:   "penaltyLen",
+		value: func(c Config) any { return c.PenaltyLen },
+		apply: func(dst *Config, src Config) { dst.PenaltyLen = src.PenaltyLen },
+	},
+	{key: "firingLines", value: func(c Config) any { return c.FiringLines }},
+	{key: "firingSchedule", value: func(c Config) any { return c.FiringSchedule }},
+	{key: "targetsPerLine", value: func(c Config) any { return c.Targets }},
+	{key: "checkPenalties", value: func(c Config) any { return c.CheckPenalties }},
+	{key: "penaltyLoopsPerMiss", value: func(c Config) any { return c.PenaltyLoopsPerMiss }},
+	{key: "start", value: func(c Config) any { return c.Start.Format(parser.TimeFormat) }},
+	{key: "startDelta", value: func(c Config) any { return c.StartDelta }},
+	{key: "missPenalty", value: func(c Config) any { return c.MissPenalty }},
+	{key: "gapPrecision", value: func(c Config) any { return c.GapPrecision }},
+	{key: "qualifiers", value: func(c Config) any { return c.Qualifiers }},
+	{key: "finalStart", value: func(c Config) any { return c.FinalStart.Format(parser.TimeFormat) }},
+	{key: "falseStartTolerance", value: func(c Config) any { return c.FalseStartTolerance }},
+	{key: "totalTimeBase", value: func(c Config) any { return c.TotalTimeBase }},
+	{
+		key:   "timeLimit",
+		value: func(c Config) any { return c.TimeLimit },
+		apply: func(dst *Config, src Config) { dst.TimeLimit = src.TimeLimit },
+	},
+	{key: "teams", value: func(c Config) any { return c.Teams }},
+	{key: "legs", value: func(c Config) any { return c.Legs }},
+	{key: "points", value: func(c Config) any { return c.Points }},
+	{key: "teamScorers", value: func(c Config) any { return c.TeamScorers }},
+	{key: "competitorIdPattern", value: func(c Config) any {
+		if c.IDPattern == nil {
+			return ""
+		}
+		return c.IDPattern.String()
+	}},
+}
+
+// Reconfigure применяет перечитанные параметры гонки. После первого события
+// меняются только длины кругов и лимит времени, изменение остальных
+// параметров отклоняется с предупреждением. Options гонки сохраняются.
+func (r *Race) Reconfigure(cfg Config) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg.Options = r.cfg.Options
+	started := !r.lastTime.IsZero()
+	next := r.cfg
+	if !started {
+		next = cfg
+	}
+	for _, param := range reloadParams {
+		old, updated := fmt.Sprint(param.value(r.cfg)), fmt.Sprint(param.value(cfg))
+		if old == updated {
+			continue
+		}
+		if started && param.apply == nil {
+			r.cfg.log().Warnf("Конфигурация: изменение %s с %s на %s отклонено, гонка уже идёт", param.key, old, updated)
+			continue
+		}
+		if started {
+			param.apply(&next, cfg)
+		}
+		r.cfg.log().Infof("Конфигурация: %s изменён с %s на %s", param.key, old, updated)
+	}
+
+	r.cfg = next
+	for id, stat := range r.stats {
+		stat.computeResults(r.cfg.forCompetitor(id))
+	}
+}
+
+// Reconfigure применяет перечитанные параметры ко всем гонкам, см.
+// Race.Reconfigure. Гонки, событий которых ещё не было, получат новые
+// параметры целиком.
+func (s *Set) Reconfigure(configs Configs) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	configs = configs.WithOptions(s.configs.Base.Options)
+	s.configs = configs
+	for _, raceID := range s.order {
+		s.races[raceID].Reconfigure(configs.ForRace(raceID))
+	}
+}
@@ -0,0 +1,340 @@
+package race
+
+import (
+	"maps"
+	"sort"
+	"time"
+)
+
+// LapResult — время и средняя скорость на круге; Incomplete означает,
+// что у круга нет начала или конца, Invalid — что конец раньше начала
+type LapResult struct {
+	Start      time.Time // начало интервала; нулевое — неизвестно
+	Time       time.Duration
+	Speed      float64
+	Incomplete bool
+	Invalid    bool
+}
+
+// Result — строка итоговой таблицы
+type Result struct {
+	ID          string
+	NotStarted  bool
+	NotFinished bool
+	Finished    bool
+	Finish      time.Time // время финиша; нулевое — нет финиша
+	TotalTime   time.Duration
+	// MissPenalty — штраф за промахи, уже включённый в TotalTime
+	MissPenalty time.Duration
+	Laps        []LapResult
+	Penalties   []LapResult
+	Hits        int
+	Shots       int
+	BoutHits    []int // попадания по посещениям рубежей в порядке посещения
+	// PenaltySkied/PenaltyOwed — пройденные и положенные по числу промахов
+	// штрафные круги; при отключённой проверке штрафных кругов или без
+	// штрафных кругов за промахи PenaltyOwed равен -1
+	PenaltySkied int
+	PenaltyOwed  int
+	Comment      string
+	Provisional  bool
+	// InvalidTotal — время финиша раньше времени старта
+	InvalidTotal bool
+	// Place — место финишировавшего участника, 0 — без места
+	Place int
+	// Behind — отставание финишировавшего участника от лидера
+	Behind time.Duration
+	// Qualified — место в пределах Options.QualifyTop
+	Qualified bool
+	// Points — очки за место по Config.Points; 0 — без места или вне таблицы
+	Points int
+	// PenaltyLoops/PenaltyTotal — штрафные круги в завершённых заходах на
+	// штрафной круг и суммарное время на нём
+	PenaltyLoops int
+	PenaltyTotal time.Duration
+	// RangeVisits/RangeTotal — время посещений огневых рубежей и их сумма
+	RangeVisits []LapResult
+	RangeTotal  time.Duration
+	// AvgSpeed — средняя скорость финишировавшего участника по всей
+	// дистанции с учётом пройденных штрафных кругов
+	AvgSpeed float64
+	// Notes — дополнительные данные участника из обработчиков Options.Handlers
+	Notes map[string]string
+	// Qualification — результат квалификации участника финала суперспринта;
+	// nil — не финал
+	Qualification *Qualification
+}
+
+// computeStandings рассчитывает строки итоговой таблицы в порядке ранжирования
+func computeStandings(competitorStats map[string]*competitorStat, cfg Config) []Result {
+	var competitorIDs []string
+	for id := range competitorStats {
+		competitorIDs = append(competitorIDs, id)
+	}
+
+	sort.Slice(competitorIDs, func(i, j int) bool {
+		statI := competitorStats[competitorIDs[i]]
+		statJ := competitorStats[competitorIDs[j]]
+		if rankI, rankJ := statI.rank(), statJ.rank(); rankI != rankJ {
+			return rankI < rankJ
+		}
+
+		if statI.rank() == rankFinished {
+			if timeI, timeJ := statI.rankTime(cfg), statJ.rankTime(cfg); timeI != timeJ {
+				return timeI < timeJ
+			}
+			// Фотофиниш: при равном времени выше тот, кто быстрее прошёл
+			// последний круг
+			if lastI, lastJ := statI.lastLapTime(), statJ.lastLapTime(); lastI != lastJ {
+				return lastI < lastJ
+			}
+		} else {
+			// Не финишировавшие — по пройденной дистанции
+			lapsI, lapsJ := statI.completedLaps(), statJ.completedLaps()
+			if lapsI != lapsJ {
+				return lapsI > lapsJ
+			}
+		}
+		// При равенстве порядок не должен зависеть от обхода map
+		return LessCompetitorID(competitorIDs[i], competitorIDs[j])
+	})
+
+	rows := make([]Result, 0, len(competitorIDs))
+	var leaderTime, prevTime time.Duration
+	for _, id := range competitorIDs {
+		stat := competitorStats[id]
+
+		row := stat.result(id, cfg.forCompetitor(id))
+		if stat.rank() == rankFinished {
+			rankTime := stat.rankTime(cfg)
+			// Равное время — одно место на всех, следующее место пропускается
+			row.Place = len(rows) + 1
+			if prev := len(rows) - 1; prev >= 0 && rows[prev].Place > 0 && prevTime == rankTime {
+				row.Place = rows[prev].Place
+			}
+			// Финишировавшие идут первыми, лидер — первая строка таблицы
+			if len(rows) == 0 {
+				leaderTime = rankTime
+			}
+			row.Behind = rankTime - leaderTime
+			row.Qualified = row.Place <= cfg.QualifyTop
+			if row.Place <= len(cfg.Points) {
+				row.Points = cfg.Points[row.Place-1]
+			}
+			prevTime = rankTime
+			legCfg := cfg.forCompetitor(id)
+			distance := legCfg.Laps*legCfg.LapLen + stat.penaltyLoops*legCfg.PenaltyLen
+			// Штраф за промахи — не время на дистанции
+			row.AvgSpeed = float64(distance) / (row.TotalTime - row.MissPenalty).Seconds()
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// result возвращает строку итоговой таблицы участника id без места и
+// отставания, которые зависят от остальных участников
+func (s *competitorStat) result(id string, cfg Config) Result {
+	row := Result{
+		ID:           id,
+		NotStarted:   s.notStarted,
+		NotFinished:  s.notFinished,
+		Finished:     !s.finishTime.IsZero(),
+		Finish:       s.finishTime,
+		TotalTime:    s.totalTime,
+		MissPenalty:  s.missPenalty(cfg),
+		InvalidTotal: s.invalidTotal(),
+		Hits:         s.hits(),
+		BoutHits:     s.boutHits(cfg.FiringLines),
+		Shots:        cfg.Targets * cfg.FiringLines,
+		Laps:         s.lapResults,
+		Penalties:    s.penaltyResults,
+		PenaltyLoops: s.penaltyLoops,
+		PenaltyTotal: s.penaltyTotal,
+		RangeVisits:  s.rangeResults,
+		RangeTotal:   s.rangeTotal,
+		Comment:      s.comment,
+		Provisional:  s.provisional,
+		Notes:        maps.Clone(s.notes),
+		PenaltySkied: s.loopsSkied(),
+		PenaltyOwed:  -1,
+	}
+	if cfg.checksPenalties() {
+		row.PenaltyOwed = s.loopsOwed(cfg)
+	}
+	if q, ok := cfg.Qualified[id]; ok {
+		row.Qualification = &q
+	}
+	return row
+}
+
+// computeResults пересчитывает время гонки, время и скорость кругов
+// участника по отметкам времени. Вызывается после каждого события
+// участника, поэтому отчёты и сортировка только читают готовые значения.
+func (s *competitorStat) computeResults(cfg Config) {
+	s.totalTime = raceTime(s, cfg)
+
+	// Новые срезы, а не переиспользование старых: строки таблицы, уже
+	// отданные живым отчётам, ссылаются на прежние результаты
+	s.lapResults = make([]LapResult, 0, len(s.lapsTime))
+	for _, lap := range s.lapsTime {
+		s.lapResults = append(s.lapResults, intervalResult(lap, cfg.LapLen))
+	}
+
+	s.penaltyResults = make([]LapResult, 0, len(s.penaltyTime))
+	s.penaltyLoops, s.penaltyTotal = 0, 0
+	for _, penalty := range s.penaltyTime {
+		result := intervalResult(penalty, cfg.PenaltyLen)
+		s.penaltyResults = append(s.penaltyResults, result)
+		if !result.Incomplete && !result.Invalid {
+			s.penaltyLoops++
+			s.penaltyTotal += result.Time
+		}
+	}
+
+	s.rangeResults = make([]LapResult, 0, len(s.bouts))
+	s.rangeTotal = 0
+	for _, bout := range s.bouts {
+		result := intervalResult(bout.interval, 0)
+		s.rangeResults = append(s.rangeResults, result)
+		if !result.Incomplete && !result.Invalid {
+			s.rangeTotal += result.Time
+		}
+	}
+}
+
+// Группы итоговой таблицы в порядке вывода
+const (
+	rankFinished = iota
+	rankRunning
+	rankInvalid
+	rankNotFinished
+	rankNotStarted
+)
+
+// rank — группа участника в итоговой таблице: финишировавшие выше всех,
+// не стартовавшие — в самом низу
+func (s *competitorStat) rank() int {
+	switch {
+	case s.notStarted:
+		return rankNotStarted
+	case s.notFinished:
+		return rankNotFinished
+	case s.invalidTotal():
+		return rankInvalid
+	case s.finishTime.IsZero():
+		return rankRunning
+	default:
+		return rankFinished
+	}
+}
+
+// completedLaps — число завершённых кругов
+func (s *competitorStat) completedLaps() int {
+	completed := 0
+	for _, lap := range s.lapsTime {
+		if !lap[1].IsZero() {
+			completed++
+		}
+	}
+	return completed
+}
+
+// boutHits — попадания на каждом посещении огневого рубежа в порядке
+// посещения. Финишировавшему участнику за каждый пропущенный рубеж
+// выводится 0 — перед посещением следующего за ним рубежа.
+func (s *competitorStat) boutHits(firingLines int) []int {
+	var missed []int
+	if !s.finishTime.IsZero() {
+		missed = missedRanges(s.bouts, firingLines)
+	}
+	hits := make([]int, 0, firingLines)
+	for _, bout := range s.bouts {
+		for len(missed) > 0 && bout.firingRange > missed[0] {
+			hits, missed = append(hits, 0), missed[1:]
+		}
+		hits = append(hits, len(bout.targets))
+	}
+	for range missed {
+		hits = append(hits, 0)
+	}
+	return hits
+}
+
+// openPenalties — число незавершённых и недействительных заходов на
+// штрафной круг
+func (s *competitorStat) openPenalties() int {
+	open := 0
+	for _, result := range s.penaltyResults {
+		if result.Incomplete || result.Invalid {
+			open++
+		}
+	}
+	return open
+}
+
+// lastLapTime — время последнего круга участника
+func (s *competitorStat) lastLapTime() time.Duration {
+	if len(s.lapResults) == 0 {
+		return 0
+	}
+	return s.lapResults[len(s.lapResults)-1].Time
+}
+
+// invalidTotal сообщает, что участник финишировал раньше, чем стартовал
+func (s *competitorStat) invalidTotal() bool {
+	return !s.finishTime.IsZero() && s.totalTime < 0
+}
+
+// raceTime — время гонки участника. По правилам раздельного старта оно
+// отсчитывается от времени старта по жеребьёвке, поэтому опоздание на старт
+// входит в результат. Без жеребьёвки используется фактический старт.
+// В индивидуальной гонке к нему прибавляется штраф за промахи.
+func raceTime(stat *competitorStat, cfg Config) time.Duration {
+	return stat.finishTime.Sub(stat.raceStart(cfg)) + stat.missPenalty(cfg)
+}
+
+// raceStart — момент, от которого отсчитывается время гонки участника
+func (s *competitorStat) raceStart(cfg Config) time.Time {
+	if cfg.TotalTimeBase == TotalTimeScheduled && cfg.rules().sharedStart {
+		return cfg.Start
+	}
+	if cfg.TotalTimeBase == TotalTimeScheduled && !s.startTime.IsZero() {
+		return s.startTime
+	}
+	return s.actualStart
+}
+
+func intervalResult(interval [2]time.Time, length int) LapResult {
+	if interval[0].IsZero() || interval[1].IsZero() {
+		return LapResult{Start: interval[0], Incomplete: true}
+	}
+
+	duration := interval[1].Sub(interval[0])
+	if duration < 0 {
+		return LapResult{Start: interval[0], Invalid: true}
+	}
+	return LapResult{
+		Start: interval[0],
+		Time:  duration,
+		Speed: float64(length) / duration.Seconds(),
+	}
+}
+
+// Status — состояние участника в таблице
+func (row Result) Status() string {
+	switch {
+	case row.NotStarted:
+		return "NotStarted"
+	case row.NotFinished:
+		return "NotFinished"
+	case !row.Finished:
+		return "Running"
+	case row.InvalidTotal:
+		return "Invalid"
+	default:
+		return "Finished"
+	}
+}
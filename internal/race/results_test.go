@@ -0,0 +1,268 @@
+package race
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPenaltySummary(t *testing.T) {
+	// Два промаха на первом рубеже и один заход — один круг; заход после
+	// второго рубежа не завершён и в сумму не входит
+	lines := `
+[09:05:00.000] 1 1
+[09:10:00.000] 2 1 10:00:00.000
+[10:00:01.000] 4 1
+[10:10:00.000] 5 1 1
+[10:10:01.000] 6 1 1
+[10:10:02.000] 6 1 2
+[10:10:03.000] 6 1 3
+[10:10:10.000] 7 1
+[10:10:20.000] 8 1
+[10:12:00.000] 9 1
+[10:13:00.000] 10 1
+[10:23:00.000] 5 1 2
+[10:23:01.000] 6 1 1
+[10:23:02.000] 6 1 2
+[10:23:03.000] 6 1 3
+[10:23:04.000] 6 1 4
+[10:23:10.000] 7 1
+[10:23:20.000] 8 1
+[10:26:00.000] 10 1
+`
+	log := &recordLogger{}
+	cfg := testConfig(t, "")
+	cfg.Logger = log
+	row := resultOf(t, runRace(t, cfg, lines).Results(), "1")
+
+	if row.PenaltyLoops != 1 {
+		t.Errorf("PenaltyLoops = %d, ожидалось 1", row.PenaltyLoops)
+	}
+	if want := 100 * time.Second; row.PenaltyTotal != want {
+		t.Errorf("PenaltyTotal = %s, ожидалось %s", row.PenaltyTotal, want)
+	}
+	if len(row.Penalties) != 2 || !row.Penalties[1].Incomplete {
+		t.Errorf("Penalties = %+v, ожидался незавершённый второй заход", row.Penalties)
+	}
+	if !log.contains("незавершённых заходов на штрафной круг 1") {
+		t.Errorf("нет предупреждения о незавершённом заходе: %q", log.warnings)
+	}
+}
+
+func TestAvgSpeed(t *testing.T) {
+	// 2 круга по 3500 м и 2 пройденных штрафных круга по 150 м за 26 минут
+	// от старта по жеребьёвке: 7300 м / 1560 с; третий положенный круг не
+	// пройден и в дистанцию не входит
+	lines := `
+[09:05:00.000] 1 1
+[09:10:00.000] 2 1 10:00:00.000
+[10:00:01.000] 4 1
+[10:10:00.000] 5 1 1
+[10:10:01.000] 6 1 1
+[10:10:02.000] 6 1 2
+[10:10:03.000] 6 1 3
+[10:10:10.000] 7 1
+[10:10:20.000] 8 1
+[10:12:00.000] 9 1
+[10:13:00.000] 10 1
+[10:23:00.000] 5 1 2
+[10:23:01.000] 6 1 1
+[10:23:02.000] 6 1 2
+[10:23:03.000] 6 1 3
+[10:23:04.000] 6 1 4
+[10:23:10.000] 7 1
+[10:23:20.000] 8 1
+[10:24:10.000] 9 1
+[10:26:00.000] 10 1
+`
+	row := resultOf(t, runRace(t, testConfig(t, ""), lines).Results(), "1")
+
+	if want := 7300.0 / 1560; math.Abs(row.AvgSpeed-want) > 1e-9 {
+		t.Errorf("AvgSpeed = %f, ожидалось %f", row.AvgSpeed, want)
+	}
+}
+
+func TestScheduledStartBasis(t *testing.T) {
+	// Участник 2 стартует на 20 секунд позже жеребьёвки и проходит круги за
+	// то же время, что и участник 1
+	lines := `
+[09:05:00.000] 1 1
+[09:05:01.000] 1 2
+[09:10:00.000] 2 1 10:00:00.000
+[09:10:01.000] 2 2 10:01:30.000
+[10:00:00.000] 4 1
+[10:01:50.000] 4 2
+[10:12:00.000] 10 1
+[10:13:50.000] 10 2
+[10:24:00.000] 10 1
+[10:25:50.000] 10 2
+`
+	rows := runRace(t, testConfig(t, ""), lines).Results()
+	if rows[0].ID != "1" || rows[1].ID != "2" {
+		t.Fatalf("порядок участников %s, %s, ожидался 1, 2", rows[0].ID, rows[1].ID)
+	}
+	if diff := rows[1].TotalTime - rows[0].TotalTime; diff != 20*time.Second {
+		t.Errorf("отставание опоздавшего участника %s, ожидалось 20s", diff)
+	}
+
+	rows = runRace(t, testConfig(t, `{"totalTimeBase": "actual"}`), lines).Results()
+	if rows[0].TotalTime != 24*time.Minute || rows[1].TotalTime != 24*time.Minute {
+		t.Errorf("время от фактического старта %s и %s, ожидалось 24m0s", rows[0].TotalTime, rows[1].TotalTime)
+	}
+}
+
+func TestResultsOrder(t *testing.T) {
+	// 1 не стартовал, 2 и 5 сошли — 5 на втором круге, 3 и 4 финишировали
+	lines := `
+[09:05:00.000] 1 1
+[09:05:01.000] 1 2
+[09:05:02.000] 1 3
+[09:05:03.000] 1 4
+[09:05:04.000] 1 5
+[09:10:00.000] 2 1 10:00:00.000
+[09:10:01.000] 2 2 10:01:30.000
+[09:10:02.000] 2 3 10:03:00.000
+[09:10:03.000] 2 4 10:04:30.000
+[09:10:04.000] 2 5 10:06:00.000
+[10:01:31.000] 4 2
+[10:03:01.000] 4 3
+[10:04:31.000] 4 4
+[10:06:01.000] 4 5
+[10:10:00.000] 11 2 Lost in the forest
+[10:18:00.000] 10 5
+[10:20:00.000] 11 5 Broken ski
+[10:27:00.000] 10 3
+[10:28:00.000] 10 4
+[10:51:00.000] 10 3
+[10:52:00.000] 10 4
+`
+	var order []string
+	for _, row := range runRace(t, testConfig(t, ""), lines).Results() {
+		order = append(order, row.ID)
+	}
+	if got := strings.Join(order, " "); got != "4 3 5 2 1" {
+		t.Errorf("порядок строк %s, ожидался 4 3 5 2 1", got)
+	}
+}
+
+func TestIndividualMissPenalty(t *testing.T) {
+	// Участник 1 прошёл дистанцию на 2 минуты быстрее, но 4 промаха дают ему
+	// 4 минуты штрафа; штрафной круг после первого рубежа время не меняет
+	lines := `
+[09:05:00.000] 1 1
+[09:05:01.000] 1 2
+[09:10:00.000] 2 1 10:00:00.000
+[09:10:01.000] 2 2 10:00:30.000
+[10:00:00.000] 4 1
+[10:00:30.000] 4 2
+[10:10:00.000] 5 1 1
+[10:10:01.000] 6 1 1
+[10:10:02.000] 6 1 2
+[10:10:03.000] 6 1 3
+[10:10:10.000] 7 1
+[10:10:20.000] 8 1
+[10:11:00.000] 5 2 1
+[10:11:01.000] 6 2 1
+[10:11:02.000] 6 2 2
+[10:11:03.000] 6 2 3
+[10:11:04.000] 6 2 4
+[10:11:05.000] 6 2 5
+[10:11:10.000] 7 2
+[10:11:20.000] 9 1
+[10:12:30.000] 10 1
+[10:14:00.000] 10 2
+[10:22:00.000] 5 1 2
+[10:22:01.000] 6 1 1
+[10:22:02.000] 6 1 2
+[10:22:03.000] 6 1 3
+[10:22:10.000] 7 1
+[10:24:00.000] 5 2 2
+[10:24:01.000] 6 2 1
+[10:24:02.000] 6 2 2
+[10:24:03.000] 6 2 3
+[10:24:04.000] 6 2 4
+[10:24:05.000] 6 2 5
+[10:24:10.000] 7 2
+[10:25:00.000] 10 1
+[10:27:30.000] 10 2
+`
+	rows := runRace(t, testConfig(t, `{"raceType": "individual"}`), lines).Results()
+
+	if rows[0].ID != "2" || rows[1].ID != "1" {
+		t.Fatalf("порядок %s %s, ожидалось 2 1", rows[0].ID, rows[1].ID)
+	}
+	fast, clean := rows[1], rows[0]
+	if fast.MissPenalty != 4*time.Minute || fast.TotalTime != 29*time.Minute {
+		t.Errorf("участник 1: штраф %s, время %s, ожидалось 4m0s и 29m0s", fast.MissPenalty, fast.TotalTime)
+	}
+	if clean.MissPenalty != 0 || clean.TotalTime != 27*time.Minute || clean.Place != 1 {
+		t.Errorf("участник 2: штраф %s, время %s, место %d, ожидалось 0s, 27m0s и 1", clean.MissPenalty, clean.TotalTime, clean.Place)
+	}
+}
+
+func TestPointsByPlace(t *testing.T) {
+	// Участники 1 и 2 делят 1-е место, участник 3 — 3-й вне таблицы,
+	// участник 4 не финишировал
+	lines := `
+[09:05:00.000] 1 1
+[09:05:01.000] 1 2
+[09:05:02.000] 1 3
+[09:05:03.000] 1 4
+[09:10:00.000] 2 1 10:00:00.000
+[09:10:01.000] 2 2 10:01:30.000
+[09:10:02.000] 2 3 10:03:00.000
+[09:10:03.000] 2 4 10:04:30.000
+[10:00:01.000] 4 1
+[10:01:31.000] 4 2
+[10:03:01.000] 4 3
+[10:04:31.000] 4 4
+[10:20:00.000] 10 1
+[10:21:30.000] 10 2
+[10:24:00.000] 10 3
+`
+	cfg := testConfig(t, `{"laps": 1, "points": [60, 54]}`)
+	rows := runRace(t, cfg, lines).Results()
+	for id, want := range map[string]int{"1": 60, "2": 60, "3": 0, "4": 0} {
+		if row := resultOf(t, rows, id); row.Points != want {
+			t.Errorf("участник %s: место %d, очков %d, ожидалось %d", id, row.Place, row.Points, want)
+		}
+	}
+}
+
+func TestPursuitBasis(t *testing.T) {
+	// Старты по отставаниям в квалификации, округлённым до секунды;
+	// ранжирование — по порядку финиша
+	lines := `
+[09:05:00.000] 1 1
+[09:05:01.000] 1 2
+[09:05:02.000] 1 4
+[09:10:00.000] 2 2 10:00:45.000
+[10:00:00.000] 4 1
+[10:00:40.000] 4 2
+[10:20:00.000] 10 1
+[10:19:50.000] 10 2
+`
+	log := &recordLogger{}
+	cfg := testConfig(t, `{"raceType": "pursuit", "laps": 1}`)
+	cfg.Logger = log
+	cfg.PursuitBasis = map[string]time.Duration{"1": 0, "2": 40400 * time.Millisecond, "3": 2 * time.Minute}
+	rows := runRace(t, cfg, lines).Results()
+
+	first, second := resultOf(t, rows, "2"), resultOf(t, rows, "1")
+	if first.Place != 1 || first.TotalTime != 19*time.Minute+10*time.Second || second.Place != 2 || second.TotalTime != 20*time.Minute {
+		t.Errorf("участник 2 %+v, участник 1 %+v, ожидались 1-е место за 19m10s и 2-е за 20m0s", first, second)
+	}
+	// Участник квалификации без событий — не стартовал
+	if row := resultOf(t, rows, "3"); !row.NotStarted {
+		t.Errorf("участник 3 %+v, ожидался NotStarted", row)
+	}
+	for _, want := range []string{
+		"время старта участника 2 10:00:45.000 не совпадает с отставанием в квалификации, ожидалось 10:00:40.000",
+		"участник 4 не финишировал в квалификации гонки преследования",
+	} {
+		if !log.contains(want) {
+			t.Errorf("нет предупреждения %q: %q", want, log.warnings)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package race
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Состояние гонки в JSON (-checkpoint, -dump-state, -load-state):
+//
+//	{
+//	  "processed": 120,          // число обработанных событий
+//	  "races": [{                // гонки в порядке появления в потоке
+//	    "id": "",                // идентификатор гонки, "" — гонка по умолчанию
+//	    "competitors": {
+//	      "1": {
+//	        "registered": true,
+//	        "startTime": "0000-01-01T10:00:00Z",   // время старта по жеребьёвке
+//	        "actualStart": "0000-01-01T10:00:01.744Z",
+//	        "laps": [["...", "..."]],              // [начало, конец] каждого круга
+//	        "penalties": [["...", "..."]],         // [вход, выход] каждой штрафной петли
+//	        "bouts": [{                            // посещения огневых рубежей
+//	          "firingRange": 1,
+//	          "targets": [1, 2, 4],                // поражённые мишени
+//	          "closed": true,                      // участник покинул рубеж
+//	          "penalties": [0],                    // индексы заходов на штрафной круг после рубежа
+//	          "interval": ["...", "..."]           // [приход, уход] с рубежа
+//	        }],
+//	        "notStarted": false,
+//	        "notFinished": false,
+//	        "finishTime": "...",
+//	        "totalTime": 0,                        // наносекунды
+//	        "comment": ""
+//	      }
+//	    },
+//	    "lastTime": "0000-01-01T10:32:22.472Z",  // время самого позднего события
+//	    "outgoing": [{                            // исходящие события 32 и 33
+//	      "time": "0000-01-01T10:25:26.047Z",
+//	      "timeStr": "[10:25:26.047]",
+//	      "id": 33,
+//	      "competitor": "1"
+//	    }]
+//	  }]
+//	}
+//
+// Время записывается в RFC 3339. Нулевое время "0001-01-01T00:00:00Z"
+// означает отсутствующую отметку, в том числе у незакрытых кругов и петель.
+
+// competitorState — сериализуемое состояние участника
+type competitorState struct {
+	Registered  bool              `json:"registered"`
+	StartTime   time.Time         `json:"startTime"`
+	ActualStart time.Time         `json:"actualStart"`
+	Laps        [][2]time.Time    `json:"laps"`
+	Penalties   [][2]time.Time    `json:"penalties"`
+	Bouts       []boutState       `json:"bouts"`
+	NotStarted  bool              `json:"notStarted"`
+	NotFinished bool              `json:"notFinished"`
+	FinishTime  time.Time         `json:"finishTime"`
+	TotalTime   time.Duration     `json:"totalTime"`
+	Comment     string            `json:"comment"`
+	Notes       map[string]string `json:"notes,omitempty"`
+	// ExchangeFrom — от кого принята эстафета
+	ExchangeFrom string `json:"exchangeFrom,omitempty"`
+}
+
+type boutState struct {
+	FiringRange int          `json:"firingRange"`
+	Lap         int          `json:"lap,omitempty"`
+	Targets     []int        `json:"targets"`
+	Closed      bool         `json:"closed"`
+	Penalties   []int        `json:"penalties,omitempty"`
+	Interval    [2]time.Time `json:"interval"`
+}
+
+type outgoingState struct {
+	Time       time.Time `json:"time"`
+	TimeStr    string    `json:"timeStr"`
+	ID         int       `json:"id"`
+	Competitor string    `json:"competitor"`
+}
+
+type raceSnapshot struct {
+	ID          string                     `json:"id"`
+	Competitors map[string]competitorState `json:"competitors"`
+	LastTime    time.Time                  `json:"lastTime"`
+	Outgoing    []outgoingState            `json:"outgoing,omitempty"`
+}
+
+// Checkpoint — снимок состояния обработки: число уже обработанных событий
+// и состояние всех гонок в порядке их появления в потоке
+type Checkpoint struct {
+	Processed int            `json:"processed"`
+	Races     []raceSnapshot `json:"races"`
+}
+
+func newCompetitorState(stat *competitorStat) competitorState {
+	state := competitorState{
+		Registered:   stat.registered,
+		StartTime:    stat.startTime,
+		ActualStart:  stat.actualStart,
+		Laps:         stat.lapsTime,
+		Penalties:    stat.penaltyTime,
+		Bouts:        make([]boutState, 0, len(stat.bouts)),
+		NotStarted:   stat.notStarted,
+		NotFinished:  stat.notFinished,
+		FinishTime:   stat.finishTime,
+		TotalTime:    stat.totalTime,
+		Comment:      stat.comment,
+		Notes:        stat.notes,
+		ExchangeFrom: stat.exchangeFrom,
+	}
+	for _, bout := range stat.bouts {
+		state.Bouts = append(state.Bouts, boutState{FiringRange: bout.firingRange, Lap: bout.lap, Targets: bout.targets, Closed: bout.closed, Penalties: bout.penalties, Interval: bout.interval})
+	}
+	return state
+}
+
+func (s competitorState) stat() *competitorStat {
+	stat := &competitorStat{
+		registered:   s.Registered,
+		startTime:    s.StartTime,
+		actualStart:  s.ActualStart,
+		lapsTime:     s.Laps,
+		penaltyTime:  s.Penalties,
+		notStarted:   s.NotStarted,
+		notFinished:  s.NotFinished,
+		finishTime:   s.FinishTime,
+		totalTime:    s.TotalTime,
+		comment:      s.Comment,
+		notes:        s.Notes,
+		exchangeFrom: s.ExchangeFrom,
+	}
+	if stat.lapsTime == nil {
+		stat.lapsTime = make([][2]time.Time, 0)
+	}
+	if stat.penaltyTime == nil {
+		stat.penaltyTime = make([][2]time.Time, 0)
+	}
+	for _, bout := range s.Bouts {
+		stat.bouts = append(stat.bouts, shootingBout{firingRange: bout.FiringRange, lap: bout.Lap, targets: bout.Targets, closed: bout.Closed, penalties: bout.Penalties, interval: bout.Interval})
+	}
+	return stat
+}
+
+func (s *Set) Snapshot(processed int) Checkpoint {
+	cp := Checkpoint{Processed: processed, Races: make([]raceSnapshot, 0, len(s.order))}
+	for _, raceID := range s.order {
+		race := s.races[raceID]
+		race.mu.Lock()
+		competitors := make(map[string]competitorState, len(race.stats))
+		for id, stat := range race.stats {
+			competitors[id] = newCompetitorState(stat)
+		}
+		outgoing := make([]outgoingState, 0, len(race.outgoing))
+		for _, ev := range race.outgoing {
+			outgoing = append(outgoing, outgoingState{Time: ev.time, TimeStr: ev.timeStr, ID: ev.id, Competitor: ev.competitor})
+		}
+		race.mu.Unlock()
+
+		cp.Races = append(cp.Races, raceSnapshot{ID: raceID, Competitors: competitors, LastTime: race.lastTime, Outgoing: outgoing})
+	}
+	return cp
+}
+
+func (s *Set) Restore(cp Checkpoint) {
+	s.races = make(map[string]*Race, len(cp.Races))
+	s.order = s.order[:0]
+	for _, snapshot := range cp.Races {
+		race := New(s.configs.ForRace(snapshot.ID))
+		for id, state := range snapshot.Competitors {
+			stat := state.stat()
+			stat.computeResults(race.cfg.forCompetitor(id))
+			race.stats[id] = stat
+		}
+		race.lastTime = snapshot.LastTime
+		for _, ev := range snapshot.Outgoing {
+			race.outgoing = append(race.outgoing, OutgoingEvent{time: ev.Time, timeStr: ev.TimeStr, id: ev.ID, competitor: ev.Competitor})
+		}
+		s.races[snapshot.ID] = race
+		s.order = append(s.order, snapshot.ID)
+	}
+}
+
+// SaveCheckpoint записывает снимок через временный файл, чтобы при сбое
+// во время записи предыдущий снимок остался целым
+func SaveCheckpoint(path string, cp Checkpoint) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	encoder := json.NewEncoder(tmp)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(cp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func LoadCheckpoint(path string) (Checkpoint, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	defer file.Close()
+
+	var cp Checkpoint
+	if err := json.NewDecoder(file).Decode(&cp); err != nil {
+		return Checkpoint{}, fmt.Errorf("файл %s повреждён: %w", path, err)
+	}
+	return cp, nil
+}
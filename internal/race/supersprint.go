@@ -0,0 +1,78 @@
+package race
+
+import (
+	"fmt"
+	"time"
+
+	"biathlon_system/parser"
+)
+
+// Qualification — результат квалификации суперспринта участника финала
+type Qualification struct {
+	Place     int
+	TotalTime time.Duration
+}
+
+// superSprintFinal — правила финала суперспринта: общий старт, места по
+// порядку финиша, незакрытые мишени на рубеже — сход вместо штрафных кругов
+var superSprintFinal = raceRules{finishOrder: true, sharedStart: true, shootingDNF: true}
+
+// Final возвращает параметры финала суперспринта с участниками qualified:
+// гонка стартует в FinalStart, если он задан
+func (c Configs) Final(qualified map[string]Qualification) Configs {
+	opts := c.Base.Options
+	opts.Qualified = qualified
+	out := c.WithOptions(opts)
+	out.Base = out.Base.final()
+	for raceID, cfg := range out.byRace {
+		out.byRace[raceID] = cfg.final()
+	}
+	return out
+}
+
+func (c Config) final() Config {
+	if !c.FinalStart.IsZero() {
+		c.Start = c.FinalStart
+	}
+	return c
+}
+
+// Qualify отбирает в финал суперспринта финишировавших участников, занявших
+// в квалификации места 1..n; при равном времени на n-м месте проходят все.
+// rows — итоговая таблица квалификации.
+func Qualify(rows []Result, n int) map[string]Qualification {
+	qualified := make(map[string]Qualification)
+	for _, row := range rows {
+		if row.Place == 0 || row.Place > n {
+			continue
+		}
+		qualified[row.ID] = Qualification{Place: row.Place, TotalTime: row.TotalTime}
+	}
+	return qualified
+}
+
+// checkShootingFailure снимает участника финала суперспринта, покинувшего
+// огневой рубеж с незакрытыми мишенями
+func checkShootingFailure(c *Competitor, ev parser.Event, bout *shootingBout) {
+	cfg, stat := c.cfg, c.stat
+	misses := bout.misses(cfg.Targets)
+	if misses <= 0 || stat.notFinished {
+		return
+	}
+	stat.notFinished = true
+	stat.comment = fmt.Sprintf("Сошёл: не закрыто мишеней на рубеже %d: %d", bout.firingRange, misses)
+	cfg.log().Warnf("Строка %d: участник %s финала суперспринта не закрыл мишеней на рубеже %d: %d, результат — сход", ev.Line, c.ID, bout.firingRange, misses)
+}
+
+// addQualifiedNonStarters добавляет участников финала суперспринта, от
+// которых не было ни одного события: в итоговой таблице они не стартовавшие
+func (r *Race) addQualifiedNonStarters() {
+	for id := range r.cfg.Qualified {
+		if _, ok := r.stats[id]; ok {
+			continue
+		}
+		stat := &competitorStat{startTime: r.cfg.Start}
+		stat.computeResults(r.cfg)
+		r.stats[id] = stat
+	}
+}
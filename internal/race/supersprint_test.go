@@ -0,0 +1,80 @@
+package race
+
+import (
+	"strings"
+	"testing"
+)
+
+// superSprintConfig — суперспринт в один круг с одним огневым рубежом
+const superSprintConfig = `{"raceType": "supersprint", "laps": 1, "lapLen": 1500, "penaltyLen": 75,
+	"firingLines": 1, "qualifiers": 2, "finalStart": "11:00:00.000"}`
+
+func TestQualifyTopN(t *testing.T) {
+	// Квалификация: участник 3 третий и в финал при квоте 2 не проходит
+	qualification := `
+[09:30:00.000] 1 1
+[09:30:01.000] 1 2
+[09:30:02.000] 1 3
+[09:35:00.000] 2 1 10:00:00.000
+[09:35:01.000] 2 2 10:00:30.000
+[09:35:02.000] 2 3 10:01:00.000
+[10:00:00.000] 4 1
+[10:00:30.000] 4 2
+[10:01:00.000] 4 3
+[10:06:40.000] 10 1
+[10:07:40.000] 10 2
+[10:08:20.000] 10 3
+`
+	configs := testConfigs(t, superSprintConfig)
+	qualified := Qualify(runRace(t, configs.Base, qualification).Results(), configs.Base.Qualifiers)
+	if len(qualified) != 2 || qualified["1"].Place != 1 || qualified["2"].Place != 2 {
+		t.Fatalf("в финал прошли %+v, ожидались участники 1 и 2", qualified)
+	}
+
+	// В финале события участника 3 отклоняются, участник 2 без событий — не
+	// стартовавший
+	log := &recordLogger{}
+	final := configs.Final(qualified).Base
+	final.Logger = log
+	rows := runRace(t, final, `
+[10:50:00.000] 1 1
+[10:50:02.000] 1 3
+[11:00:00.500] 4 1
+[11:00:00.700] 4 3
+[11:06:20.000] 10 1
+`).Results()
+	if len(rows) != 2 || rows[0].ID != "1" || rows[0].Place != 1 || rows[1].ID != "2" || !rows[1].NotStarted {
+		t.Errorf("таблица финала %+v, ожидались участник 1 на первом месте и не стартовавший участник 2", rows)
+	}
+	if !log.contains("участник 3 не прошёл в финал") {
+		t.Errorf("нет предупреждения об участнике вне финала: %q", log.warnings)
+	}
+	if got := final.Start.Format("15:04:05"); got != "11:00:00" {
+		t.Errorf("старт финала %s, ожидалось 11:00:00", got)
+	}
+}
+
+func TestFinalShootingFailure(t *testing.T) {
+	// Участник покидает рубеж с незакрытой мишенью — сход сразу, без
+	// ожидания финиша и итогов гонки
+	configs := testConfigs(t, superSprintConfig)
+	r := New(configs.Final(map[string]Qualification{"1": {Place: 1}}).Base)
+	for _, ev := range parseEvents(t, `
+[10:50:00.000] 1 1
+[11:00:00.500] 4 1
+[11:05:20.000] 5 1 1
+[11:05:23.000] 6 1 1
+[11:05:26.000] 6 1 2
+[11:05:29.000] 6 1 3
+[11:05:32.000] 6 1 4
+[11:05:40.000] 7 1
+`) {
+		if err := r.Apply(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	row := resultOf(t, r.Results(), "1")
+	if !row.NotFinished || !strings.HasPrefix(row.Comment, "Сошёл: не закрыто мишеней на рубеже 1: 1") {
+		t.Errorf("NotFinished = %v, комментарий %q, ожидался сход после рубежа", row.NotFinished, row.Comment)
+	}
+}
@@ -0,0 +1,84 @@
+package race
+
+import (
+	"sort"
+	"time"
+)
+
+// TeamScore — строка командного зачёта: сумма времени лучших TeamScorers
+// финишировавших участников команды
+type TeamScore struct {
+	Team string
+	// Scorers — участники, чьё время вошло в зачёт, в порядке мест
+	Scorers []string
+	// Place — место команды, 0 — неполная команда без места
+	Place     int
+	TotalTime time.Duration
+	Behind    time.Duration
+	Finishers int // финишировавших участников команды
+	// Complete — финишировавших не меньше TeamScorers
+	Complete bool
+	// Hits, Shots и PenaltyLoops — по всем участникам команды
+	Hits         int
+	Shots        int
+	PenaltyLoops int
+}
+
+// TeamScores рассчитывает командный зачёт по строкам итоговой таблицы rows.
+// Участники без команды в зачёт не идут.
+func TeamScores(rows []Result, cfg Config) []TeamScore {
+	index := make(map[string]int, len(cfg.Teams))
+	scores := make([]TeamScore, 0, len(cfg.Teams))
+	for _, team := range cfg.Teams {
+		index[team.Name] = len(scores)
+		scores = append(scores, TeamScore{Team: team.Name})
+	}
+
+	// rows уже упорядочены по местам, поэтому первые финишировавшие
+	// участники команды — лучшие
+	for _, row := range rows {
+		team, _, ok := cfg.teamOf(row.ID)
+		if !ok {
+			continue
+		}
+		score := &scores[index[team]]
+		score.Hits += row.Hits
+		score.Shots += row.Shots
+		score.PenaltyLoops += row.PenaltyLoops
+		if row.Place == 0 {
+			continue
+		}
+		score.Finishers++
+		if len(score.Scorers) < cfg.TeamScorers {
+			score.Scorers = append(score.Scorers, row.ID)
+			score.TotalTime += row.TotalTime
+		}
+	}
+	for i := range scores {
+		scores[i].Complete = scores[i].Finishers >= cfg.TeamScorers
+	}
+
+	sort.SliceStable(scores, func(i, j int) bool {
+		if scores[i].Complete != scores[j].Complete {
+			return scores[i].Complete
+		}
+		if scores[i].Complete && scores[i].TotalTime != scores[j].TotalTime {
+			return scores[i].TotalTime < scores[j].TotalTime
+		}
+		if scores[i].Finishers != scores[j].Finishers {
+			return scores[i].Finishers > scores[j].Finishers
+		}
+		return scores[i].Team < scores[j].Team
+	})
+	for i := range scores {
+		if !scores[i].Complete {
+			continue
+		}
+		scores[i].Place = i + 1
+		if i > 0 && scores[i-1].TotalTime == scores[i].TotalTime {
+			scores[i].Place = scores[i-1].Place
+		}
+		scores[i].Behind = scores[i].TotalTime - scores[0].TotalTime
+	}
+	return scores
+}
@@ -0,0 +1,82 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+
+	"biathlon_system/internal/race"
+)
+
+// CSV выводит итоговую таблицу в CSV (RFC 4180) для электронных
+// таблиц: заголовок и по строке на участника, время и скорость каждого круга
+// в отдельных столбцах
+type CSV struct{}
+
+func (CSV) Write(w io.Writer, rows []race.Result, cfg race.Config) error {
+	writer := csv.NewWriter(w)
+
+	header := []string{"place", "id", "status", "total_time"}
+	for i := 1; i <= cfg.Laps; i++ {
+		header = append(header, fmt.Sprintf("lap%d_time", i), fmt.Sprintf("lap%d_speed", i))
+	}
+	header = append(header, "penalty_time", "penalty_loops", "hits", "shots", "comment")
+	if cfg.QualifyTop > 0 {
+		header = append(header, "qualified")
+	}
+	if len(cfg.Points) > 0 {
+		header = append(header, "points")
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		record := make([]string, 0, len(header))
+
+		place := ""
+		if row.Place > 0 {
+			place = strconv.Itoa(row.Place)
+		}
+		totalTime := ""
+		if row.Status() == "Finished" {
+			totalTime = FormatDuration(row.TotalTime)
+		}
+		record = append(record, place, row.ID, row.Status(), totalTime)
+
+		// Столбцы кругов дополняются пустыми значениями до числа кругов гонки
+		for i := 0; i < cfg.Laps; i++ {
+			if i >= len(row.Laps) || row.Laps[i].Incomplete || row.Laps[i].Invalid {
+				record = append(record, "", "")
+				continue
+			}
+			record = append(record, FormatDuration(row.Laps[i].Time), FormatSpeed(row.Laps[i].Speed))
+		}
+
+		comment := ""
+		if row.NotStarted || row.NotFinished {
+			comment = row.Comment
+		}
+		record = append(record,
+			FormatDuration(row.PenaltyTotal),
+			strconv.Itoa(row.PenaltyLoops),
+			strconv.Itoa(row.Hits),
+			strconv.Itoa(row.Shots),
+			comment,
+		)
+		if cfg.QualifyTop > 0 {
+			record = append(record, strconv.FormatBool(row.Qualified))
+		}
+		if len(cfg.Points) > 0 {
+			record = append(record, strconv.Itoa(row.Points))
+		}
+
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
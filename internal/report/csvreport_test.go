@@ -0,0 +1,40 @@
+package report
+
+import (
+	"encoding/csv"
+	"strings"
+	"testing"
+)
+
+func TestCSVRoundTrip(t *testing.T) {
+	for _, name := range []string{"events", "comments"} {
+		rows, cfg := raceResults(t, name, "")
+		records, err := csv.NewReader(strings.NewReader(writeString(t, CSV{}, rows, cfg))).ReadAll()
+		if err != nil {
+			t.Fatalf("%s: разбор CSV: %v", name, err)
+		}
+		if len(records) != len(rows)+1 {
+			t.Fatalf("%s: записей %d, ожидалось %d", name, len(records), len(rows)+1)
+		}
+
+		// place, id, status, total_time, по два столбца на круг и ещё пять
+		columns := 4 + 2*cfg.Laps + 5
+		for i, record := range records {
+			if len(record) != columns {
+				t.Errorf("%s: запись %d: столбцов %d, ожидалось %d", name, i+1, len(record), columns)
+			}
+		}
+		for i, row := range rows {
+			record := records[i+1]
+			if record[1] != row.ID || record[2] != row.Status() {
+				t.Errorf("%s: запись %d: участник %s %s, ожидался %s %s", name, i+2, record[1], record[2], row.ID, row.Status())
+			}
+			if row.Status() == "Finished" && record[3] != FormatDuration(row.TotalTime) {
+				t.Errorf("%s: участник %s: время %s, ожидалось %s", name, row.ID, record[3], FormatDuration(row.TotalTime))
+			}
+			if row.Status() != "Finished" && record[columns-1] != row.Comment {
+				t.Errorf("%s: участник %s: комментарий %q, ожидался %q", name, row.ID, record[columns-1], row.Comment)
+			}
+		}
+	}
+}
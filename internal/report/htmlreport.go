@@ -0,0 +1,96 @@
+package report
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"time"
+
+	"biathlon_system/internal/race"
+	"biathlon_system/parser"
+)
+
+//go:embed templates/report.html templates/*.tmpl
+var templatesFS embed.FS
+
+var htmlReportTemplate = template.Must(template.ParseFS(templatesFS, "templates/report.html"))
+
+// htmlReport — данные шаблона HTML-отчёта
+type htmlReport struct {
+	Config struct {
+		Laps, LapLen, PenaltyLen, FiringLines, Targets int
+		Start, StartDelta, RaceType                    string
+	}
+	LapNumbers []int
+	Rows       []htmlRow
+}
+
+type htmlRow struct {
+	Class       string
+	Place       string
+	TotalMillis int64 // для сортировки таблицы по data-атрибуту
+	Competitor  string
+	Laps        []string
+	Shooting    string
+	Penalty     string
+	Total       string
+	Comment     string
+}
+
+// HTML выводит итоговую таблицу в самодостаточный HTML-файл для
+// просмотра в браузере и печати. Экранирование выполняет html/template.
+type HTML struct{}
+
+func (HTML) Write(w io.Writer, rows []race.Result, cfg race.Config) error {
+	var report htmlReport
+	report.Config.RaceType = cfg.RaceType
+	report.Config.Laps = cfg.Laps
+	report.Config.LapLen = cfg.LapLen
+	report.Config.PenaltyLen = cfg.PenaltyLen
+	report.Config.FiringLines = cfg.FiringLines
+	report.Config.Targets = cfg.Targets
+	report.Config.Start = cfg.Start.Format(parser.TimeFormat)
+	report.Config.StartDelta = time.Time{}.Add(cfg.StartDelta).Format(parser.TimeFormat[:8])
+	for i := 1; i <= cfg.Laps; i++ {
+		report.LapNumbers = append(report.LapNumbers, i)
+	}
+
+	for _, row := range rows {
+		out := htmlRow{
+			Competitor: row.ID,
+			Shooting:   fmt.Sprintf("%d/%d", row.Hits, row.Shots),
+			Penalty:    fmt.Sprintf("%d × %s", row.PenaltyLoops, FormatDuration(row.PenaltyTotal)),
+			Total:      row.Status(),
+		}
+		if row.Place > 0 {
+			out.Place = strconv.Itoa(row.Place)
+			out.TotalMillis = row.TotalTime.Milliseconds()
+			out.Total = FormatDuration(row.TotalTime)
+		}
+		switch {
+		case row.NotStarted:
+			out.Class = "not-started"
+			out.Comment = row.Comment
+		case row.NotFinished:
+			out.Class = "not-finished"
+			out.Comment = row.Comment
+		}
+
+		for i := 0; i < cfg.Laps; i++ {
+			switch {
+			case i >= len(row.Laps) || row.Laps[i].Incomplete:
+				out.Laps = append(out.Laps, "")
+			case row.Laps[i].Invalid:
+				out.Laps = append(out.Laps, "invalid")
+			default:
+				out.Laps = append(out.Laps, fmt.Sprintf("%s (%s м/с)", FormatDuration(row.Laps[i].Time), FormatSpeed(row.Laps[i].Speed)))
+			}
+		}
+
+		report.Rows = append(report.Rows, out)
+	}
+
+	return htmlReportTemplate.Execute(w, report)
+}
@@ -0,0 +1,59 @@
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"biathlon_system/internal/race"
+)
+
+// mdEscaper экранирует символы, ломающие таблицу или разметку Markdown
+var mdEscaper = strings.NewReplacer(`\`, `\\`, "|", `\|`, "\n", " ", "\r", " ", "<", "&lt;", ">", "&gt;")
+
+// Markdown выводит итоговую таблицу в формате GitHub Markdown:
+// сводная таблица и раскрывающийся блок с кругами каждого участника
+type Markdown struct{}
+
+func (Markdown) Write(w io.Writer, rows []race.Result, cfg race.Config) error {
+	writer := bufio.NewWriter(w)
+
+	fmt.Fprintln(writer, "| Место | Участник | Время | Отставание | Стрельба | Штраф |")
+	fmt.Fprintln(writer, "|------:|----------|------:|-----------:|---------:|------:|")
+	for _, row := range rows {
+		place, total, behind := "-", row.Status(), ""
+		if row.Place > 0 {
+			place = strconv.Itoa(row.Place)
+			total = FormatDuration(row.TotalTime)
+			behind = formatBehind(row.Place, row.Behind)
+		}
+		if (row.NotStarted || row.NotFinished) && row.Comment != "" {
+			total += " (" + mdEscaper.Replace(row.Comment) + ")"
+		}
+		fmt.Fprintf(writer, "| %s | %s | %s | %s | %d/%d | %d × %s |\n",
+			place, mdEscaper.Replace(row.ID), total, behind, row.Hits, row.Shots,
+			row.PenaltyLoops, FormatDuration(row.PenaltyTotal))
+	}
+
+	for _, row := range rows {
+		fmt.Fprintf(writer, "\n<details><summary>%s</summary>\n\n", mdEscaper.Replace(row.ID))
+		if len(row.Laps) == 0 {
+			fmt.Fprintln(writer, "Нет кругов.")
+		}
+		for i, lap := range row.Laps {
+			switch {
+			case lap.Incomplete:
+				fmt.Fprintf(writer, "- Круг %d: не завершён\n", i+1)
+			case lap.Invalid:
+				fmt.Fprintf(writer, "- Круг %d: invalid\n", i+1)
+			default:
+				fmt.Fprintf(writer, "- Круг %d: %s, %s м/с\n", i+1, FormatDuration(lap.Time), FormatSpeed(lap.Speed))
+			}
+		}
+		fmt.Fprintln(writer, "\n</details>")
+	}
+
+	return writer.Flush()
+}
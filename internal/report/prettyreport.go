@@ -0,0 +1,90 @@
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"biathlon_system/internal/race"
+)
+
+// prettyLapWidth — ширина столбца кругов, дальше детализация обрезается
+const prettyLapWidth = 40
+
+// Pretty выводит итоговую таблицу выровненными столбцами для
+// чтения в терминале. Ширина столбца — по самому длинному значению в нём.
+type Pretty struct{}
+
+func (Pretty) Write(w io.Writer, rows []race.Result, cfg race.Config) error {
+	table := [][]string{{"Место", "Номер", "Время", "Отставание", "Стрельба", "Штраф", "Круги"}}
+	for _, row := range rows {
+		place, total, behind := "-", row.Status(), ""
+		if row.Place > 0 {
+			place = strconv.Itoa(row.Place)
+			total = FormatDuration(row.TotalTime)
+			behind = formatBehind(row.Place, row.Behind)
+		}
+
+		laps := make([]string, 0, len(row.Laps))
+		for _, lap := range row.Laps {
+			switch {
+			case lap.Incomplete:
+				laps = append(laps, "-")
+			case lap.Invalid:
+				laps = append(laps, "invalid")
+			default:
+				laps = append(laps, FormatDuration(lap.Time))
+			}
+		}
+
+		table = append(table, []string{
+			place,
+			row.ID,
+			total,
+			behind,
+			fmt.Sprintf("%d/%d", row.Hits, row.Shots),
+			fmt.Sprintf("%d × %s", row.PenaltyLoops, FormatDuration(row.PenaltyTotal)),
+			truncate(strings.Join(laps, " "), prettyLapWidth),
+		})
+	}
+
+	widths := make([]int, len(table[0]))
+	for _, cells := range table {
+		for i, cell := range cells {
+			if n := utf8.RuneCountInString(cell); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	writer := bufio.NewWriter(w)
+	for _, cells := range table {
+		var line strings.Builder
+		for i, cell := range cells {
+			if i > 0 {
+				line.WriteString("  ")
+			}
+			line.WriteString(cell)
+			if i < len(cells)-1 {
+				line.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)))
+			}
+		}
+		if _, err := writer.WriteString(line.String() + "\n"); err != nil {
+			return err
+		}
+	}
+
+	return writer.Flush()
+}
+
+// truncate обрезает строку до n символов, отмечая обрезку многоточием
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
@@ -0,0 +1,519 @@
+// Package report выводит строки итоговой таблицы в поддерживаемых форматах
+package report
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"biathlon_system/internal/race"
+	"biathlon_system/parser"
+)
+
+// Writer выводит строки итоговой таблицы в одном из форматов отчёта. Все
+// значения строк уже рассчитаны race, Writer их только форматирует.
+type Writer interface {
+	Write(w io.Writer, rows []race.Result, cfg race.Config) error
+}
+
+// Formats — поддерживаемые форматы итогового отчёта; text — без
+// дополнительных колонок
+var Formats = map[string]Writer{
+	"text": Text{},
+	"csv":  CSV{},
+	"html": HTML{},
+	"md":   Markdown{},
+	"xml":  XML{},
+	"json": JSON{},
+}
+
+// Output — файл отчёта из -output и его формат
+type Output struct {
+	Format string
+	Path   string
+}
+
+// ParseOutputs разбирает -output: формат=путь через запятую
+func ParseOutputs(spec string) ([]Output, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	var outputs []Output
+	for _, item := range strings.Split(spec, ",") {
+		format, path, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || path == "" {
+			return nil, fmt.Errorf("-output: ожидается формат=путь, получено %q", item)
+		}
+		if _, ok := Formats[format]; !ok {
+			return nil, fmt.Errorf("-output: неизвестный формат отчёта %s", format)
+		}
+		outputs = append(outputs, Output{Format: format, Path: path})
+	}
+	return outputs, nil
+}
+
+// Text выводит итоговую таблицу в текстовом формате
+type Text struct {
+	// RangeDetail добавляет время каждого посещения огневого рубежа
+	// (параметр -range-detail)
+	RangeDetail bool
+	// WithRank добавляет в начало строк место участника (параметр -with-rank)
+	WithRank bool
+	// WithPenaltySummary добавляет число штрафных кругов и их суммарное время
+	// (параметр -with-penalty-summary)
+	WithPenaltySummary bool
+	// WithBouts добавляет попадания на каждом огневом рубеже (параметр
+	// -with-bouts)
+	WithBouts bool
+	// WithRange добавляет время на огневых рубежах (параметр -with-range,
+	// включается и -range-detail)
+	WithRange bool
+	// WithPenaltyCheck добавляет пройденные и положенные штрафные круги
+	// (параметр -with-penalty-check)
+	WithPenaltyCheck bool
+	// WithBehind добавляет отставание от победителя (параметр -with-behind)
+	WithBehind bool
+	// WithAvgSpeed добавляет среднюю скорость на дистанции (параметр
+	// -with-avg-speed)
+	WithAvgSpeed bool
+}
+
+func (t Text) Write(file io.Writer, rows []race.Result, cfg race.Config) error {
+	writer := bufio.NewWriter(file)
+
+	for _, row := range rows {
+		var totalTimeStr string
+		if row.NotStarted {
+			totalTimeStr = "[NotStarted]"
+		} else if row.NotFinished {
+			totalTimeStr = "[NotFinished]"
+		} else if row.InvalidTotal {
+			totalTimeStr = "[Invalid]"
+		} else {
+			totalTimeStr = "{" + FormatDuration(row.TotalTime) + "}"
+			if row.MissPenalty > 0 {
+				totalTimeStr = "{" + FormatDuration(row.TotalTime) + " (" + formatBehind(0, row.MissPenalty) + ")}"
+			}
+		}
+
+		lapsTimeStr := "["
+		for i, lap := range row.Laps {
+			if lap.Incomplete {
+				lapsTimeStr += "{,}"
+			} else if lap.Invalid {
+				lapsTimeStr += "{invalid}"
+			} else {
+				lapsTimeStr += fmt.Sprintf("{%s, %s}", FormatDuration(lap.Time), FormatSpeed(lap.Speed))
+			}
+			if i < len(row.Laps)-1 {
+				lapsTimeStr += ", "
+			}
+		}
+		lapsTimeStr += "]"
+
+		penaltyTimeStr := "["
+		for i, penalty := range row.Penalties {
+			if penalty.Incomplete {
+				penaltyTimeStr += "{,}"
+			} else if penalty.Invalid {
+				penaltyTimeStr += "{invalid}"
+			} else {
+				penaltyTimeStr += fmt.Sprintf("{%s, %s}", FormatDuration(penalty.Time), FormatSpeed(penalty.Speed))
+			}
+			if i < len(row.Penalties)-1 {
+				penaltyTimeStr += ", "
+			}
+		}
+		penaltyTimeStr += "]"
+
+		resultString := fmt.Sprintf("%s %s %s %s", totalTimeStr, row.ID, lapsTimeStr, penaltyTimeStr)
+		if t.WithPenaltySummary {
+			resultString += fmt.Sprintf(" {%s, %s}", count(row.PenaltyLoops, "loop"), FormatDuration(row.PenaltyTotal))
+		}
+		resultString += fmt.Sprintf(" %d/%d", row.Hits, row.Shots)
+		if t.WithBouts && len(row.BoutHits) > 0 {
+			bouts := make([]string, 0, len(row.BoutHits))
+			for _, hits := range row.BoutHits {
+				bouts = append(bouts, strconv.Itoa(hits))
+			}
+			resultString += " (" + strings.Join(bouts, "+") + ")"
+		}
+		if t.WithRange || t.RangeDetail {
+			resultString += " {range " + FormatDuration(row.RangeTotal)
+			if t.RangeDetail {
+				visits := make([]string, 0, len(row.RangeVisits))
+				for _, visit := range row.RangeVisits {
+					switch {
+					case visit.Incomplete:
+						visits = append(visits, "{,}")
+					case visit.Invalid:
+						visits = append(visits, "{invalid}")
+					default:
+						visits = append(visits, FormatDuration(visit.Time))
+					}
+				}
+				resultString += " [" + strings.Join(visits, ", ") + "]"
+			}
+			resultString += "}"
+		}
+		if q := row.Qualification; q != nil {
+			resultString += fmt.Sprintf(" {qualification %d, %s}", q.Place, FormatDuration(q.TotalTime))
+		}
+		// В финале суперспринта место выводится всегда: итоговая таблица
+		// сводит места квалификации и финала
+		if t.WithRank || row.Qualification != nil {
+			place := "-"
+			if row.Place > 0 {
+				place = strconv.Itoa(row.Place)
+			}
+			resultString = place + " " + resultString
+		}
+		if t.WithPenaltyCheck && row.PenaltyOwed >= 0 {
+			resultString += fmt.Sprintf(" %d/%d", row.PenaltySkied, row.PenaltyOwed)
+		}
+		if t.WithBehind && row.Place > 0 {
+			resultString += " " + formatBehind(row.Place, row.Behind)
+		}
+		if t.WithAvgSpeed && row.Place > 0 {
+			resultString += " {avg " + FormatSpeed(row.AvgSpeed) + "}"
+		}
+		if len(cfg.Points) > 0 {
+			resultString += fmt.Sprintf(" {%d pts}", row.Points)
+		}
+		if row.Qualified {
+			resultString += " Q"
+		}
+		if (row.NotStarted || row.NotFinished) && row.Comment != "" {
+			resultString += " (" + escapeComment(row.Comment) + ")"
+		}
+		if row.Provisional {
+			resultString += " [Provisional]"
+		}
+		resultString += "\n"
+
+		if _, err := writer.WriteString(resultString); err != nil {
+			return err
+		}
+	}
+
+	if cfg.RaceType == race.RaceRelay {
+		if err := t.writeTeams(writer, race.TeamStandings(rows, cfg)); err != nil {
+			return err
+		}
+	} else if len(cfg.Teams) > 0 {
+		if err := t.writeTeamScores(writer, race.TeamScores(rows, cfg)); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// writeTeams выводит после строк участников командную таблицу эстафеты
+func (t Text) writeTeams(writer *bufio.Writer, teams []race.TeamResult) error {
+	if _, err := writer.WriteString("\nTeams:\n"); err != nil {
+		return err
+	}
+	for _, team := range teams {
+		var line string
+		switch team.Status() {
+		case "Finished":
+			line = "{" + FormatDuration(team.TotalTime) + "}"
+		default:
+			line = "[" + team.Status() + "]"
+		}
+		splits := make([]string, 0, len(team.Splits))
+		for _, split := range team.Splits {
+			if split.Finished {
+				splits = append(splits, fmt.Sprintf("%s {%s, %s}", split.ID, FormatDuration(split.Time), FormatSpeed(split.Speed)))
+			} else {
+				splits = append(splits, split.ID+" {,}")
+			}
+		}
+		line += fmt.Sprintf(" %s [%s] %d/%d legs", team.Team, strings.Join(splits, ", "), team.LegsDone, len(team.Legs))
+		if t.WithRank {
+			place := "-"
+			if team.Place > 0 {
+				place = strconv.Itoa(team.Place)
+			}
+			line = place + " " + line
+		}
+		if team.Place > 0 {
+			line += " " + formatBehind(team.Place, team.Behind)
+		}
+		if team.Comment != "" {
+			line += " (" + escapeComment(team.Comment) + ")"
+		}
+		if team.Provisional {
+			line += " [Provisional]"
+		}
+		if _, err := writer.WriteString(line + "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTeamScores выводит после строк участников командный зачёт; команды,
+// в которых финишировало меньше участников, чем идёт в зачёт, — [Incomplete]
+func (t Text) writeTeamScores(writer *bufio.Writer, scores []race.TeamScore) error {
+	if _, err := writer.WriteString("\nTeam standings:\n"); err != nil {
+		return err
+	}
+	for _, score := range scores {
+		line := "[Incomplete]"
+		if score.Complete {
+			line = "{" + FormatDuration(score.TotalTime) + "}"
+		}
+		line += fmt.Sprintf(" %s [%s] %s %d/%d {%s}",
+			score.Team, strings.Join(score.Scorers, ", "), count(score.Finishers, "finisher"), score.Hits, score.Shots, count(score.PenaltyLoops, "loop"))
+		if t.WithRank {
+			place := "-"
+			if score.Place > 0 {
+				place = strconv.Itoa(score.Place)
+			}
+			line = place + " " + line
+		}
+		if score.Place > 0 {
+			line += " " + formatBehind(score.Place, score.Behind)
+		}
+		if _, err := writer.WriteString(line + "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type lapJSON struct {
+	Time    string  `json:"time,omitempty"`
+	Speed   float64 `json:"speed,omitempty"`
+	Invalid bool    `json:"invalid,omitempty"`
+}
+
+type standingJSON struct {
+	Place      int    `json:"place,omitempty"`
+	Behind     string `json:"behind,omitempty"`
+	Competitor string `json:"competitor"`
+	Status     string `json:"status"`
+	TotalTime  string `json:"totalTime,omitempty"`
+	// MissPenalty — штраф за промахи, включённый в totalTime
+	MissPenalty string    `json:"missPenalty,omitempty"`
+	Laps        []lapJSON `json:"laps"`
+	Penalties   []lapJSON `json:"penalties"`
+	Hits        int       `json:"hits"`
+	Shots       int       `json:"shots"`
+	BoutHits    []int     `json:"boutHits"`
+	// PenaltyLoopsOwed отсутствует, если проверка штрафных кругов отключена
+	PenaltyLoopsSkied int    `json:"penaltyLoopsSkied"`
+	PenaltyLoopsOwed  *int   `json:"penaltyLoopsOwed,omitempty"`
+	Comment           string `json:"comment,omitempty"`
+	Provisional       bool   `json:"provisional,omitempty"`
+	Qualified         bool   `json:"qualified,omitempty"`
+	Points            int    `json:"points,omitempty"`
+	// PenaltyTotal — суммарное время завершённых штрафных кругов
+	PenaltyLoops int       `json:"penaltyLoops"`
+	PenaltyTotal string    `json:"penaltyTotal"`
+	RangeVisits  []lapJSON `json:"rangeVisits"`
+	RangeTime    string    `json:"rangeTime"`
+	AvgSpeed     float64   `json:"avgSpeed,omitempty"`
+	// Notes — дополнительные данные из пользовательских событий
+	Notes map[string]string `json:"notes,omitempty"`
+	// Qualification — место и время квалификации участника финала суперспринта
+	Qualification *qualificationJSON `json:"qualification,omitempty"`
+}
+
+type qualificationJSON struct {
+	Place     int    `json:"place"`
+	TotalTime string `json:"totalTime"`
+}
+
+// JSON выводит итоговую таблицу массивом JSON, как /standings
+type JSON struct{}
+
+func (JSON) Write(w io.Writer, rows []race.Result, cfg race.Config) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(standingsToJSON(rows))
+}
+
+// MarshalResults кодирует строки таблицы в JSON для живых отчётов
+func MarshalResults(rows []race.Result) ([]byte, error) {
+	return json.Marshal(standingsToJSON(rows))
+}
+
+func standingsToJSON(rows []race.Result) []standingJSON {
+	out := make([]standingJSON, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, newStandingJSON(row))
+	}
+	return out
+}
+
+func newStandingJSON(row race.Result) standingJSON {
+	out := standingJSON{
+		Place:             row.Place,
+		Competitor:        row.ID,
+		Status:            row.Status(),
+		Laps:              lapsJSON(row.Laps),
+		Penalties:         lapsJSON(row.Penalties),
+		PenaltyLoops:      row.PenaltyLoops,
+		PenaltyTotal:      FormatDuration(row.PenaltyTotal),
+		RangeVisits:       lapsJSON(row.RangeVisits),
+		RangeTime:         FormatDuration(row.RangeTotal),
+		Hits:              row.Hits,
+		Shots:             row.Shots,
+		BoutHits:          row.BoutHits,
+		PenaltyLoopsSkied: row.PenaltySkied,
+		Provisional:       row.Provisional,
+		Qualified:         row.Qualified,
+		Points:            row.Points,
+		Notes:             row.Notes,
+	}
+	if row.PenaltyOwed >= 0 {
+		out.PenaltyLoopsOwed = &row.PenaltyOwed
+	}
+	if q := row.Qualification; q != nil {
+		out.Qualification = &qualificationJSON{Place: q.Place, TotalTime: FormatDuration(q.TotalTime)}
+	}
+	if row.Place > 0 {
+		out.AvgSpeed = roundSpeed(row.AvgSpeed)
+	}
+	if row.Place > 0 && row.Behind > 0 {
+		out.Behind = formatBehind(row.Place, row.Behind)
+	}
+	switch out.Status {
+	case "NotStarted", "NotFinished":
+		out.Comment = row.Comment
+	case "Finished":
+		out.TotalTime = FormatDuration(row.TotalTime)
+		if row.MissPenalty > 0 {
+			out.MissPenalty = FormatDuration(row.MissPenalty)
+		}
+	}
+
+	return out
+}
+
+// competitorJSON — подробные данные участника: строка таблицы, в которой у
+// кругов и посещений рубежа указано начало, а незавершённые отмечены open
+type competitorJSON struct {
+	standingJSON
+	Laps        []lapDetailJSON `json:"laps"`
+	Penalties   []lapDetailJSON `json:"penalties"`
+	RangeVisits []lapDetailJSON `json:"rangeVisits"`
+}
+
+type lapDetailJSON struct {
+	lapJSON
+	Start string `json:"start,omitempty"`
+	Open  bool   `json:"open,omitempty"`
+}
+
+// CompetitorJSON возвращает подробные данные участника по строке таблицы row
+// для живых отчётов
+func CompetitorJSON(row race.Result) any {
+	return competitorJSON{
+		standingJSON: newStandingJSON(row),
+		Laps:         lapDetailsJSON(row.Laps),
+		Penalties:    lapDetailsJSON(row.Penalties),
+		RangeVisits:  lapDetailsJSON(row.RangeVisits),
+	}
+}
+
+// StandingsJSON возвращает строки таблицы в структуре JSON-отчёта для
+// вложения в ответы живых отчётов
+func StandingsJSON(rows []race.Result) any {
+	return standingsToJSON(rows)
+}
+
+func lapDetailsJSON(laps []race.LapResult) []lapDetailJSON {
+	summary := lapsJSON(laps)
+	out := make([]lapDetailJSON, 0, len(laps))
+	for i, lap := range laps {
+		detail := lapDetailJSON{lapJSON: summary[i], Open: lap.Incomplete}
+		if !lap.Start.IsZero() {
+			detail.Start = lap.Start.Format(parser.TimeFormat)
+		}
+		out = append(out, detail)
+	}
+	return out
+}
+
+func lapsJSON(laps []race.LapResult) []lapJSON {
+	out := make([]lapJSON, 0, len(laps))
+	for _, lap := range laps {
+		if lap.Incomplete {
+			out = append(out, lapJSON{})
+			continue
+		}
+		if lap.Invalid {
+			out = append(out, lapJSON{Invalid: true})
+			continue
+		}
+		out = append(out, lapJSON{Time: FormatDuration(lap.Time), Speed: roundSpeed(lap.Speed)})
+	}
+	return out
+}
+
+// FormatDuration выводит длительность в формате HH:MM:SS.sss
+func FormatDuration(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60, d.Milliseconds()%1000)
+}
+
+// count выводит число n со словом word, во множественном числе — с -s
+func count(n int, word string) string {
+	if n == 1 {
+		return "1 " + word
+	}
+	return strconv.Itoa(n) + " " + word + "s"
+}
+
+// commentEscaper экранирует в комментарии скобки, чтобы строка отчёта
+// оставалась разбираемой: комментарий выводится в круглых скобках
+var commentEscaper = strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`, "\n", " ", "\r", " ")
+
+func escapeComment(comment string) string {
+	return commentEscaper.Replace(comment)
+}
+
+// formatBehind выводит отставание от лидера как +SS.s, +M:SS.s или
+// +H:MM:SS.s; у лидера отставания нет
+func formatBehind(place int, behind time.Duration) string {
+	if place == 1 && behind == 0 {
+		return "-"
+	}
+
+	tenths := behind.Milliseconds() / 100
+	hours, minutes, seconds := tenths/36000, tenths/600%60, tenths/10%60
+	switch {
+	case hours > 0:
+		return fmt.Sprintf("+%d:%02d:%02d.%d", hours, minutes, seconds, tenths%10)
+	case minutes > 0:
+		return fmt.Sprintf("+%d:%02d.%d", minutes, seconds, tenths%10)
+	default:
+		return fmt.Sprintf("+%02d.%d", seconds, tenths%10)
+	}
+}
+
+// roundSpeed округляет скорость до тех же трёх знаков, что и в текстовом
+// отчёте; неопределённая скорость — 0, то есть отсутствует в JSON
+func roundSpeed(speed float64) float64 {
+	if math.IsInf(speed, 0) || math.IsNaN(speed) {
+		return 0
+	}
+	return math.Round(speed*1000) / 1000
+}
+
+// FormatSpeed выводит скорость с тремя знаками после запятой. Скорость
+// круга нулевой длительности не определена и выводится как "-", а не +Inf.
+func FormatSpeed(speed float64) string {
+	if math.IsInf(speed, 0) || math.IsNaN(speed) {
+		return "-"
+	}
+	return strconv.FormatFloat(speed, 'f', 3, 64)
+}
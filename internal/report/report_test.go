@@ -0,0 +1,465 @@
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"biathlon_system/internal/race"
+	"biathlon_system/parser"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// testConfigJSON — параметры гонки из примера README
+const testConfigJSON = `{
+	"laps": 2,
+	"lapLen": 3500,
+	"penaltyLen": 150,
+	"firingLines": 2,
+	"start": "10:00:00.000",
+	"startDelta": "00:01:30"
+}`
+
+// raceResults обрабатывает события из testdata/name и возвращает строки
+// итоговой таблицы; extra дополняет или заменяет параметры testConfigJSON
+func raceResults(t *testing.T, name, extra string) ([]race.Result, race.Config) {
+	t.Helper()
+	return raceResultsWith(t, name, extra, race.Options{Logger: race.NopLogger{}})
+}
+
+// raceResultsWith — raceResults с параметрами запуска opts
+func raceResultsWith(t *testing.T, name, extra string, opts race.Options) ([]race.Result, race.Config) {
+	t.Helper()
+
+	v := viper.New()
+	v.SetConfigType("json")
+	if err := v.ReadConfig(strings.NewReader(testConfigJSON)); err != nil {
+		t.Fatal(err)
+	}
+	if extra != "" {
+		if err := v.MergeConfig(strings.NewReader(extra)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configs, err := race.LoadConfigs(v)
+	if err != nil {
+		t.Fatalf("LoadConfigs: %v", err)
+	}
+	cfg := configs.WithOptions(opts).Base
+
+	file, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	r := race.New(cfg)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		ev, err := parser.ParseEvent(scanner.Text())
+		if err != nil {
+			t.Fatalf("строка %d: %v", line, err)
+		}
+		ev.Line = line
+		if err := r.Apply(ev); err != nil {
+			t.Fatalf("строка %d: %v", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	r.Finalize()
+	return r.Results(), r.Config()
+}
+
+// writeString выводит строки таблицы в формате writer
+func writeString(t *testing.T, writer Writer, rows []race.Result, cfg race.Config) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := writer.Write(&buf, rows, cfg); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestTextOptionalColumns(t *testing.T) {
+	rows, cfg := raceResults(t, "events", "")
+	rows = rows[:2]
+
+	got := writeString(t, Text{}, rows, cfg)
+	want := `{00:25:18.356} 2 [{00:12:38.243, 4.616}, {00:12:38.610, 4.614}] [{00:00:50.000, 3.000}, {00:00:50.000, 3.000}] 8/10
+{00:25:26.047} 1 [{00:12:33.636, 4.644}, {00:12:50.667, 4.542}] [{00:01:40.000, 1.500}, {00:00:50.000, 3.000}] 7/10
+`
+	if got != want {
+		t.Errorf("строки без дополнительных колонок:\n%s\nожидалось:\n%s", got, want)
+	}
+
+	all := Text{WithPenaltySummary: true, WithBouts: true, WithRange: true, WithPenaltyCheck: true, WithBehind: true, WithAvgSpeed: true}
+	got = writeString(t, all, rows, cfg)
+	want = `{00:25:18.356} 2 [{00:12:38.243, 4.616}, {00:12:38.610, 4.614}] [{00:00:50.000, 3.000}, {00:00:50.000, 3.000}] {2 loops, 00:01:40.000} 8/10 (4+4) {range 00:00:13.633} 2/2 - {avg 4.808}
+{00:25:26.047} 1 [{00:12:33.636, 4.644}, {00:12:50.667, 4.542}] [{00:01:40.000, 1.500}, {00:00:50.000, 3.000}] {2 loops, 00:02:30.000} 7/10 (3+4) {range 00:00:12.971} 2/3 +07.6 {avg 4.784}
+`
+	if got != want {
+		t.Errorf("строки со всеми колонками:\n%s\nожидалось:\n%s", got, want)
+	}
+}
+
+func TestNotFinishedComment(t *testing.T) {
+	rows, cfg := raceResults(t, "dnf_comment", "")
+	got := writeString(t, Text{}, rows, cfg)
+	want := `[NotFinished] 1 [{,}] [] 0/10 (Fell on descent  [medical attention])
+[NotFinished] 2 [{,}] [] 0/10 (  two  leading spaces and [brackets] [)
+`
+	if got != want {
+		t.Errorf("строки сошедших участников:\n%s\nожидалось:\n%s", got, want)
+	}
+}
+
+func TestTextInvalidIntervals(t *testing.T) {
+	// В testdata/skewed часы рубежа и финишного створа расходятся: у участника 1
+	// отрицательны второй круг и штрафной круг, у участника 2 — первый круг и
+	// время гонки
+	rows, cfg := raceResults(t, "skewed", "")
+	got := writeString(t, Text{}, rows, cfg)
+	want := `{00:11:00.000} 1 [{00:11:59.000, 4.868}, {invalid}] [{invalid}] 0/10
+[Invalid] 2 [{invalid}, {00:00:20.000, 175.000}] [] 0/10
+`
+	if got != want {
+		t.Errorf("таблица по ленте с расхождением часов:\n%s\nожидалось:\n%s", got, want)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{7*time.Minute + 3*time.Second + 5*time.Millisecond, "00:07:03.005"},
+		{7*time.Minute + 3*time.Second + 50*time.Millisecond, "00:07:03.050"},
+		{7*time.Minute + 3*time.Second + 500*time.Millisecond, "00:07:03.500"},
+		{0, "00:00:00.000"},
+		{25*time.Hour + time.Millisecond, "25:00:00.001"},
+	}
+	for _, tt := range tests {
+		if got := FormatDuration(tt.d); got != tt.want {
+			t.Errorf("FormatDuration(%s) = %s, ожидалось %s", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestTextRankExAequo(t *testing.T) {
+	// Участники 1 и 2 делят первое место, следующее место — третье
+	rows, cfg := raceResults(t, "ex_aequo", "")
+	got := writeString(t, Text{WithRank: true}, rows, cfg)
+	want := `1 {00:24:00.000} 1 [{00:12:00.000, 4.861}, {00:12:00.000, 4.861}] [] 0/10
+1 {00:24:00.000} 2 [{00:12:00.000, 4.861}, {00:12:00.000, 4.861}] [] 0/10
+3 {00:25:00.000} 3 [{00:12:30.000, 4.667}, {00:12:30.000, 4.667}] [] 0/10
+- [NotFinished] 4 [{,}] [] 0/10 (Broken pole)
+`
+	if got != want {
+		t.Errorf("таблица с местами:\n%s\nожидалось:\n%s", got, want)
+	}
+	if got := writeString(t, Text{}, rows, cfg); strings.HasPrefix(got, "1 ") {
+		t.Errorf("место выведено без -with-rank:\n%s", got)
+	}
+}
+
+func TestFormatBehind(t *testing.T) {
+	tests := []struct {
+		place  int
+		behind time.Duration
+		want   string
+	}{
+		{1, 0, "-"},
+		{2, 90 * time.Millisecond, "+00.0"},
+		{2, 7600 * time.Millisecond, "+07.6"},
+		{2, 59900 * time.Millisecond, "+59.9"},
+		{2, time.Minute, "+1:00.0"},
+		{2, time.Minute + 23400*time.Millisecond, "+1:23.4"},
+		{2, time.Hour - 100*time.Millisecond, "+59:59.9"},
+		{2, time.Hour, "+1:00:00.0"},
+		{2, 2*time.Hour + 3*time.Minute + 4500*time.Millisecond, "+2:03:04.5"},
+	}
+	for _, tt := range tests {
+		if got := formatBehind(tt.place, tt.behind); got != tt.want {
+			t.Errorf("formatBehind(%d, %s) = %s, ожидалось %s", tt.place, tt.behind, got, tt.want)
+		}
+	}
+}
+
+func TestRaceTypeProfiles(t *testing.T) {
+	tests := []struct {
+		raceType, fixture, extra string
+	}{
+		{"sprint", "events", ""},
+		// 5 кругов и 4 огневых рубежа, участник 2 пропустил третий
+		{"individual", "individual", `"laps": 5, "lapLen": 3000, "firingLines": 4, "startDelta": "00:00:30"`},
+		// Участник 2 финишировал первым, участник 1 — раньше участника 3,
+		// хотя время гонки у участника 3 лучше
+		{"pursuit", "pursuit", ""},
+		// Общий старт в 10:00:00, участник 3 ушёл со старта на 5 секунд позже
+		{"massstart", "massstart", ""},
+	}
+	for _, tt := range tests {
+		extra := `{"raceType": "` + tt.raceType + `"`
+		if tt.extra != "" {
+			extra += ", " + tt.extra
+		}
+		rows, cfg := raceResults(t, tt.fixture, extra+"}")
+		golden, err := os.ReadFile(filepath.Join("testdata", tt.raceType+".golden"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := writeString(t, Text{WithBouts: true}, rows, cfg); got != string(golden) {
+			t.Errorf("%s: таблица\n%s\nэталон testdata/%s.golden:\n%s", tt.raceType, got, tt.raceType, golden)
+		}
+	}
+}
+
+func TestTextStatusComments(t *testing.T) {
+	// Комментарии из нескольких слов со скобками и обратной косой чертой и
+	// дисквалификация за поздний старт
+	rows, cfg := raceResults(t, "comments", "")
+	golden, err := os.ReadFile(filepath.Join("testdata", "comments.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := writeString(t, Text{}, rows, cfg); got != string(golden) {
+		t.Errorf("таблица с комментариями:\n%s\nэталон testdata/comments.golden:\n%s", got, golden)
+	}
+}
+
+func TestTextGolden(t *testing.T) {
+	rows, cfg := raceResults(t, "events", "")
+	golden, err := os.ReadFile(filepath.Join("testdata", "events.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := writeString(t, Formats["text"], rows, cfg); got != string(golden) {
+		t.Errorf("текстовый отчёт:\n%s\nэталон testdata/events.golden:\n%s", got, golden)
+	}
+}
+
+func TestFormatsDoNotModifyRows(t *testing.T) {
+	// Writer только форматирует: строки таблицы после записи не меняются
+	for name, writer := range Formats {
+		rows, cfg := raceResults(t, "events", "")
+		before := fmt.Sprintf("%+v", rows)
+		writeString(t, writer, rows, cfg)
+		if after := fmt.Sprintf("%+v", rows); after != before {
+			t.Errorf("формат %s изменил строки таблицы", name)
+		}
+	}
+}
+
+func TestTextTargetsPerLine(t *testing.T) {
+	// 4 мишени на рубеже: чистая стрельба на первом рубеже и ни одного
+	// попадания на втором — 4/8 и один заход на штрафной круг из четырёх
+	// положенных
+	rows, cfg := raceResults(t, "targets4", `{"targetsPerLine": 4}`)
+	got := writeString(t, Text{WithBouts: true, WithPenaltyCheck: true}, rows, cfg)
+	want := "{00:30:00.000} 1 [{00:12:59.000, 4.493}, {00:17:00.000, 3.431}] [{00:03:20.000, 0.750}] 4/8 (4+0) 1/4\n"
+	if got != want {
+		t.Errorf("таблица с 4 мишенями:\n%s\nожидалось:\n%s", got, want)
+	}
+}
+
+func TestQualifiedTieAtCutoff(t *testing.T) {
+	// Участники 1 и 2 делят первое место при квоте 1: проходят оба
+	var logs bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&logs)
+	rows, cfg := raceResultsWith(t, "ex_aequo", "", race.Options{Logger: logger, QualifyTop: 1})
+
+	want := map[string]bool{"1": true, "2": true, "3": false, "4": false}
+	for _, row := range rows {
+		if row.Qualified != want[row.ID] {
+			t.Errorf("участник %s: qualified %t, ожидалось %t", row.ID, row.Qualified, want[row.ID])
+		}
+	}
+	if !strings.Contains(logs.String(), "Квалификацию прошли 2 участников при квоте 1") {
+		t.Errorf("в журнале нет предупреждения о превышении квоты:\n%s", logs.String())
+	}
+
+	text := strings.Split(writeString(t, Text{}, rows, cfg), "\n")
+	for i, row := range rows {
+		if strings.HasSuffix(text[i], " Q") != want[row.ID] {
+			t.Errorf("строка %q: отметка Q, ожидалось %t", text[i], want[row.ID])
+		}
+	}
+
+	var standings []struct {
+		Competitor string `json:"competitor"`
+		Qualified  bool   `json:"qualified"`
+	}
+	if err := json.Unmarshal([]byte(writeString(t, JSON{}, rows, cfg)), &standings); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range standings {
+		if row.Qualified != want[row.Competitor] {
+			t.Errorf("JSON: участник %s: qualified %t, ожидалось %t", row.Competitor, row.Qualified, want[row.Competitor])
+		}
+	}
+
+	records, err := csv.NewReader(strings.NewReader(writeString(t, CSV{}, rows, cfg))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	column := len(records[0]) - 1
+	if records[0][column] != "qualified" {
+		t.Fatalf("последний столбец CSV %s, ожидался qualified", records[0][column])
+	}
+	for _, record := range records[1:] {
+		if got := record[column] == "true"; got != want[record[1]] {
+			t.Errorf("CSV: участник %s: qualified %s, ожидалось %t", record[1], record[column], want[record[1]])
+		}
+	}
+}
+
+// mixedRows — двое финишировавших из testdata/events, сошедший с
+// комментарием, требующим экранирования, и не стартовавший
+func mixedRows(t *testing.T) ([]race.Result, race.Config) {
+	t.Helper()
+
+	rows, cfg := raceResults(t, "events", "")
+	dnf, _ := raceResults(t, "comments", "")
+	rows = append(rows[:2], dnf[0], dnf[2])
+	rows[2].ID = "4"
+	rows[2].Comment = `Упал | <b>"лыжа"</b> & палка`
+	return rows, cfg
+}
+
+func TestHTMLGolden(t *testing.T) {
+	rows, cfg := mixedRows(t)
+	golden, err := os.ReadFile(filepath.Join("testdata", "html.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := writeString(t, Formats["html"], rows, cfg); got != string(golden) {
+		t.Errorf("HTML-отчёт:\n%s\nэталон testdata/html.golden:\n%s", got, golden)
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	rows, cfg := mixedRows(t)
+	got := writeString(t, Formats["md"], rows, cfg)
+	want := `| Место | Участник | Время | Отставание | Стрельба | Штраф |
+|------:|----------|------:|-----------:|---------:|------:|
+| 1 | 2 | 00:25:18.356 | - | 8/10 | 2 × 00:01:40.000 |
+| 2 | 1 | 00:25:26.047 | +07.6 | 7/10 | 2 × 00:02:30.000 |
+| - | 4 | NotFinished (Упал \| &lt;b&gt;"лыжа"&lt;/b&gt; & палка) |  | 0/10 | 0 × 00:00:00.000 |
+| - | 3 | NotStarted (Дисквалифицирован: старт после допустимого времени) |  | 0/10 | 0 × 00:00:00.000 |
+
+<details><summary>2</summary>
+
+- Круг 1: 00:12:38.243, 4.616 м/с
+- Круг 2: 00:12:38.610, 4.614 м/с
+
+</details>
+
+<details><summary>1</summary>
+
+- Круг 1: 00:12:33.636, 4.644 м/с
+- Круг 2: 00:12:50.667, 4.542 м/с
+
+</details>
+
+<details><summary>4</summary>
+
+- Круг 1: не завершён
+
+</details>
+
+<details><summary>3</summary>
+
+- Круг 1: не завершён
+
+</details>
+`
+	if got != want {
+		t.Errorf("Markdown-отчёт:\n%s\nожидалось:\n%s", got, want)
+	}
+}
+
+func TestPretty(t *testing.T) {
+	rows, cfg := mixedRows(t)
+	got := writeString(t, Pretty{}, rows, cfg)
+	want := `Место  Номер  Время         Отставание  Стрельба  Штраф             Круги
+1      2      00:25:18.356  -           8/10      2 × 00:01:40.000  00:12:38.243 00:12:38.610
+2      1      00:25:26.047  +07.6       7/10      2 × 00:02:30.000  00:12:33.636 00:12:50.667
+-      4      NotFinished               0/10      0 × 00:00:00.000  -
+-      3      NotStarted                0/10      0 × 00:00:00.000  -
+`
+	if got != want {
+		t.Errorf("выровненная таблица:\n%s\nожидалось:\n%s", got, want)
+	}
+
+	// Круги, не помещающиеся в столбец, обрезаются с многоточием
+	rows, cfg = raceResults(t, "individual", `{"raceType": "individual", "laps": 5, "lapLen": 3000, "firingLines": 4, "startDelta": "00:00:30"}`)
+	got = writeString(t, Pretty{}, rows, cfg)
+	want = `Место  Номер  Время         Отставание  Стрельба  Штраф             Круги
+1      1      00:54:10.750  -           17/20     0 × 00:00:00.000  00:10:00.000 00:10:07.050 00:10:14.100 …
+2      2      00:59:25.850  +5:15.1     12/20     0 × 00:00:00.000  00:10:03.000 00:10:10.050 00:10:17.100 …
+`
+	if got != want {
+		t.Errorf("выровненная таблица с пятью кругами:\n%s\nожидалось:\n%s", got, want)
+	}
+}
+
+func TestZeroLengthLapSpeed(t *testing.T) {
+	// Первый круг закрыт в момент старта: бесконечная скорость в отчёт не
+	// попадает
+	rows, cfg := raceResults(t, "zerolap", "")
+	tests := []struct {
+		writer Writer
+		want   string
+	}{
+		{Text{WithAvgSpeed: true}, "{00:12:00.000} 1 [{00:00:00.000, -}, {00:12:00.000, 4.861}] [] 0/10 {avg 9.722}\n"},
+		{Formats["csv"], `place,id,status,total_time,lap1_time,lap1_speed,lap2_time,lap2_speed,penalty_time,penalty_loops,hits,shots,comment
+1,1,Finished,00:12:00.000,00:00:00.000,-,00:12:00.000,4.861,00:00:00.000,0,0,10,
+`},
+	}
+	for _, tt := range tests {
+		if got := writeString(t, tt.writer, rows, cfg); got != tt.want {
+			t.Errorf("%T:\n%s\nожидалось:\n%s", tt.writer, got, tt.want)
+		}
+	}
+	for name, writer := range Formats {
+		if got := writeString(t, writer, rows, cfg); strings.Contains(got, "Inf") || strings.Contains(got, "NaN") {
+			t.Errorf("формат %s: бесконечная скорость в отчёте:\n%s", name, got)
+		}
+	}
+}
+
+func TestFiringScheduleForms(t *testing.T) {
+	// Отметки по кругам и номера кругов задают одно расписание: знаменатель
+	// стрельбы — 4 рубежа по 5 мишеней, как в testdata/individual.golden
+	golden, err := os.ReadFile(filepath.Join("testdata", "individual.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, schedule := range []string{`[1, 1, 1, 1, 0]`, `[1, 2, 3, 4]`} {
+		var logs bytes.Buffer
+		logger := logrus.New()
+		logger.SetOutput(&logs)
+		rows, cfg := raceResultsWith(t, "individual", `{"raceType": "individual", "laps": 5, "lapLen": 3000, "firingLines": 4,
+			"startDelta": "00:00:30", "firingSchedule": `+schedule+`}`, race.Options{Logger: logger})
+		if got := writeString(t, Text{WithBouts: true}, rows, cfg); got != string(golden) {
+			t.Errorf("firingSchedule %s: таблица\n%s\nэталон testdata/individual.golden:\n%s", schedule, got, golden)
+		}
+		// Участник 2 пропустил третий рубеж
+		if !strings.Contains(logs.String(), "участник 2 закончил круг 3, пропустив стрельбу по расписанию") {
+			t.Errorf("firingSchedule %s: нет предупреждения о пропущенной стрельбе:\n%s", schedule, logs.String())
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/template"
+	"time"
+
+	"biathlon_system/internal/race"
+	"biathlon_system/parser"
+)
+
+// Данные пользовательского шаблона отчёта (-template):
+//
+//	.Race         — параметры гонки: RaceType, Laps, LapLen, PenaltyLen,
+//	                FiringLines, Targets, Start, StartDelta (time.Time)
+//	.Competitors  — участники в порядке итоговой таблицы, см. templateCompetitor
+//
+// Функции шаблона: duration (длительность как HH:MM:SS.sss), speed (скорость
+// с тремя знаками), clock (время суток как HH:MM:SS.sss), inc (n+1).
+
+type templateData struct {
+	Race        templateRace
+	Competitors []templateCompetitor
+}
+
+type templateRace struct {
+	RaceType                                       string
+	Laps, LapLen, PenaltyLen, FiringLines, Targets int
+	Start, StartDelta                              time.Time
+}
+
+type templateCompetitor struct {
+	Place        int // 0 — без места
+	ID           string
+	Status       string // Finished, Running, Invalid, NotFinished, NotStarted
+	TotalTime    time.Duration
+	MissPenalty  time.Duration // штраф за промахи в TotalTime
+	Behind       time.Duration
+	AvgSpeed     float64
+	Laps         []templateLap
+	Penalties    []templateLap
+	PenaltyLoops int
+	PenaltyTime  time.Duration
+	PenaltySkied int
+	PenaltyOwed  int // -1 — проверка штрафных кругов отключена
+	RangeTime    time.Duration
+	Hits         int
+	Shots        int
+	BoutHits     []int
+	Comment      string
+	Provisional  bool
+}
+
+type templateLap struct {
+	Time       time.Duration
+	Speed      float64
+	Incomplete bool
+	Invalid    bool
+}
+
+// builtinTemplates — шаблоны, доступные в -template по имени
+var builtinTemplates = map[string]string{
+	"compact":  "templates/compact.tmpl",
+	"detailed": "templates/detailed.tmpl",
+}
+
+var templateFuncs = template.FuncMap{
+	"duration": FormatDuration,
+	"speed":    FormatSpeed,
+	"clock": func(t time.Time) string {
+		return t.Format(parser.TimeFormat)
+	},
+	"inc": func(n int) int {
+		return n + 1
+	},
+}
+
+// LoadTemplate разбирает шаблон: встроенный по имени или из файла.
+// Вызывается до обработки событий, чтобы ошибка в шаблоне (с номером строки)
+// обнаружилась сразу, а не после всей гонки.
+func LoadTemplate(name string) (*Template, error) {
+	var text []byte
+	var err error
+	if path, ok := builtinTemplates[name]; ok {
+		text, err = templatesFS.ReadFile(path)
+	} else {
+		text, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Ошибка чтения шаблона отчёта: %w", err)
+	}
+
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("Ошибка в шаблоне отчёта: %w", err)
+	}
+	return &Template{tmpl: tmpl}, nil
+}
+
+// Template выводит итоговую таблицу через шаблон text/template из -template
+type Template struct {
+	tmpl *template.Template
+}
+
+func (t *Template) Write(w io.Writer, rows []race.Result, cfg race.Config) error {
+	data := templateData{Race: templateRace{
+		RaceType:    cfg.RaceType,
+		Laps:        cfg.Laps,
+		LapLen:      cfg.LapLen,
+		PenaltyLen:  cfg.PenaltyLen,
+		FiringLines: cfg.FiringLines,
+		Targets:     cfg.Targets,
+		Start:       cfg.Start,
+		StartDelta:  time.Time{}.Add(cfg.StartDelta),
+	}}
+
+	for _, row := range rows {
+		competitor := templateCompetitor{
+			Place:        row.Place,
+			ID:           row.ID,
+			Status:       row.Status(),
+			TotalTime:    row.TotalTime,
+			MissPenalty:  row.MissPenalty,
+			Behind:       row.Behind,
+			AvgSpeed:     row.AvgSpeed,
+			Laps:         templateLaps(row.Laps),
+			Penalties:    templateLaps(row.Penalties),
+			PenaltyLoops: row.PenaltyLoops,
+			PenaltyTime:  row.PenaltyTotal,
+			PenaltySkied: row.PenaltySkied,
+			PenaltyOwed:  row.PenaltyOwed,
+			RangeTime:    row.RangeTotal,
+			Hits:         row.Hits,
+			Shots:        row.Shots,
+			BoutHits:     row.BoutHits,
+			Provisional:  row.Provisional,
+		}
+		if row.NotStarted || row.NotFinished {
+			competitor.Comment = row.Comment
+		}
+		data.Competitors = append(data.Competitors, competitor)
+	}
+
+	return t.tmpl.Execute(w, data)
+}
+
+func templateLaps(laps []race.LapResult) []templateLap {
+	out := make([]templateLap, 0, len(laps))
+	for _, lap := range laps {
+		out = append(out, templateLap{Time: lap.Time, Speed: lap.Speed, Incomplete: lap.Incomplete, Invalid: lap.Invalid})
+	}
+	return out
+}
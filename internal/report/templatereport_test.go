@@ -0,0 +1,50 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinTemplates(t *testing.T) {
+	rows, cfg := raceResults(t, "events", "")
+	for _, name := range []string{"compact", "detailed"} {
+		tmpl, err := LoadTemplate(name)
+		if err != nil {
+			t.Fatalf("LoadTemplate(%s): %v", name, err)
+		}
+		golden, err := os.ReadFile(filepath.Join("testdata", name+".golden"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := writeString(t, tmpl, rows, cfg); got != string(golden) {
+			t.Errorf("шаблон %s:\n%s\nэталон testdata/%s.golden:\n%s", name, got, name, golden)
+		}
+	}
+}
+
+func TestTemplateErrorLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.tmpl")
+	text := "{{range .Competitors}}\n{{.ID}} {{duration .TotalTime}}\n{{.Place | nosuchfunc}}\n{{end}}\n"
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadTemplate(path)
+	if err == nil || !strings.Contains(err.Error(), path+":3:") {
+		t.Errorf("ошибка %v, ожидалась ошибка в строке 3 шаблона", err)
+	}
+
+	// Отсутствующее поле обнаруживается только при выводе, тоже с номером строки
+	if err := os.WriteFile(path, []byte("{{range .Competitors}}\n{{.Name}}\n{{end}}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := LoadTemplate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, cfg := raceResults(t, "events", "")
+	if err := tmpl.Write(&strings.Builder{}, rows, cfg); err == nil || !strings.Contains(err.Error(), path+":2:") {
+		t.Errorf("ошибка %v, ожидалась ошибка в строке 2 шаблона", err)
+	}
+}
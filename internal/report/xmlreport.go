@@ -0,0 +1,178 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"biathlon_system/internal/race"
+	"biathlon_system/parser"
+)
+
+// Структура XML-отчёта для обмена результатами с системой федерации:
+//
+//	<Race laps="2" lapLen="3500" penaltyLen="150" firingLines="2" start="10:00:00.000">
+//	  <Competitor id="1" place="1">
+//	    <Result status="Finished" totalTime="00:25:18.356" totalTimeISO="PT25M18.356S"></Result>
+//	    <Laps>
+//	      <Lap n="1" time="00:12:38.243" timeISO="PT12M38.243S" speed="4.616"></Lap>
+//	    </Laps>
+//	    <Penalties loops="2" time="00:01:40.000" timeISO="PT1M40S">
+//	      <Lap n="1" time="00:00:50.000" timeISO="PT50S" speed="3.000"></Lap>
+//	    </Penalties>
+//	    <Shooting hits="8" shots="10"></Shooting>
+//	    <Comment>...</Comment>
+//	  </Competitor>
+//	</Race>
+//
+// Незавершённые круги выводятся без времени и скорости, круги с отрицательной
+// длительностью — с атрибутом invalid="true".
+
+type XMLRace struct {
+	XMLName     xml.Name        `xml:"Race"`
+	RaceType    string          `xml:"type,attr,omitempty"`
+	Laps        int             `xml:"laps,attr"`
+	LapLen      int             `xml:"lapLen,attr"`
+	PenaltyLen  int             `xml:"penaltyLen,attr"`
+	FiringLines int             `xml:"firingLines,attr"`
+	Start       string          `xml:"start,attr"`
+	Competitors []XMLCompetitor `xml:"Competitor"`
+}
+
+type XMLCompetitor struct {
+	ID        string       `xml:"id,attr"`
+	Place     int          `xml:"place,attr,omitempty"`
+	Points    int          `xml:"points,attr,omitempty"`
+	Result    XMLResult    `xml:"Result"`
+	Laps      []XMLLap     `xml:"Laps>Lap"`
+	Penalties XMLPenalties `xml:"Penalties"`
+	Shooting  XMLShooting  `xml:"Shooting"`
+	Comment   string       `xml:"Comment,omitempty"`
+}
+
+type XMLResult struct {
+	Status       string `xml:"status,attr"`
+	TotalTime    string `xml:"totalTime,attr,omitempty"`
+	TotalTimeISO string `xml:"totalTimeISO,attr,omitempty"`
+}
+
+type XMLLap struct {
+	N       int    `xml:"n,attr"`
+	Time    string `xml:"time,attr,omitempty"`
+	TimeISO string `xml:"timeISO,attr,omitempty"`
+	Speed   string `xml:"speed,attr,omitempty"`
+	Invalid bool   `xml:"invalid,attr,omitempty"`
+}
+
+type XMLPenalties struct {
+	Loops   int      `xml:"loops,attr"`
+	Time    string   `xml:"time,attr"`
+	TimeISO string   `xml:"timeISO,attr"`
+	Laps    []XMLLap `xml:"Lap"`
+}
+
+type XMLShooting struct {
+	Hits  int `xml:"hits,attr"`
+	Shots int `xml:"shots,attr"`
+}
+
+// newXMLRace строит XML-отчёт по строкам итоговой таблицы
+func newXMLRace(rows []race.Result, cfg race.Config) XMLRace {
+	race := XMLRace{
+		RaceType:    cfg.RaceType,
+		Laps:        cfg.Laps,
+		LapLen:      cfg.LapLen,
+		PenaltyLen:  cfg.PenaltyLen,
+		FiringLines: cfg.FiringLines,
+		Start:       cfg.Start.Format(parser.TimeFormat),
+	}
+
+	for _, row := range rows {
+		competitor := XMLCompetitor{
+			ID:     row.ID,
+			Place:  row.Place,
+			Points: row.Points,
+			Result: XMLResult{Status: row.Status()},
+			Laps:   xmlLaps(row.Laps),
+			Penalties: XMLPenalties{
+				Loops:   row.PenaltyLoops,
+				Time:    FormatDuration(row.PenaltyTotal),
+				TimeISO: isoDuration(row.PenaltyTotal),
+				Laps:    xmlLaps(row.Penalties),
+			},
+			Shooting: XMLShooting{Hits: row.Hits, Shots: row.Shots},
+		}
+		switch competitor.Result.Status {
+		case "Finished":
+			competitor.Result.TotalTime = FormatDuration(row.TotalTime)
+			competitor.Result.TotalTimeISO = isoDuration(row.TotalTime)
+		case "NotStarted", "NotFinished":
+			competitor.Comment = row.Comment
+		}
+		race.Competitors = append(race.Competitors, competitor)
+	}
+
+	return race
+}
+
+func xmlLaps(laps []race.LapResult) []XMLLap {
+	out := make([]XMLLap, 0, len(laps))
+	for i, lap := range laps {
+		switch {
+		case lap.Incomplete:
+			out = append(out, XMLLap{N: i + 1})
+		case lap.Invalid:
+			out = append(out, XMLLap{N: i + 1, Invalid: true})
+		default:
+			out = append(out, XMLLap{
+				N:       i + 1,
+				Time:    FormatDuration(lap.Time),
+				TimeISO: isoDuration(lap.Time),
+				Speed:   FormatSpeed(lap.Speed),
+			})
+		}
+	}
+	return out
+}
+
+// isoDuration выводит длительность в формате ISO 8601, например PT1H2M3.456S
+func isoDuration(d time.Duration) string {
+	if d == 0 {
+		return "PT0S"
+	}
+
+	var b strings.Builder
+	b.WriteString("PT")
+	if h := int(d.Hours()); h > 0 {
+		fmt.Fprintf(&b, "%dH", h)
+	}
+	if m := int(d.Minutes()) % 60; m > 0 {
+		fmt.Fprintf(&b, "%dM", m)
+	}
+	if ms := d.Milliseconds() % 60000; ms > 0 {
+		seconds := strconv.FormatFloat(float64(ms)/1000, 'f', 3, 64)
+		seconds = strings.TrimRight(strings.TrimRight(seconds, "0"), ".")
+		b.WriteString(seconds + "S")
+	}
+	return b.String()
+}
+
+// XML выводит итоговую таблицу в XML
+type XML struct{}
+
+func (XML) Write(w io.Writer, rows []race.Result, cfg race.Config) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(newXMLRace(rows, cfg)); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,61 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+	"time"
+)
+
+func TestXMLRoundTrip(t *testing.T) {
+	for _, name := range []string{"events", "comments", "skewed"} {
+		rows, cfg := raceResults(t, name, "")
+		out := writeString(t, XML{}, rows, cfg)
+
+		var parsed XMLRace
+		if err := xml.Unmarshal([]byte(out), &parsed); err != nil {
+			t.Fatalf("%s: xml.Unmarshal: %v", name, err)
+		}
+		if len(parsed.Competitors) != len(rows) {
+			t.Fatalf("%s: участников %d, ожидалось %d", name, len(parsed.Competitors), len(rows))
+		}
+		for i, row := range rows {
+			competitor := parsed.Competitors[i]
+			if competitor.ID != row.ID || competitor.Result.Status != row.Status() || len(competitor.Laps) != len(row.Laps) {
+				t.Errorf("%s: участник %d: %+v, ожидался %s %s", name, i+1, competitor, row.ID, row.Status())
+			}
+		}
+
+		// Повторная запись разобранного отчёта даёт тот же файл
+		var again bytes.Buffer
+		again.WriteString(xml.Header)
+		encoder := xml.NewEncoder(&again)
+		encoder.Indent("", "  ")
+		if err := encoder.Encode(parsed); err != nil {
+			t.Fatal(err)
+		}
+		again.WriteString("\n")
+		if again.String() != out {
+			t.Errorf("%s: отчёт после разбора:\n%s\nисходный:\n%s", name, again.String(), out)
+		}
+	}
+}
+
+func TestISODuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "PT0S"},
+		{50 * time.Second, "PT50S"},
+		{time.Minute + 40*time.Second, "PT1M40S"},
+		{25*time.Minute + 18356*time.Millisecond, "PT25M18.356S"},
+		{time.Hour + 2*time.Minute + 3450*time.Millisecond, "PT1H2M3.45S"},
+		{2 * time.Hour, "PT2H"},
+	}
+	for _, tt := range tests {
+		if got := isoDuration(tt.d); got != tt.want {
+			t.Errorf("isoDuration(%s) = %s, ожидалось %s", tt.d, got, tt.want)
+		}
+	}
+}
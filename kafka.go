@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// kafkaConsumer читает события из топика Kafka в составе группы потребителей
+type kafkaConsumer struct {
+	reader *kafka.Reader
+}
+
+func newKafkaConsumer(brokers []string, topic, group string) *kafkaConsumer {
+	return &kafkaConsumer{
+		reader: kafka.NewReader(kafka.ReaderConfig{
+			Brokers: brokers,
+			Topic:   topic,
+			GroupID: group,
+		}),
+	}
+}
+
+func (c *kafkaConsumer) fetch(ctx context.Context) (brokerMessage, error) {
+	msg, err := c.reader.FetchMessage(ctx)
+	if err != nil {
+		return brokerMessage{}, err
+	}
+
+	return brokerMessage{
+		payload: msg.Value,
+		commit: func(ctx context.Context) error {
+			return c.reader.CommitMessages(ctx, msg)
+		},
+	}, nil
+}
+
+func (c *kafkaConsumer) close() error {
+	return c.reader.Close()
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"biathlon_system/internal/race"
+	"biathlon_system/internal/report"
+	"biathlon_system/parser"
+)
+
+const (
+	liveStandingsTop      = 10
+	liveStandingsInterval = time.Second
+)
+
+// livePrinter выводит первые liveStandingsTop строк промежуточной таблицы
+// после окончания круга или финиша (параметр -live-standings), не чаще раза
+// в liveStandingsInterval
+type livePrinter struct {
+	mu    sync.Mutex
+	out   io.Writer
+	clock race.Clock
+	last  time.Time
+	// race возвращает гонку события по её идентификатору
+	race func(raceID string) *race.Race
+}
+
+func newLivePrinter(out io.Writer, clock race.Clock) *livePrinter {
+	return &livePrinter{out: out, clock: clock}
+}
+
+// standingsHooks возвращает обработчики гонки, вызывающие notify после
+// событий, которые меняют порядок в таблице: окончания круга, в том числе
+// последнего, дисквалификации и схода
+func standingsHooks(notify func(ev parser.Event)) *race.Hooks {
+	hook := func(id string, ev parser.Event, snapshot race.Result) { notify(ev) }
+	return &race.Hooks{OnLapComplete: hook, OnDisqualify: hook, OnCannotContinue: hook}
+}
+
+func (p *livePrinter) update(ev parser.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.clock.Now()
+	if p.race == nil || !p.last.IsZero() && now.Sub(p.last) < liveStandingsInterval {
+		return
+	}
+	p.last = now
+
+	current := p.race(ev.Race)
+	standings := race.LiveStandings(current.Results())
+	if len(standings) > liveStandingsTop {
+		standings = standings[:liveStandingsTop]
+	}
+	laps := current.Config().Laps
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s ---\n", ev.TimeStr)
+	for _, row := range standings {
+		if row.Finished {
+			fmt.Fprintf(&b, "%3d %-6s {%s} finished\n", row.Place, row.ID, report.FormatDuration(row.Elapsed))
+		} else {
+			fmt.Fprintf(&b, "%3d %-6s {%s} lap %d/%d\n", row.Place, row.ID, report.FormatDuration(row.Elapsed), row.Laps, laps)
+		}
+	}
+	io.WriteString(p.out, b.String())
+}
@@ -2,293 +2,961 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"errors"
+	"flag"
 	"fmt"
-	"github.com/sirupsen/logrus"
-	"github.com/spf13/viper"
+	"io"
 	"os"
-	"sort"
-	"strconv"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
+
+	"biathlon_system/internal/race"
+	"biathlon_system/internal/report"
+	"biathlon_system/parser"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+type options struct {
+	configPath    string
+	pursuitBasis  string
+	finalPath     string
+	eventsPaths   []string
+	outPath       string
+	outEventsPath string
+	outputFormat  string
+	outputSpec    string
+	templatePath  string
+	template      *report.Template
+	pretty        bool
+	rangeDetail   bool
+	withRank      bool
+	liveStandings bool
+	statusAddr    string
+	format        string
+	follow        bool
+	stopEvent     int
+	reorder       int
+	reorderWindow time.Duration
+	listen        string
+	serve         string
+	udp           string
+
+	// Дополнительные колонки текстового отчёта
+	withPenaltySummary bool
+	withBouts          bool
+	withRange          bool
+	withPenaltyCheck   bool
+	withBehind         bool
+	withAvgSpeed       bool
+
+	kafkaBrokers   string
+	kafkaTopic     string
+	kafkaGroup     string
+	endMarker      string
+	deadLetterPath string
+
+	mqttBroker  string
+	mqttTopic   string
+	mqttControl string
+
+	grpcAddr string
+
+	natsURL            string
+	natsSubject        string
+	natsQueue          string
+	natsControlSubject string
+
+	dir    string
+	outDir string
+
+	checkpointPath  string
+	checkpointEvery int
+	resume          bool
+	dumpStatePath   string
+	loadStatePath   string
+
+	mode string
+	// rejectUnknown — неизвестный ID события — ошибка строки, а не
+	// предупреждение (-reject-unknown-events)
+	rejectUnknown bool
+	raceOptions   race.Options
+	// configs — параметры гонок для сортировки событий по времени (-reorder)
+	configs race.Configs
+}
+
+// Коды завершения программы
+const (
+	exitFailure    = 1 // прочие ошибки, в режиме -dir — гонки с ошибками
+	exitIncomplete = 2 // входные данные прочитаны не полностью
+	exitConfig     = 3 // ошибка параметров запуска или конфигурации
+	exitInput      = 4 // ошибка чтения или обработки событий
+	exitOutput     = 5 // ошибка записи отчётов и состояния
+	exitInvalid    = 6 // недопустимые параметры гонки в конфигурации
 )
 
-type competitorStat struct {
-	registered    bool
-	startTime     time.Time
-	actualStart   time.Time
-	lapsTime      [][2]time.Time
-	penaltyTime   [][2]time.Time
-	hits          int
-	notStarted    bool
-	notFinished   bool
-	finishTime    time.Time
-	totalTime     time.Duration
-	comment       string
-	lapSpeeds     []float64
-	penaltySpeeds []float64
+// exitError — ошибка с кодом завершения программы
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// withExitCode помечает err кодом завершения code, если у неё ещё нет кода
+func withExitCode(code int, err error) error {
+	var exitErr *exitError
+	if err == nil || errors.As(err, &exitErr) {
+		return err
+	}
+	return &exitError{code: code, err: err}
 }
 
-var timeFormat = "15:04:05.000"
+func configError(err error) error { return withExitCode(exitConfig, err) }
+func inputError(err error) error  { return withExitCode(exitInput, err) }
+func outputError(err error) error { return withExitCode(exitOutput, err) }
+
+// exitCode возвращает код завершения для ошибки run
+func exitCode(err error) int {
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	return exitFailure
+}
 
 func main() {
 	logrus.SetFormatter(&logrus.TextFormatter{
 		FullTimestamp:   true,
 		TimestampFormat: "15:04:05.000",
 	})
+	// Стандартный вывод занят отчётом при -out -, журнал идёт только в stderr
+	logrus.SetOutput(os.Stderr)
+
+	// SIGINT и SIGTERM отменяют ctx: приём событий прекращается, а отчёт
+	// записывается по уже обработанным событиям
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := run(ctx, parseFlags())
+	stop()
+
+	// os.Exit вызывается только здесь, после того как отложенные вызовы run
+	// закрыли и дописали файлы
+	if err != nil {
+		logrus.Error(err)
+		os.Exit(exitCode(err))
+	}
+}
 
-	if err := initConfig(); err != nil {
-		logrus.Fatalf("Ошибка инициализации конфигурации: %s", err.Error())
+// run выполняет обработку по параметрам запуска. Ошибка содержит код
+// завершения: ошибки конфигурации, входных данных и записи отчётов
+// различаются.
+func run(ctx context.Context, opts options) error {
+	if err := opts.validate(); err != nil {
+		return configError(fmt.Errorf("Ошибка в параметрах запуска: %w", err))
+	}
+	if opts.templatePath != "" {
+		tmpl, err := report.LoadTemplate(opts.templatePath)
+		if err != nil {
+			return configError(err)
+		}
+		opts.template = tmpl
 	}
 
-	laps := viper.GetInt("laps")
-	lapLen := viper.GetInt("lapLen")
-	penaltyLen := viper.GetInt("penaltyLen")
-	firingLines := viper.GetInt("firingLines")
-	startStr := viper.GetString("start")
-	startDeltaStr := viper.GetString("startDelta")
+	if err := initConfig(opts.configPath); err != nil {
+		return configError(fmt.Errorf("Ошибка инициализации конфигурации: %w", err))
+	}
 
-	start, err := time.Parse(timeFormat[:8], startStr)
+	configs, err := race.LoadConfigs(viper.GetViper())
 	if err != nil {
-		logrus.Fatalf("Ошибка парсинга времени старта: %s", err)
+		err = fmt.Errorf("%s: %w", viper.ConfigFileUsed(), err)
+		var invalid *race.InvalidConfigError
+		if errors.As(err, &invalid) {
+			return &exitError{code: exitInvalid, err: err}
+		}
+		return configError(err)
 	}
-	startDelta, err := time.Parse(timeFormat[:8], startDeltaStr)
-	if err != nil {
-		logrus.Fatalf("Ошибка парсинга времени интервала между стартами: %s", err)
+	if opts.pursuitBasis != "" {
+		if configs.Base.RaceType != race.RacePursuit {
+			return configError(fmt.Errorf("-pursuit-basis требует raceType: %s", race.RacePursuit))
+		}
+		basis, err := loadPursuitBasis(opts.pursuitBasis, configs.Base)
+		if err != nil {
+			return inputError(fmt.Errorf("Ошибка чтения квалификации: %w", err))
+		}
+		opts.raceOptions.PursuitBasis = basis
+	}
+	if opts.finalPath != "" && configs.Base.RaceType != race.RaceSuperSprint {
+		return configError(fmt.Errorf("-final требует raceType: %s", race.RaceSuperSprint))
+	}
+	var live *livePrinter
+	var stream *standingsStream
+	if opts.liveStandings {
+		live = newLivePrinter(os.Stderr, opts.raceOptions.Clock)
+	}
+	if opts.statusAddr != "" {
+		stream = newStandingsStream(opts.raceOptions.Clock)
+	}
+	if live != nil || stream != nil {
+		opts.raceOptions.Hooks = standingsHooks(func(ev parser.Event) {
+			if live != nil {
+				live.update(ev)
+			}
+			if stream != nil {
+				stream.update(ev)
+			}
+		})
+	}
+	configs = configs.WithOptions(opts.raceOptions)
+	opts.configs = configs
+
+	if opts.finalPath != "" {
+		return runSuperSprint(ctx, opts, configs)
+	}
+	if opts.dir != "" {
+		if failed := processDir(ctx, opts, configs); failed > 0 {
+			return &exitError{code: exitFailure, err: fmt.Errorf("гонок с ошибками: %d", failed)}
+		}
+		return nil
+	}
+
+	// События с идентификатором гонки и в -follow, и при приёме по сети идут
+	// в свою гонку races
+	races := race.NewSet(configs)
+	raceOf := races.Race
+	if live != nil {
+		live.race = raceOf
+	}
+	if stream != nil {
+		stream.race = raceOf
+		go func() {
+			if err := serveStatus(ctx, opts.statusAddr, raceOf, stream, opts.raceOptions.Clock); err != nil {
+				logrus.Errorf("Ошибка HTTP-сервера результатов: %s", err)
+			}
+		}()
+	}
+	if opts.streaming() {
+		// Судьи могут поправить параметры между гонками дня без перезапуска
+		watchConfig(viper.GetViper(), races.Reconfigure)
 	}
 
-	fileIncomingEvents, err := os.Open("events")
+	switch {
+	case opts.listen != "":
+		err = serveTCP(ctx, opts.listen, races, opts.newSource())
+	case opts.serve != "":
+		err = serveHTTP(ctx, opts.serve, races, opts.reportWriter(""))
+	case opts.grpcAddr != "":
+		err = serveGRPC(ctx, opts.grpcAddr, races)
+	case opts.udp != "":
+		err = serveUDP(ctx, opts.udp, races, opts.newSource())
+	case opts.kafkaBrokers != "":
+		consumer := newKafkaConsumer(strings.Split(opts.kafkaBrokers, ","), opts.kafkaTopic, opts.kafkaGroup)
+		err = consumeBroker(ctx, opts, consumer, races)
+	case opts.natsURL != "":
+		var consumer *natsConsumer
+		consumer, err = newNATSConsumer(opts.natsURL, opts.natsSubject, opts.natsQueue, opts.natsControlSubject, opts.endMarker)
+		if err == nil {
+			err = consumeBroker(ctx, opts, consumer, races)
+		}
+	case opts.mqttBroker != "":
+		var consumer *mqttConsumer
+		consumer, err = newMQTTConsumer(opts.mqttBroker, opts.mqttTopic, opts.mqttControl, opts.endMarker)
+		if err == nil {
+			err = consumeBroker(ctx, opts, consumer, races)
+		}
+	default:
+		stats := newProcessStats(opts.mode, configs)
+		stats.rejectUnknown = opts.rejectUnknown
+		if err := processFiles(ctx, opts, races, stats); err != nil {
+			return inputError(err)
+		}
+		if err := writeRaceReports(races, opts.reportOutputs(), opts.outEventsPath); err != nil {
+			return outputError(err)
+		}
+		if err := stats.incomplete(); err != nil {
+			return &exitError{code: exitIncomplete, err: err}
+		}
+		return nil
+	}
 	if err != nil {
-		logrus.Fatalf("Ошибка открытия файла событий: %s", err)
+		return inputError(err)
 	}
-	defer fileIncomingEvents.Close()
 
-	scanner := bufio.NewScanner(fileIncomingEvents)
+	return outputError(writeRaceReports(races, opts.reportOutputs(), opts.outEventsPath))
+}
 
-	competitorsStats := make(map[string]*competitorStat)
+// streaming сообщает, что события поступают, пока программа работает:
+// режим -follow или приём событий по сети
+func (o options) streaming() bool {
+	return o.follow || o.listen != "" || o.serve != "" || o.grpcAddr != "" || o.udp != "" ||
+		o.kafkaBrokers != "" || o.mqttBroker != "" || o.natsURL != ""
+}
 
-	for scanner.Scan() {
-		event := scanner.Text()
-		err := handleEvent(event, competitorsStats, start, startDelta, laps)
+func parseFlags() options {
+	var opts options
+	events := flag.String("events", "events", "пути к файлам событий через запятую (\"-\" — стандартный ввод)")
+	flag.StringVar(&opts.configPath, "config", "", "путь к файлу конфигурации json, yaml или toml (по умолчанию config.* в рабочем каталоге, configs/ или каталоге настроек пользователя)")
+	flag.StringVar(&opts.pursuitBasis, "pursuit-basis", "", "итоговая таблица квалификации (JSON или текст): старты гонки преследования по отставаниям")
+	flag.StringVar(&opts.finalPath, "final", "", "файл событий финала суперспринта: -events — квалификация, в финал проходят лучшие qualifiers участников")
+	flag.StringVar(&opts.outPath, "out", "resulting_table", "путь к файлу итогового отчёта (\"-\" — стандартный вывод)")
+	flag.StringVar(&opts.outEventsPath, "out-events", "output_events", "путь к файлу исходящих событий (\"\" — не записывать)")
+	flag.StringVar(&opts.outputFormat, "output-format", "text", "формат итогового отчёта: text, csv, html, md или xml")
+	flag.StringVar(&opts.outputSpec, "output", "", "несколько отчётов за один проход: формат=путь через запятую, например text=resulting_table,json=results.json")
+	flag.BoolVar(&opts.pretty, "pretty", false, "выводить итоговый отчёт выровненной таблицей для чтения в терминале")
+	flag.StringVar(&opts.templatePath, "template", "", "шаблон text/template для итогового отчёта: путь к файлу или встроенный compact/detailed")
+	flag.StringVar(&opts.format, "format", "text", "формат входных событий: text, jsonl или csv")
+	flag.StringVar(&opts.dir, "dir", "", "каталог с архивом гонок: обрабатывается каждый файл events в дереве")
+	flag.StringVar(&opts.outDir, "out-dir", "", "каталог для результатов режима -dir с той же структурой, что и у архива")
+	flag.StringVar(&opts.checkpointPath, "checkpoint", "", "файл контрольной точки с состоянием обработки")
+	flag.IntVar(&opts.checkpointEvery, "checkpoint-every", 1000, "сохранять контрольную точку каждые N событий")
+	flag.BoolVar(&opts.resume, "resume", false, "продолжить обработку с контрольной точки -checkpoint")
+	flag.StringVar(&opts.dumpStatePath, "dump-state", "", "записать состояние гонки в JSON-файл после обработки событий")
+	flag.StringVar(&opts.loadStatePath, "load-state", "", "загрузить состояние гонки из JSON-файла перед обработкой событий")
+	flag.StringVar(&opts.mode, "mode", modeStrict, "режим обработки ошибок: strict — остановка на первой ошибке, lenient — пропуск ошибочных строк со сводкой")
+	flag.BoolVar(&opts.rejectUnknown, "reject-unknown-events", false, "считать событие с неизвестным ID ошибкой строки вместо предупреждения")
+	flag.IntVar(&maxLineSize, "max-line", maxLineSize, "максимальная длина строки событий в байтах")
+	flag.BoolVar(&opts.raceOptions.StrictOfficiating, "strict-officiating", false, "отмечать результат предварительным, если штрафные круги не сходятся с промахами на рубежах")
+	flag.BoolVar(&opts.rangeDetail, "range-detail", false, "выводить в итоговом отчёте время каждого посещения огневого рубежа")
+	flag.IntVar(&opts.raceOptions.QualifyTop, "qualify-top", 0, "отметить в отчёте Q первых N финишировавших; при равном времени на N-м месте проходят все")
+	flag.BoolVar(&opts.liveStandings, "live-standings", false, "в режиме -follow и при приёме по сети выводить в stderr первые 10 участников после кругов и финишей, не чаще раза в секунду")
+	flag.StringVar(&opts.statusAddr, "status", "", "адрес HTTP-сервера текущих результатов в режиме -follow и при приёме по сети: GET /standings и /competitors/{id}")
+	flag.BoolVar(&opts.withRank, "with-rank", false, "выводить в итоговом отчёте место участника; при равном времени место общее")
+	flag.BoolVar(&opts.withPenaltySummary, "with-penalty-summary", false, "выводить в итоговом отчёте число штрафных кругов и их суммарное время")
+	flag.BoolVar(&opts.withBouts, "with-bouts", false, "выводить в итоговом отчёте попадания на каждом огневом рубеже, например (5+3+4+5)")
+	flag.BoolVar(&opts.withRange, "with-range", false, "выводить в итоговом отчёте суммарное время на огневых рубежах")
+	flag.BoolVar(&opts.withPenaltyCheck, "with-penalty-check", false, "выводить в итоговом отчёте пройденные и положенные по промахам штрафные круги")
+	flag.BoolVar(&opts.withBehind, "with-behind", false, "выводить в итоговом отчёте отставание финишировавших от победителя")
+	flag.BoolVar(&opts.withAvgSpeed, "with-avg-speed", false, "выводить в итоговом отчёте среднюю скорость финишировавших на дистанции")
+	flag.BoolVar(&opts.raceOptions.EnforcePenalties, "enforce-penalties", false, "дисквалифицировать участников, не зашедших на штрафной круг после рубежа с промахами")
+	flag.BoolVar(&opts.raceOptions.IgnoreRaceStart, "ignore-race-start", false, "не проверять события по времени старта гонки из конфигурации (тренировки)")
+	flag.BoolVar(&opts.raceOptions.AllowUnregistered, "allow-unregistered", false, "принимать события участников без события регистрации 1")
+	lenient := flag.Bool("lenient", false, "то же, что -mode lenient")
+	flag.BoolVar(&opts.follow, "follow", false, "продолжать чтение файла событий после его конца, как tail -f")
+	flag.IntVar(&opts.reorder, "reorder", 0, "сортировать события в окне из N событий перед обработкой (0 — не сортировать); окно хранится в памяти")
+	flag.DurationVar(&opts.reorderWindow, "reorder-window", 0, "сортировать события, придерживая их на указанное время по часам событий, например 3s")
+	flag.IntVar(&opts.stopEvent, "stop-event", 0, "ID служебного события, завершающего приём событий (0 — не используется)")
+	flag.StringVar(&opts.listen, "listen", "", "адрес для приёма событий по TCP, например :7000")
+	flag.StringVar(&opts.serve, "serve", "", "адрес HTTP-сервера приёма событий (POST /events) и результатов (GET /results)")
+	flag.StringVar(&opts.grpcAddr, "grpc", "", "адрес gRPC-сервера приёма событий и запроса результатов, например :8443")
+	flag.StringVar(&opts.udp, "udp", "", "адрес для приёма событий UDP-датаграммами, например :9999")
+	flag.StringVar(&opts.kafkaBrokers, "kafka-brokers", "", "адреса брокеров Kafka через запятую")
+	flag.StringVar(&opts.kafkaTopic, "kafka-topic", "", "топик Kafka с событиями")
+	flag.StringVar(&opts.kafkaGroup, "kafka-group", "biathlon_system", "группа потребителей Kafka")
+	flag.StringVar(&opts.mqttBroker, "mqtt", "", "адрес MQTT-брокера, например tcp://broker:1883")
+	flag.StringVar(&opts.mqttTopic, "topic", "", "топик MQTT с событиями; сегмент \"+\" содержит номер огневого рубежа")
+	flag.StringVar(&opts.mqttControl, "control-topic", "biathlon/control", "управляющий топик MQTT для сообщения о конце гонки")
+	flag.StringVar(&opts.natsURL, "nats", "", "адрес сервера NATS, например nats://localhost:4222")
+	flag.StringVar(&opts.natsSubject, "subject", "biathlon.events", "тема NATS с событиями")
+	flag.StringVar(&opts.natsQueue, "queue", "", "группа очереди NATS для распределения нагрузки между экземплярами")
+	flag.StringVar(&opts.natsControlSubject, "control-subject", "biathlon.control", "управляющая тема NATS для сообщения \"finalize\"")
+	flag.StringVar(&opts.endMarker, "end-marker", "END", "сообщение брокера, означающее конец гонки")
+	flag.StringVar(&opts.deadLetterPath, "dead-letter", "dead_letter.log", "журнал сообщений брокера, которые не удалось обработать")
+	flag.Parse()
+
+	opts.eventsPaths = strings.Split(*events, ",")
+	if *lenient {
+		opts.mode = modeLenient
+	}
+	opts.raceOptions.Strict = opts.mode == modeStrict
+	opts.raceOptions.Logger = logrus.StandardLogger()
+	opts.raceOptions.Clock = race.SystemClock{}
+	// Позиционные аргументы сохранены для совместимости: biathlon_system -
+	if flag.NArg() > 0 {
+		opts.eventsPaths = flag.Args()
+	}
+
+	return opts
+}
+
+// reportOutputs возвращает отчёты, которые нужно записать: из -output или
+// единственный отчёт -out в формате по умолчанию
+func (o options) reportOutputs() []reportOutput {
+	if o.outputSpec == "" {
+		return []reportOutput{{writer: o.reportWriter(""), path: o.outPath}}
+	}
+	specs, _ := report.ParseOutputs(o.outputSpec)
+	outputs := make([]reportOutput, 0, len(specs))
+	for _, spec := range specs {
+		outputs = append(outputs, reportOutput{writer: o.reportWriter(spec.Format), path: spec.Path})
+	}
+	return outputs
+}
+
+// reportWriter возвращает формат итогового отчёта format; пустой format —
+// формат, выбранный -output-format, -template или -pretty
+func (o options) reportWriter(format string) report.Writer {
+	if format == "" {
+		switch {
+		case o.template != nil:
+			return o.template
+		case o.pretty:
+			return report.Pretty{}
+		}
+		format = o.outputFormat
+	}
+	if format == "text" {
+		return report.Text{
+			RangeDetail:        o.rangeDetail,
+			WithRank:           o.withRank,
+			WithPenaltySummary: o.withPenaltySummary,
+			WithBouts:          o.withBouts,
+			WithRange:          o.withRange,
+			WithPenaltyCheck:   o.withPenaltyCheck,
+			WithBehind:         o.withBehind,
+			WithAvgSpeed:       o.withAvgSpeed,
+		}
+	}
+	return report.Formats[format]
+}
+
+// newSource возвращает конструктор источника событий выбранного формата,
+// при необходимости с сортировкой событий (-reorder, -reorder-window)
+func (o options) newSource() func(io.Reader) eventSource {
+	newSource := eventFormats[o.format]
+	if o.reorder == 0 && o.reorderWindow == 0 {
+		return newSource
+	}
+	return func(r io.Reader) eventSource {
+		return newReorderSource(newSource(r), o.reorder, o.reorderWindow, o.configs)
+	}
+}
+
+func (o options) validate() error {
+	if _, ok := eventFormats[o.format]; !ok {
+		return fmt.Errorf("неизвестный формат событий %s", o.format)
+	}
+	if _, ok := report.Formats[o.outputFormat]; !ok {
+		return fmt.Errorf("неизвестный формат отчёта %s", o.outputFormat)
+	}
+	if o.templatePath != "" && o.outputFormat != "text" {
+		return errors.New("-template и -output-format несовместимы")
+	}
+	if o.pretty && (o.templatePath != "" || o.outputFormat != "text") {
+		return errors.New("-pretty несовместим с -template и -output-format")
+	}
+	if _, err := report.ParseOutputs(o.outputSpec); err != nil {
+		return err
+	}
+	if o.mode != modeStrict && o.mode != modeLenient {
+		return fmt.Errorf("неизвестный режим обработки %s, ожидается strict или lenient", o.mode)
+	}
+	if o.resume && o.checkpointPath == "" {
+		return errors.New("для -resume нужно указать -checkpoint")
+	}
+	if o.resume && o.loadStatePath != "" {
+		return errors.New("-resume и -load-state несовместимы")
+	}
+	if o.reorder < 0 || o.reorderWindow < 0 {
+		return errors.New("-reorder и -reorder-window не могут быть отрицательными")
+	}
+	if maxLineSize < 1 {
+		return errors.New("-max-line должен быть положительным")
+	}
+	if o.liveStandings && !o.streaming() {
+		return errors.New("-live-standings работает только в режиме -follow и при приёме событий по сети")
+	}
+	if o.statusAddr != "" && !o.streaming() {
+		return errors.New("-status работает только в режиме -follow и при приёме событий по сети")
+	}
+	if o.raceOptions.QualifyTop < 0 {
+		return errors.New("-qualify-top не может быть отрицательным")
+	}
+	if o.checkpointEvery < 1 {
+		return errors.New("-checkpoint-every должен быть положительным")
+	}
+
+	if o.dir != "" {
+		info, err := os.Stat(o.dir)
+		if err != nil || !info.IsDir() {
+			return fmt.Errorf("каталог гонок %s не найден", o.dir)
+		}
+		if o.follow {
+			return errors.New("режим -follow несовместим с -dir")
+		}
+	}
+
+	if o.finalPath != "" {
+		if o.dir != "" || o.follow || o.checkpointPath != "" || o.loadStatePath != "" {
+			return errors.New("-final несовместим с -dir, -follow, -checkpoint и -load-state")
+		}
+		if _, err := os.Stat(o.finalPath); err != nil {
+			return fmt.Errorf("файл событий финала %s не найден", o.finalPath)
+		}
+	}
+
+	networkModes := 0
+	if (o.kafkaBrokers == "") != (o.kafkaTopic == "") {
+		return errors.New("для чтения из Kafka нужно указать и -kafka-brokers, и -kafka-topic")
+	}
+	if o.mqttBroker != "" && o.mqttTopic == "" {
+		return errors.New("для чтения из MQTT нужно указать -topic")
+	}
+	for _, addr := range []string{o.listen, o.serve, o.grpcAddr, o.udp, o.kafkaBrokers, o.mqttBroker, o.natsURL} {
+		if addr != "" {
+			networkModes++
+		}
+	}
+	if networkModes > 1 {
+		return errors.New("можно выбрать только один из режимов -listen, -serve, -grpc, -udp, -kafka-brokers, -mqtt и -nats")
+	}
+	if networkModes == 1 {
+		if o.follow {
+			return errors.New("режим -follow несовместим с приёмом событий по сети")
+		}
+		if o.finalPath != "" {
+			return errors.New("-final несовместим с приёмом событий по сети")
+		}
+		return nil
+	}
+	if o.follow && (len(o.eventsPaths) != 1 || o.eventsPaths[0] == "-") {
+		return errors.New("режим -follow поддерживает только один файл событий")
+	}
+
+	for _, path := range o.eventsPaths {
+		if path == "-" {
+			continue
+		}
+
+		info, err := os.Stat(path)
 		if err != nil {
-			logrus.Fatal(err)
+			if errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("файл событий %s не найден", path)
+			}
+			return fmt.Errorf("файл событий %s недоступен: %s", path, err)
+		}
+		if info.IsDir() {
+			return fmt.Errorf("путь к файлу событий %s указывает на каталог", path)
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		logrus.Errorf("Ошибка чтения файла: %v", err)
+	return nil
+}
+
+// writeReportFiles записывает итоговый отчёт гонки в outputs и, если
+// eventsPath задан, исходящие события в файл eventsPath
+func writeReportFiles(race *race.Race, outputs []reportOutput, eventsPath string) error {
+	if err := writeReportFile(race, outputs); err != nil {
+		return err
+	}
+	if eventsPath == "" {
+		return nil
+	}
+	return writeOutgoingFile(race, eventsPath)
+}
+
+// writeReportFile записывает итоговый отчёт гонки во все outputs по одним и
+// тем же строкам таблицы. Ошибка записи одного файла не мешает остальным.
+func writeReportFile(race *race.Race, outputs []reportOutput) error {
+	race.Finalize()
+	race.LogDataIssues()
+	rows := race.Results()
+
+	var errs []error
+	for _, output := range outputs {
+		if err := writeReportOutput(rows, race.Config(), output); err != nil {
+			errs = append(errs, err)
+		}
 	}
+	return errors.Join(errs...)
+}
+
+// reportOutput — файл итогового отчёта и формат, в котором он записывается
+type reportOutput struct {
+	writer report.Writer
+	path   string
+}
+
+// withSuffix возвращает те же отчёты с путями path<suffix>
+func withSuffix(outputs []reportOutput, suffix string) []reportOutput {
+	out := make([]reportOutput, 0, len(outputs))
+	for _, output := range outputs {
+		out = append(out, reportOutput{writer: output.writer, path: output.path + suffix})
+	}
+	return out
+}
 
-	fileResults, err := os.Create("resulting_table")
+func writeReportOutput(rows []race.Result, cfg race.Config, output reportOutput) error {
+	fileResults, err := createOutput(output.path)
 	if err != nil {
-		logrus.Fatalf("Ошибка создания файла результатов: %s", err)
+		return fmt.Errorf("Ошибка создания файла результатов: %w", err)
+	}
+	defer fileResults.Abort()
+
+	if err := output.writer.Write(fileResults, rows, cfg); err != nil {
+		return fmt.Errorf("Ошибка записи в файл %s: %w", output.path, err)
+	}
+
+	return fileResults.Close()
+}
+
+// outputFile — файл отчёта: Close фиксирует записанное, Abort отбрасывает
+// незавершённую запись
+type outputFile interface {
+	io.WriteCloser
+	Abort()
+}
+
+// stdoutOutput — стандартный вывод как файл отчёта, который не закрывается
+// после записи
+type stdoutOutput struct{ io.Writer }
+
+func (stdoutOutput) Close() error { return nil }
+func (stdoutOutput) Abort()       {}
+
+// deviceOutput — устройство или именованный канал: их нельзя заменить
+// переименованием, поэтому отчёт пишется в них напрямую
+type deviceOutput struct{ *os.File }
+
+func (f deviceOutput) Abort() { f.File.Close() }
+
+// atomicFile пишет во временный файл рядом с целевым и переименовывает его
+// поверх целевого только при успешном Close, прежний файл до этого не
+// трогается
+type atomicFile struct {
+	*os.File
+	path string
+	done bool
+}
+
+func (f *atomicFile) Close() error {
+	if f.done {
+		return nil
 	}
-	defer fileResults.Close()
+	f.done = true
+	if err := f.File.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), f.path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
 
-	writeFinalReport(competitorsStats, fileResults, lapLen, penaltyLen, firingLines)
+func (f *atomicFile) Abort() {
+	if f.done {
+		return
+	}
+	f.done = true
+	f.File.Close()
+	os.Remove(f.Name())
 }
 
-func handleEvent(event string, competitorStats map[string]*competitorStat, start time.Time, startDelta time.Time, laps int) error {
-	params := strings.Split(event, " ")
-	timeStr := params[0]
-	idEvStr := params[1]
-	idComp := params[2]
+// createOutput создаёт файл отчёта вместе с недостающими каталогами;
+// "-" — стандартный вывод, устройства вроде /dev/null открываются для записи
+func createOutput(path string) (outputFile, error) {
+	if path == "-" {
+		return stdoutOutput{os.Stdout}, nil
+	}
+	if info, err := os.Stat(path); err == nil && !info.Mode().IsRegular() && !info.IsDir() {
+		file, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return nil, err
+		}
+		return deviceOutput{file}, nil
+	}
+	dir := filepath.Dir(path)
+	if dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			if errors.Is(err, os.ErrPermission) {
+				return nil, fmt.Errorf("нет прав на создание каталога %s", dir)
+			}
+			return nil, err
+		}
+	}
 
-	timeEv, err := time.Parse(timeFormat, timeStr[1:len(timeStr)-1])
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
 	if err != nil {
-		return errors.New(fmt.Sprintf("Ошибка парсинга времени события: %s,  событие: %s", err, event))
+		if errors.Is(err, os.ErrPermission) {
+			return nil, fmt.Errorf("нет прав на запись в %s", path)
+		}
+		return nil, err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
 	}
 
-	idEv, err := strconv.Atoi(idEvStr)
+	return &atomicFile{File: tmp, path: path}, nil
+}
+
+// consumeBroker читает события из брокера сообщений до маркера конца гонки
+// или отмены ctx
+func consumeBroker(ctx context.Context, opts options, consumer messageConsumer, races *race.Set) error {
+	defer consumer.close()
+
+	deadLetter, err := openDeadLetter(opts.deadLetterPath)
 	if err != nil {
-		return errors.New(fmt.Sprintf("Ошибка преобразования ID события в число: %s, событие: %s", err, event))
+		return fmt.Errorf("Ошибка открытия журнала недоставленных сообщений: %w", err)
 	}
+	defer deadLetter.Close()
+
+	return consumeMessages(ctx, consumer, races, consumeOptions{
+		newSource:  opts.newSource(),
+		endMarker:  opts.endMarker,
+		deadLetter: deadLetter,
+		clock:      opts.raceOptions.Clock,
+		dedup:      newEventDedup(),
+	})
+}
 
-	if _, ok := competitorStats[idComp]; !ok {
-		competitorStats[idComp] = &competitorStat{
-			lapsTime:      make([][2]time.Time, 0),
-			penaltyTime:   make([][2]time.Time, 0),
-			hits:          0,
-			lapSpeeds:     make([]float64, 0),
-			penaltySpeeds: make([]float64, 0),
+// processFiles обрабатывает события из файлов, перечисленных в параметрах запуска
+func processFiles(ctx context.Context, opts options, races *race.Set, stats *processStats) error {
+	inputs, err := openInputs(opts.eventsPaths)
+	if err != nil {
+		return fmt.Errorf("Ошибка открытия файла событий: %w", err)
+	}
+	defer closeInputs(inputs)
+	logrus.Infof("Обработка файлов событий: %s", strings.Join(opts.eventsPaths, ", "))
+
+	newSource := opts.newSource()
+	var input io.Reader = inputs[0]
+	if opts.follow {
+		input = newFollowReader(input, opts.raceOptions.Clock, ctx.Done())
+		logrus.Info("Режим слежения: отчёт будет сформирован по SIGINT/SIGTERM или служебному событию")
+	}
+	src := newSource(input)
+	if len(inputs) > 1 {
+		src, err = mergeEvents(opts.eventsPaths, inputs, newSource, stats)
+		if err != nil {
+			return fmt.Errorf("Ошибка слияния файлов событий: %w", err)
 		}
 	}
+	if opts.stopEvent != 0 {
+		src = &untilEvent{src: src, id: opts.stopEvent}
+	}
+	if opts.follow {
+		done := make(chan struct{})
+		defer close(done)
+		go races.WatchTimeLimits(timeLimitInterval, done)
+	}
 
-	stat := competitorStats[idComp]
-
-	switch idEv {
-	case 1: // Участник зарегистрирован
-		stat.registered = true
-		logrus.Infof("%s The competitor(%s) registered", timeStr, idComp)
-	case 2: // Жеребьёвка старта
-		startTimeStr := params[3]
-		startTime, err := time.Parse(timeFormat, startTimeStr)
+	sink := &checkpointSink{races: races, path: opts.checkpointPath, every: opts.checkpointEvery}
+	switch {
+	case opts.resume:
+		cp, err := race.LoadCheckpoint(opts.checkpointPath)
+		if err != nil {
+			return fmt.Errorf("Ошибка загрузки контрольной точки: %w", err)
+		}
+		races.Restore(cp)
+		sink.processed = cp.Processed
+		src = &skipEvents{src: src, n: cp.Processed}
+		logrus.Infof("Возобновление с контрольной точки %s: пропуск %d обработанных событий", opts.checkpointPath, cp.Processed)
+	case opts.loadStatePath != "":
+		cp, err := race.LoadCheckpoint(opts.loadStatePath)
 		if err != nil {
-			return errors.New(fmt.Sprintf("Ошибка парсинга времени старта из события: %s, событие: %s", err, event))
-		}
-		stat.startTime = startTime
-		logrus.Infof("%s The start time for the competitor(%s) was set by a draw to %s", timeStr, idComp, startTimeStr)
-	case 3: // Участник на стартовой линии
-		logrus.Infof("%s The competitor(%s) is on the start line", timeStr, idComp)
-	case 4: // Участник стартовал
-		stat.actualStart = timeEv
-		stat.lapsTime = append(stat.lapsTime, [2]time.Time{timeEv})
-		logrus.Infof("%s The competitor(%s) has started", timeStr, idComp)
-
-		startDeltaDuration := time.Duration(startDelta.Hour())*time.Hour +
-			time.Duration(startDelta.Minute())*time.Minute +
-			time.Duration(startDelta.Second())*time.Second +
-			time.Duration(startDelta.Nanosecond())*time.Nanosecond
-		deadline := stat.startTime.Add(startDeltaDuration)
-		if stat.actualStart.After(deadline) {
-			stat.notStarted = true
-			stat.comment = "Дисквалифицирован: старт после допустимого времени"
-			logrus.Warnf("Участник %s дисквалифицирован: старт после допустимого времени (%s > %s).", idComp, stat.actualStart.Format(timeFormat), deadline.Format(timeFormat))
-		}
-	case 5: // Участник на огневом рубеже
-		firingRange := params[3]
-		logrus.Infof("%s The competitor(%s) is on the firing range(%s)", timeStr, idComp, firingRange)
-	case 6: // Попадание в цель
-		target := params[3]
-		logrus.Infof("%s The target(%s) has been hit by competitor(%s)", timeStr, target, idComp)
-		stat.hits++
-	case 7: // Участник покинул огневой рубеж
-		logrus.Infof("%s The competitor(%s) left the firing range", timeStr, idComp)
-	case 8: // Участник зашел на штрафной круг
-		stat.penaltyTime = append(stat.penaltyTime, [2]time.Time{timeEv, {}}) // Начало штрафного круга
-		logrus.Infof("%s The competitor(%s) entered the penalty laps", timeStr, idComp)
-	case 9: // Участник покинул штрафной круг
-		stat.penaltyTime[len(stat.penaltyTime)-1][1] = timeEv // Конец штрафного круга
-		logrus.Infof("%s The competitor(%s) left the penalty laps", timeStr, idComp)
-	case 10: // Участник закончил круг
-		stat.lapsTime[len(stat.lapsTime)-1][1] = timeEv
-		logrus.Infof("%s The competitor(%s) ended the main lap", timeStr, idComp)
-		if len(stat.lapsTime) < laps {
-			stat.lapsTime = append(stat.lapsTime, [2]time.Time{timeEv})
-		} else {
-			stat.finishTime = timeEv
-		}
-	case 11: // Участник не может продолжать
-		comment := strings.Join(params[3:], " ")
-		stat.notFinished = true
-		stat.comment = comment
-		logrus.Infof("%s The competitor(%s) can`t continue: %s", timeStr, idComp, comment)
+			return fmt.Errorf("Ошибка загрузки состояния: %w", err)
+		}
+		races.Restore(cp)
+		sink.processed = cp.Processed
+		logrus.Infof("Загружено состояние гонки из %s", opts.loadStatePath)
+	}
 
-	default:
-		logrus.Warnf("Неизвестный ID события: %s, событие: %s", idEvStr, event)
+	err = processEvents(ctx, src, sink, stats)
+	stats.logSummary()
+	if err != nil {
+		return err
+	}
+
+	if opts.checkpointPath != "" {
+		if err := sink.save(); err != nil {
+			return outputError(err)
+		}
+	}
+	if opts.dumpStatePath != "" {
+		if err := race.SaveCheckpoint(opts.dumpStatePath, races.Snapshot(sink.processed)); err != nil {
+			return outputError(fmt.Errorf("Ошибка сохранения состояния в %s: %w", opts.dumpStatePath, err))
+		}
+		logrus.Infof("Состояние гонки сохранено в %s", opts.dumpStatePath)
 	}
 
 	return nil
 }
 
-func writeFinalReport(competitorStats map[string]*competitorStat, file *os.File, lapLen, penaltyLen, firingLines int) {
-	var competitorIDs []string
-	for id := range competitorStats {
-		competitorIDs = append(competitorIDs, id)
+const (
+	modeStrict  = "strict"
+	modeLenient = "lenient"
+)
+
+// processStats ведёт учёт обработанных и пропущенных событий в режиме -mode
+type processStats struct {
+	lenient bool
+	// rejectUnknown — событие с неизвестным ID — ошибка строки
+	rejectUnknown bool
+	configs       race.Configs // даты старта гонок для событий без даты
+	processed     int
+	skipped       int
+	truncated     int // пропущенные строки длиннее -max-line
+
+	// Время последнего события ленты и каждого участника
+	started        bool
+	lastTime       time.Time
+	lastLine       int
+	lastCompetitor map[string]time.Time
+}
+
+func newProcessStats(mode string, configs race.Configs) *processStats {
+	return &processStats{lenient: mode == modeLenient, configs: configs, lastCompetitor: make(map[string]time.Time)}
+}
+
+// checkOrder проверяет, что время событий не идёт назад — ни в ленте
+// целиком, ни у отдельного участника. Время без даты сравнивается на дате
+// старта гонки. В мягком режиме нарушение только записывается в лог.
+func (s *processStats) checkOrder(ev parser.Event) error {
+	key := ev.Race + " " + ev.CompetitorID
+	t := s.configs.EventTime(ev)
+	var err error
+	if last, ok := s.lastCompetitor[key]; ok && t.Before(last) {
+		err = fmt.Errorf("строка %d: время события %s участника %s раньше его предыдущего события %s",
+			ev.Line, ev.TimeStr, ev.CompetitorID, last.Format(parser.TimeFormat))
+	} else if s.started && t.Before(s.lastTime) {
+		err = fmt.Errorf("строка %d: время события %s раньше времени %s из строки %d",
+			ev.Line, ev.TimeStr, s.lastTime.Format(parser.TimeFormat), s.lastLine)
+	}
+	if !s.started || t.After(s.lastTime) {
+		s.started, s.lastTime, s.lastLine = true, t, ev.Line
+	}
+	if last, ok := s.lastCompetitor[key]; !ok || t.After(last) {
+		s.lastCompetitor[key] = t
+	}
+
+	if err != nil && s.lenient {
+		logrus.Warn(err)
+		return nil
+	}
+	return err
+}
+
+// skip пропускает ошибочную строку в мягком режиме. Возвращает false, если
+// ошибка должна прервать обработку.
+func (s *processStats) skip(err error) bool {
+	if !s.lenient {
+		return false
+	}
+	logrus.Errorf("Строка пропущена: %s", err)
+	s.skipped++
+	if errors.Is(err, errLineTooLong) {
+		s.truncated++
 	}
+	return true
+}
 
-	sort.Slice(competitorIDs, func(i, j int) bool {
-		statI := competitorStats[competitorIDs[i]]
-		statJ := competitorStats[competitorIDs[j]]
-		if statI.notStarted {
-			return true
+func (s *processStats) logSummary() {
+	logrus.Infof("Обработано событий: %d, пропущено строк: %d", s.processed, s.skipped)
+}
+
+// incomplete возвращает ошибку, если часть входных данных не была прочитана
+func (s *processStats) incomplete() error {
+	if s.truncated == 0 {
+		return nil
+	}
+	return fmt.Errorf("входные данные обработаны не полностью: пропущено строк длиннее %d байт: %d", maxLineSize, s.truncated)
+}
+
+// processEvents передаёт события источника в sink. В мягком режиме ошибочные
+// строки записываются в лог и пропускаются, в строгом обработка прерывается
+// на первой ошибке, а неизвестные ID событий считаются ошибкой. После отмены
+// ctx приём событий прекращается без ошибки, чтобы по уже обработанным
+// событиям был записан отчёт.
+func processEvents(ctx context.Context, src eventSource, sink eventSink, stats *processStats) error {
+	for {
+		if ctx.Err() != nil {
+			logrus.Info("Приём событий остановлен, отчёт формируется по уже обработанным событиям")
+			return nil
 		}
-		if statJ.notStarted {
-			return false
+		ev, err := src.next()
+		if err == io.EOF {
+			return nil
 		}
-		if statI.notFinished {
-			return true
+		var lineErr *lineError
+		if errors.As(err, &lineErr) && stats.skip(err) {
+			continue
 		}
-		if statJ.notFinished {
-			return false
+		if err != nil {
+			return err
 		}
 
-		return statI.finishTime.Sub(statI.actualStart) < statJ.finishTime.Sub(statJ.actualStart)
-	})
-
-	writer := bufio.NewWriter(file)
-	defer writer.Flush()
-
-	for _, id := range competitorIDs {
-		stat := competitorStats[id]
-
-		var totalTimeStr string
-		if stat.notStarted {
-			totalTimeStr = "[NotStarted]"
-		} else if stat.notFinished {
-			totalTimeStr = "[NotFinished]"
-		} else {
-			totalTime := stat.finishTime.Sub(stat.actualStart)
-			hours := int(totalTime.Hours())
-			minutes := int(totalTime.Minutes()) % 60
-			seconds := int(totalTime.Seconds()) % 60
-			milliseconds := totalTime.Milliseconds() % 1000
-			totalTimeStr = fmt.Sprintf("{%02d:%02d:%02d.%03d}", hours, minutes, seconds, milliseconds)
-		}
-
-		lapsTimeStr := "["
-		for i, lap := range stat.lapsTime {
-			if lap[0].IsZero() || lap[1].IsZero() {
-				lapsTimeStr += "{,}"
-			} else {
-				lapTime := lap[1].Sub(lap[0])
-				speed := float64(lapLen) / lapTime.Seconds()
-				stat.lapSpeeds = append(stat.lapSpeeds, speed)
-				hours := int(lapTime.Hours())
-				minutes := int(lapTime.Minutes()) % 60
-				seconds := int(lapTime.Seconds()) % 60
-				milliseconds := lapTime.Milliseconds() % 1000
-
-				lapsTimeStr += fmt.Sprintf("{%02d:%02d:%02d.%d, %.3f}", hours, minutes, seconds, milliseconds, speed)
-			}
-			if i < len(stat.lapsTime)-1 {
-				lapsTimeStr += ", "
-			}
+		if err := stats.checkOrder(ev); err != nil {
+			return err
 		}
-		lapsTimeStr += "]"
-
-		penaltyTimeStr := "["
-		for i, penalty := range stat.penaltyTime {
-			if penalty[0].IsZero() || penalty[1].IsZero() {
-				penaltyTimeStr += "{,}"
-			} else {
-				penaltyTime := penalty[1].Sub(penalty[0])
-				speed := float64(penaltyLen) / penaltyTime.Seconds()
-				stat.penaltySpeeds = append(stat.penaltySpeeds, speed)
-				hours := int(penaltyTime.Hours())
-				minutes := int(penaltyTime.Minutes()) % 60
-				seconds := int(penaltyTime.Seconds()) % 60
-				milliseconds := penaltyTime.Milliseconds() % 1000
-				penaltyTimeStr += fmt.Sprintf("{%02d:%02d:%02d.%03d, %.3f}", hours, minutes, seconds, milliseconds, speed)
+		if stats.rejectUnknown && !stats.configs.ForRace(ev.Race).IsKnownEvent(ev.ID) {
+			if err := fmt.Errorf("строка %d: неизвестный ID события: %d, событие: %s", ev.Line, ev.ID, ev.Raw); !stats.skip(err) {
+				return err
 			}
-			if i < len(stat.penaltyTime)-1 {
-				penaltyTimeStr += ", "
+			continue
+		}
+		if err := sink.Apply(ev); err != nil {
+			err = fmt.Errorf("строка %d: %w", ev.Line, err)
+			if !stats.skip(err) {
+				return err
 			}
+			continue
 		}
-		penaltyTimeStr += "]"
+		stats.processed++
+	}
+}
 
-		resultString := fmt.Sprintf("%s %s %s %s %d/%d\n",
-			totalTimeStr,
-			id,
-			lapsTimeStr,
-			penaltyTimeStr,
-			stat.hits,
-			5*firingLines,
-		)
+// eventSink принимает разобранные события
+type eventSink interface {
+	Apply(ev parser.Event) error
+}
 
-		_, err := writer.WriteString(resultString)
-		if err != nil {
-			logrus.Errorf("Ошибка записи в файл: %s", err)
-			return
+// writeRaceReports записывает отчёты каждой гонки: гонки по умолчанию — в
+// пути outputs, остальных — в path_<raceID>. Исходящие события так же
+// записываются в eventsPath, если он задан.
+func writeRaceReports(races *race.Set, outputs []reportOutput, eventsPath string) error {
+	raceIDs := races.IDs()
+	if len(raceIDs) == 0 {
+		return writeReportFiles(races.Race(""), outputs, eventsPath)
+	}
+
+	for _, raceID := range raceIDs {
+		raceOutputs, raceEventsPath := outputs, eventsPath
+		if raceID != "" {
+			raceOutputs = withSuffix(outputs, "_"+raceID)
+			if eventsPath != "" {
+				raceEventsPath = eventsPath + "_" + raceID
+			}
+		}
+		if err := writeReportFiles(races.Race(raceID), raceOutputs, raceEventsPath); err != nil {
+			return err
 		}
 	}
 
+	return nil
 }
 
-func initConfig() error {
-	viper.AddConfigPath("configs")
-	viper.SetConfigName("config")
-	viper.SetConfigType("json")
+// standingsJSON возвращает текущую таблицу результатов гонки в JSON
+func standingsJSON(race *race.Race) ([]byte, error) {
+	return report.MarshalResults(race.Results())
+}
+
+// writeOutgoingFile записывает исходящие события гонки в хронологическом порядке
+func writeOutgoingFile(race *race.Race, path string) error {
+	events := race.Outgoing()
+
+	file, err := createOutput(path)
+	if err != nil {
+		return fmt.Errorf("Ошибка создания файла исходящих событий: %w", err)
+	}
+	defer file.Abort()
+
+	writer := bufio.NewWriter(file)
+	for _, ev := range events {
+		if _, err := writer.WriteString(ev.String() + "\n"); err != nil {
+			return fmt.Errorf("Ошибка записи в файл %s: %w", path, err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("Ошибка записи в файл %s: %w", path, err)
+	}
 
-	return viper.ReadInConfig()
+	return file.Close()
 }
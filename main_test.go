@@ -0,0 +1,949 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"biathlon_system/internal/race"
+	"biathlon_system/internal/report"
+	"biathlon_system/parser"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+func TestMain(m *testing.M) {
+	// Сквозные тесты запускают тестовый бинарник как программу, см. runMain
+	if os.Getenv("BIATHLON_RUN_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	logrus.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// runMain запускает программу с аргументами args в каталоге dir и
+// возвращает её код завершения
+func runMain(t *testing.T, dir string, args ...string) int {
+	t.Helper()
+
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "BIATHLON_RUN_MAIN=1")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatalf("запуск программы: %v\n%s", err, stderr.String())
+	}
+	return 0
+}
+
+// testConfigJSON — параметры гонки из примера README
+const testConfigJSON = `{
+	"laps": 2,
+	"lapLen": 3500,
+	"penaltyLen": 150,
+	"firingLines": 2,
+	"start": "10:00:00.000",
+	"startDelta": "00:01:30"
+}`
+
+// testConfigs загружает параметры гонок из JSON; extra дополняет или
+// заменяет параметры testConfigJSON
+func testConfigs(t *testing.T, extra string) race.Configs {
+	t.Helper()
+
+	v := viper.New()
+	v.SetConfigType("json")
+	if err := v.ReadConfig(strings.NewReader(testConfigJSON)); err != nil {
+		t.Fatal(err)
+	}
+	if extra != "" {
+		if err := v.MergeConfig(strings.NewReader(extra)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configs, err := race.LoadConfigs(v)
+	if err != nil {
+		t.Fatalf("LoadConfigs: %v", err)
+	}
+	return configs.WithOptions(race.Options{Logger: race.NopLogger{}})
+}
+
+// testOptions — параметры запуска по умолчанию, как после parseFlags, для
+// файлов событий eventsPaths; отчёт записывается во временный каталог
+func testOptions(t *testing.T, eventsPaths ...string) options {
+	t.Helper()
+
+	return options{
+		configPath:      filepath.Join("configs", "config.json"),
+		eventsPaths:     eventsPaths,
+		outPath:         filepath.Join(t.TempDir(), "resulting_table"),
+		outputFormat:    "text",
+		format:          "text",
+		mode:            modeStrict,
+		checkpointEvery: 1000,
+		raceOptions:     race.Options{Strict: true, Logger: race.NopLogger{}, Clock: race.SystemClock{}},
+	}
+}
+
+// runTable выполняет run с параметрами opts и возвращает итоговый отчёт
+func runTable(t *testing.T, opts options) string {
+	t.Helper()
+
+	if err := run(context.Background(), opts); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	table, err := os.ReadFile(opts.outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(table)
+}
+
+// textSource читает события в текстовом формате из строки
+func textSource(lines string) eventSource {
+	return newEventReader(strings.NewReader(strings.TrimLeft(lines, "\n")), parser.ParseEvent)
+}
+
+func TestCheckOrderMixedDates(t *testing.T) {
+	// Время без даты относится к дате старта и идёт после 09:00 того же дня
+	configs := testConfigs(t, `{"start": "2024-03-10 10:00:00.000"}`)
+	lines := `
+[2024-03-10 09:00:00.000] 1 1
+[09:10:00.000] 2 1 10:00:00.000
+`
+	races := race.NewSet(configs)
+	if err := processEvents(context.Background(), textSource(lines), races, newProcessStats(modeStrict, configs)); err != nil {
+		t.Fatalf("processEvents: %v", err)
+	}
+}
+
+// skewedFeed — события двух компьютеров огневых рубежей, часы второго
+// отстают на две секунды
+const skewedFeed = `
+[09:05:00.000] 1 1
+[09:05:01.000] 1 2
+[09:10:00.000] 2 1 10:00:00.000
+[09:10:01.000] 2 2 10:01:00.000
+[10:00:01.000] 4 1
+[10:01:01.000] 4 2
+[10:10:00.000] 5 1 1
+[10:09:58.500] 5 2 1
+[10:10:02.000] 6 1 1
+[10:10:01.000] 6 2 1
+`
+
+func TestCheckOrderClockSkew(t *testing.T) {
+	configs := testConfigs(t, "")
+
+	err := processEvents(context.Background(), textSource(skewedFeed), race.NewSet(configs), newProcessStats(modeStrict, configs))
+	if err == nil || !strings.Contains(err.Error(), "строка 8") {
+		t.Fatalf("strict: ошибка %v, ожидалась ошибка в строке 8", err)
+	}
+
+	stats := newProcessStats(modeLenient, configs)
+	if err := processEvents(context.Background(), textSource(skewedFeed), race.NewSet(configs), stats); err != nil {
+		t.Fatalf("lenient: %v", err)
+	}
+	if stats.processed != 10 || stats.skipped != 0 {
+		t.Errorf("lenient: обработано %d, пропущено %d, ожидалось 10 и 0", stats.processed, stats.skipped)
+	}
+}
+
+func TestEventsPath(t *testing.T) {
+	want := runTable(t, testOptions(t, "events"))
+	got := runTable(t, testOptions(t, filepath.Join("testdata", "races", "2024-03-10", "events.log")))
+	if got != want {
+		t.Errorf("таблица по -events testdata/races/2024-03-10/events.log:\n%s\nпо events:\n%s", got, want)
+	}
+}
+
+func TestEventsPathValidation(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{filepath.Join("testdata", "missing"), "не найден"},
+		{"testdata", "указывает на каталог"},
+	}
+	for _, tt := range tests {
+		err := run(context.Background(), testOptions(t, tt.path))
+		if err == nil || !strings.Contains(err.Error(), tt.want) || exitCode(err) != exitConfig {
+			t.Errorf("-events %s: ошибка %v с кодом %d, ожидалось «%s» с кодом %d", tt.path, err, exitCode(err), tt.want, exitConfig)
+		}
+	}
+}
+
+func TestOutCreatesDirs(t *testing.T) {
+	opts := testOptions(t, "events")
+	opts.outPath = filepath.Join(t.TempDir(), "results", "race42", "final.txt")
+	if table := runTable(t, opts); table == "" {
+		t.Error("пустой отчёт")
+	}
+}
+
+func TestOutWriteProtected(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("права на каталог не действуют для root")
+	}
+	dir := filepath.Join(t.TempDir(), "readonly")
+	if err := os.Mkdir(dir, 0o555); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := testOptions(t, "events")
+	opts.outPath = filepath.Join(dir, "resulting_table")
+	err := run(context.Background(), opts)
+	if err == nil || exitCode(err) != exitOutput || !strings.Contains(err.Error(), "нет прав на запись") {
+		t.Errorf("ошибка %v с кодом %d, ожидалась ошибка прав с кодом %d", err, exitCode(err), exitOutput)
+	}
+}
+
+func TestOutUncreatable(t *testing.T) {
+	// Каталог отчёта не создать: на его месте файл
+	opts := testOptions(t, "events")
+	opts.outPath = filepath.Join("events", "resulting_table")
+	if err := run(context.Background(), opts); err == nil || exitCode(err) != exitOutput {
+		t.Errorf("ошибка %v с кодом %d, ожидался код %d", err, exitCode(err), exitOutput)
+	}
+}
+
+func TestBrokenLines(t *testing.T) {
+	// В testdata/broken в ленту events вставлены пять неразбираемых строк
+	path := filepath.Join("testdata", "broken")
+	configs := testConfigs(t, "")
+
+	opts := options{eventsPaths: []string{path}, format: "text", mode: modeStrict, checkpointEvery: 1000}
+	err := processFiles(context.Background(), opts, race.NewSet(configs), newProcessStats(opts.mode, configs))
+	if err == nil || !strings.Contains(err.Error(), "строка 4") || !strings.Contains(err.Error(), "[09:30:01.005] 1") {
+		t.Errorf("строгий режим: ошибка %v, ожидалась ошибка строки 4", err)
+	}
+
+	opts.mode = modeLenient
+	stats := newProcessStats(opts.mode, configs)
+	races := race.NewSet(configs)
+	if err := processFiles(context.Background(), opts, races, stats); err != nil {
+		t.Fatalf("мягкий режим: %v", err)
+	}
+	if stats.skipped != 5 {
+		t.Errorf("пропущено строк %d, ожидалось 5", stats.skipped)
+	}
+	if got, want := reportText(t, races.Race("")), processPaths(t, configs, "events"); got != want {
+		t.Errorf("таблица по ленте с ошибками:\n%s\nпо events:\n%s", got, want)
+	}
+}
+
+func TestProcessingModes(t *testing.T) {
+	path := filepath.Join("testdata", "broken")
+
+	opts := testOptions(t, path)
+	err := run(context.Background(), opts)
+	if exitCode(err) != exitInput || !strings.Contains(err.Error(), "строка 4") {
+		t.Errorf("strict: ошибка %v с кодом %d, ожидалась ошибка строки 4 с кодом %d", err, exitCode(err), exitInput)
+	}
+	if _, err := os.Stat(opts.outPath); err == nil {
+		t.Error("strict: отчёт записан, хотя обработка прервана")
+	}
+
+	var logs bytes.Buffer
+	logrus.SetOutput(&logs)
+	defer logrus.SetOutput(io.Discard)
+
+	opts = testOptions(t, path)
+	opts.mode = modeLenient
+	opts.raceOptions.Strict = false
+	got := runTable(t, opts)
+	if want := runTable(t, testOptions(t, "events")); got != want {
+		t.Errorf("lenient: таблица\n%s\nпо events:\n%s", got, want)
+	}
+	if !strings.Contains(logs.String(), "Обработано событий: 104, пропущено строк: 5") {
+		t.Errorf("lenient: нет сводки обработки в журнале:\n%s", logs.String())
+	}
+}
+
+func TestUnknownEventMode(t *testing.T) {
+	configs := testConfigs(t, "")
+	lines := `
+[09:05:00.000] 1 1
+[09:06:00.000] 42 1
+[09:07:00.000] 1 2
+`
+	// По умолчанию в обоих режимах — предупреждение, событие пропускается
+	for _, mode := range []string{modeStrict, modeLenient} {
+		stats := newProcessStats(mode, configs)
+		races := race.NewSet(configs)
+		if err := processEvents(context.Background(), textSource(lines), races, stats); err != nil {
+			t.Errorf("%s: %v", mode, err)
+		}
+		if stats.processed != 3 || stats.skipped != 0 {
+			t.Errorf("%s: обработано %d, пропущено %d, ожидалось 3 и 0", mode, stats.processed, stats.skipped)
+		}
+	}
+
+	// -reject-unknown-events: ошибка в strict и пропуск строки в lenient
+	stats := newProcessStats(modeStrict, configs)
+	stats.rejectUnknown = true
+	err := processEvents(context.Background(), textSource(lines), race.NewSet(configs), stats)
+	if err == nil || !strings.Contains(err.Error(), "неизвестный ID события: 42") {
+		t.Errorf("strict -reject-unknown-events: ошибка %v, ожидалась ошибка неизвестного события", err)
+	}
+	stats = newProcessStats(modeLenient, configs)
+	stats.rejectUnknown = true
+	if err := processEvents(context.Background(), textSource(lines), race.NewSet(configs), stats); err != nil {
+		t.Errorf("lenient -reject-unknown-events: %v", err)
+	}
+	if stats.processed != 2 || stats.skipped != 1 {
+		t.Errorf("lenient -reject-unknown-events: обработано %d, пропущено %d, ожидалось 2 и 1", stats.processed, stats.skipped)
+	}
+}
+
+func TestHitOutsideRangeLenient(t *testing.T) {
+	// Попадание через две минуты после ухода с рубежа пропускается со сводкой
+	configs := testConfigs(t, "")
+	lines := `
+[09:05:00.000] 1 1
+[09:10:00.000] 2 1 10:00:00.000
+[10:00:01.000] 4 1
+[10:10:00.000] 5 1 1
+[10:10:01.000] 6 1 1
+[10:10:10.000] 7 1
+[10:12:10.000] 6 1 2
+`
+	err := processEvents(context.Background(), textSource(lines), race.NewSet(configs), newProcessStats(modeStrict, configs))
+	if err == nil || !strings.Contains(err.Error(), "строка 7") {
+		t.Errorf("strict: ошибка %v, ожидалась ошибка в строке 7", err)
+	}
+
+	stats := newProcessStats(modeLenient, configs)
+	if err := processEvents(context.Background(), textSource(lines), race.NewSet(configs), stats); err != nil {
+		t.Fatalf("lenient: %v", err)
+	}
+	if stats.processed != 6 || stats.skipped != 1 {
+		t.Errorf("lenient: обработано %d, пропущено %d, ожидалось 6 и 1", stats.processed, stats.skipped)
+	}
+}
+
+func TestOutgoingEvents(t *testing.T) {
+	// Финиш участника 2 записан после финиша участника 1, но раньше по времени
+	opts := testOptions(t, filepath.Join("testdata", "outgoing", "events"))
+	opts.mode = modeLenient
+	opts.raceOptions.Strict = false
+	opts.outEventsPath = filepath.Join(t.TempDir(), "output_events")
+	runTable(t, opts)
+
+	got, err := os.ReadFile(opts.outEventsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(filepath.Join("testdata", "outgoing", "output_events"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("исходящие события:\n%s\nожидалось:\n%s", got, want)
+	}
+}
+
+// readGolden возвращает эталонный файл testdata/golden/name
+func readGolden(t *testing.T, name string) string {
+	t.Helper()
+
+	golden, err := os.ReadFile(filepath.Join("testdata", "golden", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(golden)
+}
+
+func TestGoldenTextReport(t *testing.T) {
+	if got, want := runTable(t, testOptions(t, "events")), readGolden(t, "resulting_table"); got != want {
+		t.Errorf("отчёт по events:\n%s\nэталон testdata/golden/resulting_table:\n%s", got, want)
+	}
+}
+
+func TestTieStableOrder(t *testing.T) {
+	// У всех трёх участников 24 минуты; 3 быстрее на последнем круге, 9 и 10
+	// совпадают и по нему
+	path := filepath.Join("testdata", "tie", "events")
+	want := runTable(t, testOptions(t, path))
+
+	var order []string
+	for _, line := range strings.Split(strings.TrimSpace(want), "\n") {
+		order = append(order, strings.Fields(line)[1])
+	}
+	if got := strings.Join(order, " "); got != "3 9 10" {
+		t.Errorf("порядок участников %s, ожидался 3 9 10", got)
+	}
+	for i := 0; i < 10; i++ {
+		if got := runTable(t, testOptions(t, path)); got != want {
+			t.Fatalf("запуск %d: таблица\n%s\nотличается от первой:\n%s", i+2, got, want)
+		}
+	}
+}
+
+func TestTemplateErrorBeforeProcessing(t *testing.T) {
+	// Ошибка в шаблоне сообщается до обработки событий: отчёт не записывается
+	path := filepath.Join(t.TempDir(), "report.tmpl")
+	if err := os.WriteFile(path, []byte("{{range .Competitors}}\n{{.ID}\n{{end}}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	opts := testOptions(t, "events")
+	opts.templatePath = path
+	err := run(context.Background(), opts)
+	if exitCode(err) != exitConfig || !strings.Contains(err.Error(), path+":2:") {
+		t.Errorf("ошибка %v с кодом %d, ожидалась ошибка шаблона в строке 2 с кодом %d", err, exitCode(err), exitConfig)
+	}
+	if _, err := os.Stat(opts.outPath); err == nil {
+		t.Error("отчёт записан, хотя шаблон содержит ошибку")
+	}
+}
+
+func TestMultipleOutputs(t *testing.T) {
+	dir := t.TempDir()
+	opts := testOptions(t, "events")
+	opts.outputSpec = "text=" + filepath.Join(dir, "resulting_table") + ",json=" + filepath.Join(dir, "results.json") + ",csv=" + filepath.Join(dir, "results.csv")
+	if err := run(context.Background(), opts); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	// Время гонки каждого участника по каждому из трёх файлов
+	times := map[string]map[string]string{"text": {}, "json": {}, "csv": {}}
+	text, err := os.ReadFile(filepath.Join(dir, "resulting_table"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(text)), "\n") {
+		fields := strings.Fields(line)
+		times["text"][fields[1]] = strings.Trim(fields[0], "{}")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "results.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var standings []struct {
+		Competitor string `json:"competitor"`
+		TotalTime  string `json:"totalTime"`
+	}
+	if err := json.Unmarshal(data, &standings); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range standings {
+		times["json"][s.Competitor] = s.TotalTime
+	}
+
+	file, err := os.Open(filepath.Join(dir, "results.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range records[1:] {
+		times["csv"][record[1]] = record[3]
+	}
+
+	if len(times["text"]) != 5 {
+		t.Fatalf("участников в текстовом отчёте %d, ожидалось 5", len(times["text"]))
+	}
+	for id, want := range times["text"] {
+		for _, format := range []string{"json", "csv"} {
+			if got := times[format][id]; got != want {
+				t.Errorf("участник %s: время в %s %q, в текстовом отчёте %q", id, format, got, want)
+			}
+		}
+	}
+}
+
+func TestMultipleOutputsPartialFailure(t *testing.T) {
+	// Текстовый отчёт нельзя создать внутри файла events, JSON записывается
+	dir := t.TempDir()
+	opts := testOptions(t, "events")
+	opts.outputSpec = "text=" + filepath.Join("events", "resulting_table") + ",json=" + filepath.Join(dir, "results.json")
+	err := run(context.Background(), opts)
+	if exitCode(err) != exitOutput {
+		t.Errorf("ошибка %v с кодом %d, ожидался код %d", err, exitCode(err), exitOutput)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "results.json")); err != nil {
+		t.Errorf("JSON-отчёт не записан: %v", err)
+	}
+}
+
+func TestOutStdout(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	opts := testOptions(t, "events")
+	opts.outPath = "-"
+	err = run(context.Background(), opts)
+	os.Stdout = stdout
+	w.Close()
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := readGolden(t, "resulting_table"); string(got) != want {
+		t.Errorf("стандартный вывод:\n%s\nэталон testdata/golden/resulting_table:\n%s", got, want)
+	}
+}
+
+// failingWriter записывает начало отчёта и завершается ошибкой
+type failingWriter struct{}
+
+func (failingWriter) Write(w io.Writer, rows []race.Result, cfg race.Config) error {
+	if _, err := io.WriteString(w, "{00:25:18.356} 2 [{00:12"); err != nil {
+		return err
+	}
+	return errors.New("диск переполнен")
+}
+
+func TestReportWriteAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "resulting_table")
+	previous := readGolden(t, "resulting_table")
+	if err := os.WriteFile(path, []byte(previous), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := writeReportOutput(nil, testConfigs(t, "").Base, reportOutput{writer: failingWriter{}, path: path})
+	if err == nil || !strings.Contains(err.Error(), "диск переполнен") {
+		t.Errorf("ошибка %v, ожидалась ошибка записи", err)
+	}
+	if got, err := os.ReadFile(path); err != nil || string(got) != previous {
+		t.Errorf("прежний отчёт изменён: %q (%v)", got, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("в каталоге осталось файлов %d, ожидался только прежний отчёт", len(entries))
+	}
+}
+
+func TestEndToEndGolden(t *testing.T) {
+	// Программа с параметрами по умолчанию записывает resulting_table и
+	// output_events в рабочий каталог
+	dir := t.TempDir()
+	abs := func(path string) string {
+		path, err := filepath.Abs(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	if code := runMain(t, dir, "-config", abs(filepath.Join("configs", "config.json")), "-events", abs("events")); code != 0 {
+		t.Fatalf("код завершения %d", code)
+	}
+	for _, name := range []string{"resulting_table", "output_events"} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := readGolden(t, name); string(got) != want {
+			t.Errorf("%s:\n%s\nэталон testdata/golden/%s:\n%s", name, got, name, want)
+		}
+	}
+
+	if code := runMain(t, dir, "-config", abs(filepath.Join("configs", "config.json")), "-events", abs(filepath.Join("testdata", "broken"))); code != exitInput {
+		t.Errorf("лента с ошибками: код завершения %d, ожидался %d", code, exitInput)
+	}
+}
+
+func TestRunExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig := func(name, text string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	malformed := writeConfig("malformed.json", `{"laps": 2,`)
+	invalid := writeConfig("invalid.json", `{"laps": 0, "lapLen": 3500, "penaltyLen": 150, "firingLines": 2, "start": "10:00:00.000", "startDelta": "00:01:30"}`)
+
+	tests := []struct {
+		name   string
+		modify func(opts *options)
+		code   int
+		msg    string
+	}{
+		{"нечитаемая конфигурация", func(opts *options) { opts.configPath = malformed }, exitConfig, malformed},
+		{"недопустимые параметры гонки", func(opts *options) { opts.configPath = invalid }, exitInvalid, "laps"},
+		{"ошибка в ленте", func(opts *options) { opts.eventsPaths = []string{filepath.Join("testdata", "broken")} }, exitInput, "строка 4"},
+		{"ошибка записи отчёта", func(opts *options) { opts.outPath = filepath.Join("events", "resulting_table") }, exitOutput, "events"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := testOptions(t, "events")
+			tt.modify(&opts)
+			err := run(context.Background(), opts)
+			if exitCode(err) != tt.code || !strings.Contains(err.Error(), tt.msg) {
+				t.Errorf("ошибка %v с кодом %d, ожидался код %d и %q", err, exitCode(err), tt.code, tt.msg)
+			}
+		})
+	}
+}
+
+// cancelAfter отменяет ctx после n событий источника src
+type cancelAfter struct {
+	src    eventSource
+	n      int
+	cancel context.CancelFunc
+}
+
+func (s *cancelAfter) next() (parser.Event, error) {
+	ev, err := s.src.next()
+	if s.n--; s.n == 0 {
+		s.cancel()
+	}
+	return ev, err
+}
+
+func TestCancelMidRace(t *testing.T) {
+	// Остановка после 60 из 104 событий: все участники ещё на дистанции
+	data, err := os.ReadFile("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	configs := testConfigs(t, "")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	races := race.NewSet(configs)
+	src := &cancelAfter{src: textSource(string(data)), n: 60, cancel: cancel}
+	if err := processEvents(ctx, src, races, newProcessStats(modeStrict, configs)); err != nil {
+		t.Fatalf("processEvents: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "resulting_table")
+	if err := writeRaceReports(races, []reportOutput{{writer: report.Text{}, path: path}}, ""); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := processPaths(t, configs, writeTemp(t, strings.Join(lines[:60], ""))); string(got) != want {
+		t.Errorf("таблица после отмены:\n%s\nпо первым 60 событиям:\n%s", got, want)
+	}
+	if rows := strings.Count(string(got), "\n"); rows != 5 || strings.Count(string(got), "[NotFinished]") != 5 {
+		t.Errorf("таблица после отмены:\n%s\nожидалось 5 строк [NotFinished]", got)
+	}
+}
+
+// writeTemp записывает text во временный файл и возвращает путь к нему
+func writeTemp(t *testing.T, text string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "events")
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRelayTeamsReport(t *testing.T) {
+	// В testdata/relay/events команда B первой передаёт эстафету, но команда
+	// A финиширует раньше
+	configs := testConfigs(t, `{"raceType": "relay", "laps": 1, "firingLines": 1,
+		"teams": [{"name": "A", "members": [1, 2]}, {"name": "B", "members": [3, 4]}]}`)
+	got := processPaths(t, configs, filepath.Join("testdata", "relay", "events"))
+	want := `{00:11:30.000} 3 [{00:11:29.300, 5.078}] [] 5/5
+{00:11:59.500} 2 [{00:11:59.500, 4.864}] [] 5/5
+{00:12:00.000} 1 [{00:11:59.500, 4.864}] [{00:00:30.000, 5.000}] 4/5
+{00:12:59.800} 4 [{00:12:59.800, 4.488}] [{00:01:00.000, 2.500}] 3/5
+
+Teams:
+{00:24:00.000} A [1 {00:12:00.000, 5.069}, 2 {00:11:59.500, 4.864}] 2/2 legs -
+{00:24:30.000} B [3 {00:11:30.000, 5.072}, 4 {00:12:59.800, 4.681}] 2/2 legs +30.0
+`
+	if got != want {
+		t.Errorf("отчёт эстафеты:\n%s\nожидалось:\n%s", got, want)
+	}
+}
+
+func TestSuperSprintFeeds(t *testing.T) {
+	// Квалификация из -events отбирает троих лучших, финал из -final: участник
+	// 4 не прошёл в финал, участник 2 сошёл с промахом на рубеже
+	config := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(config, []byte(`{"raceType": "supersprint", "laps": 1, "lapLen": 1500, "penaltyLen": 75,
+		"firingLines": 1, "start": "10:00:00.000", "startDelta": "00:00:30", "qualifiers": 3, "finalStart": "11:00:00.000"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	opts := testOptions(t, filepath.Join("testdata", "supersprint", "qualification"))
+	opts.configPath = config
+	opts.finalPath = filepath.Join("testdata", "supersprint", "final")
+
+	got := runTable(t, opts)
+	want := `1 {00:06:20.300} 1 [{00:06:20.000, 3.947}] [] 5/5 {qualification 1, 00:06:40.100}
+2 {00:06:30.700} 3 [{00:06:30.000, 3.846}] [] 5/5 {qualification 3, 00:07:00.300}
+- [NotFinished] 2 [{00:06:40.000, 3.750}] [{00:00:30.000, 2.500}] 4/5 {qualification 2, 00:07:00.200} (Сошёл: не закрыто мишеней на рубеже 1: 1)
+`
+	if got != want {
+		t.Errorf("таблица финала:\n%s\nожидалось:\n%s", got, want)
+	}
+}
+
+func TestLoadPursuitBasis(t *testing.T) {
+	// JSON-отчёт и текстовая таблица с местами дают одни отставания;
+	// не финишировавшие в квалификации пропускаются
+	tests := map[string]string{
+		"json": `[{"competitor": "2", "status": "Finished", "totalTime": "00:25:00.000"},
+	{"competitor": "1", "status": "Finished", "totalTime": "00:25:40.400"},
+	{"competitor": "3", "status": "NotFinished", "totalTime": ""}]`,
+		"text": `1 {00:25:00.000} 2 [{00:12:30.000, 4.667}, {00:12:30.000, 4.667}] [] 10/10 -
+2 {00:25:40.400} 1 [{00:12:50.000, 4.545}, {00:12:50.400, 4.543}] [] 10/10 +40.4
+- [NotFinished] 3 [{00:12:00.000, 4.861}, {,}] [] 5/5
+`,
+	}
+	want := map[string]time.Duration{"1": 40400 * time.Millisecond, "2": 0}
+	for name, basis := range tests {
+		got, err := loadPursuitBasis(writeTemp(t, basis), testConfigs(t, "").Base)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: отставания %v, ожидалось %v", name, got, want)
+		}
+	}
+
+	if _, err := loadPursuitBasis(writeTemp(t, "- [NotStarted] 1 [{,}, {,}] [] 0/0\n"), testConfigs(t, "").Base); err == nil || !strings.Contains(err.Error(), "нет финишировавших участников") {
+		t.Errorf("квалификация без финишировавших: ошибка %v", err)
+	}
+}
+
+// copyFile копирует файл src в dst, создавая каталоги dst
+func copyFile(t *testing.T, src, dst string) {
+	t.Helper()
+
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProcessDir(t *testing.T) {
+	// Гонка a — по общей конфигурации, b — сжатая, со своей конфигурацией
+	// на три круга, c — с ошибочной строкой, которая не мешает остальным
+	root := filepath.Join(t.TempDir(), "races")
+	copyFile(t, "events", filepath.Join(root, "a", "events"))
+	copyFile(t, filepath.Join("testdata", "gzip", "events.gz"), filepath.Join(root, "b", "events.gz"))
+	copyFile(t, filepath.Join("testdata", "broken"), filepath.Join(root, "c", "events.log"))
+	copyFile(t, "README.md", filepath.Join(root, "a", "notes.md"))
+	if err := os.MkdirAll(filepath.Join(root, "b", "configs"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "b", "configs", "config.json"), []byte(`{"laps": 3, "lapLen": 3500, "penaltyLen": 150,
+		"firingLines": 2, "start": "10:00:00.000", "startDelta": "00:01:30"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	configs := testConfigs(t, "")
+	want := processPaths(t, configs, "events")
+
+	for _, outDir := range []string{"", filepath.Join(t.TempDir(), "out")} {
+		opts := testOptions(t)
+		opts.dir = root
+		opts.outDir = outDir
+		if failed := processDir(context.Background(), opts, configs); failed != 1 {
+			t.Errorf("-out-dir %q: гонок с ошибками %d, ожидалась 1", outDir, failed)
+		}
+
+		base := root
+		if outDir != "" {
+			base = outDir
+		}
+		a, err := os.ReadFile(filepath.Join(base, "a", "resulting_table"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(a) != want {
+			t.Errorf("-out-dir %q: таблица гонки a\n%s\nпо events:\n%s", outDir, a, want)
+		}
+		b, err := os.ReadFile(filepath.Join(base, "b", "resulting_table"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), "Данные неполны: завершено кругов 2 из 3") {
+			t.Errorf("-out-dir %q: таблица гонки b не по её конфигурации:\n%s", outDir, b)
+		}
+		if _, err := os.Stat(filepath.Join(base, "c", "resulting_table")); err == nil {
+			t.Errorf("-out-dir %q: записана таблица гонки c с ошибкой", outDir)
+		}
+	}
+}
+
+func TestDumpLoadState(t *testing.T) {
+	configs := testConfigs(t, "")
+	want := processPaths(t, configs, "events")
+	feed, err := os.ReadFile("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(feed), "\n")
+
+	// После 26 строк открыт штрафной круг участника 1, после 36 — круги
+	// всех пятерых
+	for _, split := range []int{26, 36} {
+		dir := t.TempDir()
+		dump := filepath.Join(dir, "state.json")
+		opts := options{eventsPaths: []string{writeTemp(t, strings.Join(lines[:split], ""))}, format: "text", mode: modeStrict,
+			checkpointEvery: 1000, dumpStatePath: dump}
+		if err := processFiles(context.Background(), opts, race.NewSet(configs), newProcessStats(opts.mode, configs)); err != nil {
+			t.Fatal(err)
+		}
+
+		// Загруженное и снова выгруженное состояние совпадает с исходным
+		opts = options{eventsPaths: []string{writeTemp(t, "")}, format: "text", mode: modeStrict,
+			checkpointEvery: 1000, loadStatePath: dump, dumpStatePath: filepath.Join(dir, "again.json")}
+		if err := processFiles(context.Background(), opts, race.NewSet(configs), newProcessStats(opts.mode, configs)); err != nil {
+			t.Fatal(err)
+		}
+		first, err := os.ReadFile(dump)
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, err := os.ReadFile(opts.dumpStatePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(first) != string(again) {
+			t.Errorf("после %d строк: состояние изменилось при загрузке:\n%s\nисходное:\n%s", split, again, first)
+		}
+
+		// Продолжение ленты с загруженного состояния даёт ту же таблицу
+		opts = options{eventsPaths: []string{writeTemp(t, strings.Join(lines[split:], ""))}, format: "text", mode: modeStrict,
+			checkpointEvery: 1000, loadStatePath: dump}
+		races := race.NewSet(configs)
+		if err := processFiles(context.Background(), opts, races, newProcessStats(opts.mode, configs)); err != nil {
+			t.Fatal(err)
+		}
+		if got := reportText(t, races.Race("")); got != want {
+			t.Errorf("после %d строк: таблица\n%s\nбез перерыва:\n%s", split, got, want)
+		}
+	}
+}
+
+func TestUnregisteredCompetitors(t *testing.T) {
+	// Опечатка хронометриста: старт и круг участника 31 вместо 3
+	feed, err := os.ReadFile("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(feed)), "\n")
+	lines = append(lines, "[10:03:01.000] 4 31", "[10:15:44.000] 10 31")
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i][:14] < lines[j][:14] })
+
+	var logs bytes.Buffer
+	logrus.SetOutput(&logs)
+	defer logrus.SetOutput(io.Discard)
+
+	tests := []struct {
+		allow bool
+		ghost bool   // участник 31 в таблице
+		log   string // сводка отклонённых событий
+	}{
+		{allow: false, ghost: false, log: "Отклонены события незарегистрированных участников: 31 (2)"},
+		{allow: true, ghost: true},
+	}
+	for _, tt := range tests {
+		logs.Reset()
+		configs := testConfigs(t, "").WithOptions(race.Options{Logger: logrus.StandardLogger(), AllowUnregistered: tt.allow})
+		races := race.NewSet(configs)
+		if err := processEvents(context.Background(), textSource(strings.Join(lines, "\n")), races, newProcessStats(modeStrict, configs)); err != nil {
+			t.Fatalf("-allow-unregistered=%v: %v", tt.allow, err)
+		}
+		r := races.Race("")
+		r.LogDataIssues()
+
+		ghost := false
+		for _, row := range r.Results() {
+			ghost = ghost || row.ID == "31"
+		}
+		if ghost != tt.ghost {
+			t.Errorf("-allow-unregistered=%v: участник 31 в таблице: %v, ожидалось %v", tt.allow, ghost, tt.ghost)
+		}
+		if tt.log != "" && !strings.Contains(logs.String(), tt.log) {
+			t.Errorf("-allow-unregistered=%v: нет сводки «%s» в журнале:\n%s", tt.allow, tt.log, logs.String())
+		}
+		if !tt.allow && !strings.Contains(logs.String(), "событие участника 31 без регистрации отклонено") {
+			t.Errorf("нет предупреждения об отклонённом событии участника 31:\n%s", logs.String())
+		}
+	}
+}
+
+func TestCompetitorIDs(t *testing.T) {
+	lines := `
+[09:05:00.000] 1 03
+[09:05:01.000] 1 10
+[09:05:02.000] 1 2
+[09:10:00.000] 2 3 10:00:00.000
+[09:11:00.000] 1 abc!
+`
+	tests := []struct {
+		name, extra, mode string
+		ids               string // участники таблицы по порядку
+		err               string
+	}{
+		// "03" и "3" — один участник, порядок — числовой
+		{name: "числовые strict", mode: modeStrict, err: `строка 5: ID участника "abc!" должен быть числом`},
+		{name: "числовые lenient", mode: modeLenient, ids: "2 3 10"},
+		// По шаблону ID не приводятся: жеребьёвка "3" отклонена как событие
+		// незарегистрированного участника
+		{name: "шаблон", extra: `{"competitorIdPattern": "[0-9a-z!]+"}`, mode: modeStrict, ids: "2 03 10 abc!"},
+	}
+	for _, tt := range tests {
+		configs := testConfigs(t, tt.extra)
+		races := race.NewSet(configs)
+		err := processEvents(context.Background(), textSource(lines), races, newProcessStats(tt.mode, configs))
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: ошибка %v, ожидалась «%s»", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var ids []string
+		for _, row := range races.Race("").Results() {
+			ids = append(ids, row.ID)
+		}
+		if got := strings.Join(ids, " "); got != tt.ids {
+			t.Errorf("%s: участники %s, ожидалось %s", tt.name, got, tt.ids)
+		}
+	}
+}
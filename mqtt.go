@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	mqttQoS               = 1
+	mqttDisconnectTimeout = time.Second
+)
+
+// mqttConsumer подписывается на топики с событиями и на управляющий топик.
+// Сессия не очищается при переподключении, поэтому брокер дошлёт
+// пропущенные сообщения, а повторы отсекаются дедупликацией.
+type mqttConsumer struct {
+	client   mqtt.Client
+	messages chan brokerMessage
+}
+
+func newMQTTConsumer(broker, topic, controlTopic, endMarker string) (*mqttConsumer, error) {
+	c := &mqttConsumer{messages: make(chan brokerMessage, 256)}
+	rangeSegment := wildcardSegment(topic)
+
+	onEvent := func(_ mqtt.Client, msg mqtt.Message) {
+		payload := string(msg.Payload())
+		if rangeSegment >= 0 {
+			payload = withFiringRange(payload, strings.Split(msg.Topic(), "/")[rangeSegment])
+		}
+		c.messages <- brokerMessage{payload: []byte(payload), commit: ackMQTT(msg)}
+	}
+	onControl := func(_ mqtt.Client, msg mqtt.Message) {
+		if strings.TrimSpace(string(msg.Payload())) != endMarker {
+			logrus.Warnf("Неизвестное управляющее сообщение MQTT: %s", msg.Payload())
+			msg.Ack()
+			return
+		}
+		c.messages <- brokerMessage{payload: []byte(endMarker), commit: ackMQTT(msg)}
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(broker).
+		SetClientID("biathlon_system").
+		SetCleanSession(false).
+		SetAutoReconnect(true).
+		SetAutoAckDisabled(true).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			logrus.Warnf("Потеряно соединение с MQTT-брокером: %s", err)
+		}).
+		SetOnConnectHandler(func(client mqtt.Client) {
+			logrus.Infof("Подключение к MQTT-брокеру %s, подписка на %s и %s", broker, topic, controlTopic)
+			subscriptions := map[string]byte{topic: mqttQoS}
+			token := client.SubscribeMultiple(subscriptions, onEvent)
+			if token.Wait() && token.Error() != nil {
+				logrus.Errorf("Ошибка подписки на %s: %s", topic, token.Error())
+			}
+			if controlTopic != "" {
+				token = client.Subscribe(controlTopic, mqttQoS, onControl)
+				if token.Wait() && token.Error() != nil {
+					logrus.Errorf("Ошибка подписки на %s: %s", controlTopic, token.Error())
+				}
+			}
+		})
+
+	c.client = mqtt.NewClient(opts)
+	if token := c.client.Connect(); token.Wait() && token.Error() != nil {
+		return nil, fmt.Errorf("Ошибка подключения к MQTT-брокеру %s: %w", broker, token.Error())
+	}
+
+	return c, nil
+}
+
+func (c *mqttConsumer) fetch(ctx context.Context) (brokerMessage, error) {
+	select {
+	case msg := <-c.messages:
+		return msg, nil
+	case <-ctx.Done():
+		return brokerMessage{}, ctx.Err()
+	}
+}
+
+func (c *mqttConsumer) close() error {
+	c.client.Disconnect(uint(mqttDisconnectTimeout.Milliseconds()))
+	return nil
+}
+
+func ackMQTT(msg mqtt.Message) func(context.Context) error {
+	return func(context.Context) error {
+		msg.Ack()
+		return nil
+	}
+}
+
+// wildcardSegment возвращает номер сегмента топика, подставленного вместо "+"
+func wildcardSegment(topic string) int {
+	for i, segment := range strings.Split(topic, "/") {
+		if segment == "+" {
+			return i
+		}
+	}
+	return -1
+}
+
+// withFiringRange дополняет событие 5 номером огневого рубежа из топика,
+// если в самом сообщении номера нет
+func withFiringRange(payload, firingRange string) string {
+	payload = strings.TrimRight(payload, "\r\n")
+	fields := strings.Split(payload, " ")
+	if len(fields) < 2 {
+		return payload
+	}
+
+	switch fields[1] {
+	case "5":
+		if len(fields) == 3 {
+			return payload + " " + firingRange
+		}
+		if fields[3] != firingRange {
+			logrus.Warnf("Номер огневого рубежа в сообщении (%s) не совпадает с топиком (%s): %s", fields[3], firingRange, payload)
+		}
+	}
+
+	return payload
+}
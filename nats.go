@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/sirupsen/logrus"
+)
+
+// natsFinalize — управляющее сообщение, по которому все экземпляры формируют отчёт
+const natsFinalize = "finalize"
+
+// natsConsumer получает события из темы NATS. Экземпляры с одной очередью
+// делят поток событий между собой, а управляющая тема доставляется каждому.
+type natsConsumer struct {
+	conn     *nats.Conn
+	messages chan brokerMessage
+}
+
+func newNATSConsumer(url, subject, queue, controlSubject, endMarker string) (*natsConsumer, error) {
+	c := &natsConsumer{messages: make(chan brokerMessage, 256)}
+
+	conn, err := nats.Connect(url,
+		nats.Name("biathlon_system"),
+		// Пока соединения нет, ничего не буферизуем: пропуск должен быть виден
+		nats.ReconnectBufSize(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			logrus.Warnf("Потеряно соединение с NATS: %v; события за время разрыва будут пропущены", err)
+		}),
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			logrus.Warnf("Соединение с NATS восстановлено (%s); проверьте данные на пропуски", conn.ConnectedUrl())
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Ошибка подключения к NATS %s: %w", url, err)
+	}
+	c.conn = conn
+
+	onEvent := func(msg *nats.Msg) {
+		c.messages <- brokerMessage{payload: msg.Data, commit: noCommit}
+	}
+	if queue != "" {
+		_, err = conn.QueueSubscribe(subject, queue, onEvent)
+	} else {
+		_, err = conn.Subscribe(subject, onEvent)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("Ошибка подписки на %s: %w", subject, err)
+	}
+
+	_, err = conn.Subscribe(controlSubject, func(msg *nats.Msg) {
+		if strings.TrimSpace(string(msg.Data)) != natsFinalize {
+			logrus.Warnf("Неизвестное управляющее сообщение NATS: %s", msg.Data)
+			return
+		}
+		c.messages <- brokerMessage{payload: []byte(endMarker), commit: noCommit}
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("Ошибка подписки на %s: %w", controlSubject, err)
+	}
+	logrus.Infof("Подписка на %s (очередь %q) и %s", subject, queue, controlSubject)
+
+	return c, nil
+}
+
+func (c *natsConsumer) fetch(ctx context.Context) (brokerMessage, error) {
+	select {
+	case msg := <-c.messages:
+		return msg, nil
+	case <-ctx.Done():
+		return brokerMessage{}, ctx.Err()
+	}
+}
+
+func (c *natsConsumer) close() error {
+	return c.conn.Drain()
+}
+
+// noCommit используется для брокеров без подтверждения доставки
+func noCommit(context.Context) error {
+	return nil
+}
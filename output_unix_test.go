@@ -0,0 +1,43 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestOutNamedPipe(t *testing.T) {
+	// Именованный канал нельзя подменять обычным файлом при атомарной записи
+	path := filepath.Join(t.TempDir(), "results")
+	if err := syscall.Mkfifo(path, 0o644); err != nil {
+		t.Skipf("mkfifo: %v", err)
+	}
+	received := make(chan string)
+	go func() {
+		pipe, err := os.Open(path)
+		if err != nil {
+			received <- err.Error()
+			return
+		}
+		defer pipe.Close()
+		data, _ := io.ReadAll(pipe)
+		received <- string(data)
+	}()
+
+	opts := testOptions(t, "events")
+	opts.outPath = path
+	if err := run(context.Background(), opts); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if got, want := <-received, readGolden(t, "resulting_table"); got != want {
+		t.Errorf("прочитано из канала:\n%s\nэталон:\n%s", got, want)
+	}
+	if info, err := os.Lstat(path); err != nil || info.Mode()&os.ModeNamedPipe == 0 {
+		t.Errorf("%s больше не именованный канал", path)
+	}
+}
@@ -0,0 +1,227 @@
+// Package parser разбирает строки входных событий в Event независимо от
+// формата исходного файла
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event — входящее событие, не зависящее от формата исходного файла
+type Event struct {
+	Time         time.Time
+	TimeStr      string // время в квадратных скобках, как оно выводится в лог
+	ID           int
+	CompetitorID string
+	Extra        string
+	Raw          string
+	Line         int
+	// Race — идентификатор гонки для потоков, в которых чередуются события
+	// нескольких гонок; пустой у событий без идентификатора
+	Race string
+}
+
+// Виды ошибок разбора события, различаемые через errors.Is
+var (
+	ErrTime   = errors.New("неверное время события")
+	ErrID     = errors.New("неверный ID события")
+	ErrFields = errors.New("не хватает полей события")
+	ErrSyntax = errors.New("неверный синтаксис события")
+)
+
+// Error — ошибка разбора события; Kind — один из ErrTime, ErrID, ErrFields,
+// ErrSyntax. Исходная ошибка, если есть, тоже доступна через errors.Is и
+// errors.As.
+type Error struct {
+	Kind  error
+	msg   string
+	cause error
+}
+
+func (e *Error) Error() string { return e.msg }
+
+func (e *Error) Unwrap() []error {
+	if e.cause == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.cause}
+}
+
+func newError(kind error, format string, args ...any) error {
+	return &Error{Kind: kind, msg: fmt.Sprintf(format, args...)}
+}
+
+// ParseEvent разбирает строку вида [HH:MM:SS.sss] eventID competitorID extraParams,
+// перед которой может стоять идентификатор гонки: R1 [HH:MM:SS.sss] ...
+// Номер строки Line заполняет читатель потока событий.
+func ParseEvent(line string) (Event, error) {
+	// Первые поля разбираются по позициям, остаток строки — extraParams как есть
+	var race string
+	rest := line
+	if token, tail, ok := strings.Cut(rest, " "); ok && raceToken.MatchString(token) {
+		race, rest = token, tail
+	}
+
+	timeStr, rest, ok1 := cutTime(rest)
+	idEvStr, rest, ok2 := strings.Cut(rest, " ")
+	idComp, extra, _ := strings.Cut(rest, " ")
+	if !ok1 || !ok2 {
+		return Event{}, newError(ErrFields, "ожидалось не менее 3 полей, событие: %s", line)
+	}
+
+	ev, err := ParseFields(timeStr, idEvStr, idComp, extra, line)
+	ev.Race = race
+	return ev, err
+}
+
+// raceToken — идентификатор гонки перед временем события. Остальные первые
+// поля разбираются как время: повреждённое время даёт ErrTime, а не
+// принимается за гонку.
+var raceToken = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+
+// cutTime отделяет поле времени от остатка строки. Время с датой содержит
+// пробел, поэтому поле в скобках берётся целиком до "]".
+func cutTime(s string) (timeStr, rest string, ok bool) {
+	if strings.HasPrefix(s, "[") {
+		if i := strings.Index(s, "] "); i >= 0 {
+			return s[:i+1], s[i+2:], true
+		}
+	}
+	return strings.Cut(s, " ")
+}
+
+// extraRequired — события, у которых обязателен дополнительный параметр
+var extraRequired = map[int]string{
+	2:  "время старта",
+	5:  "номер огневого рубежа",
+	6:  "номер мишени",
+	11: "комментарий",
+}
+
+// checkFields проверяет, что у события есть все поля, нужные для его ID
+func checkFields(ev Event) error {
+	if ev.CompetitorID == "" {
+		return newError(ErrFields, "не указан ID участника, событие: %s", ev.Raw)
+	}
+	if name, ok := extraRequired[ev.ID]; ok && ev.Extra == "" {
+		return newError(ErrFields, "у события %d не указан параметр «%s», событие: %s", ev.ID, name, ev.Raw)
+	}
+	return nil
+}
+
+// ParseFields собирает событие из уже разделённых полей: времени в
+// квадратных скобках, ID события, ID участника и extraParams
+func ParseFields(timeStr, idEvStr, idComp, extra, raw string) (Event, error) {
+	timeEv, err := ParseTime(timeStr)
+	if err != nil {
+		return Event{}, newError(ErrTime, "Ошибка парсинга времени события: %s,  событие: %s", err, raw)
+	}
+
+	idEv, err := strconv.Atoi(idEvStr)
+	if err != nil {
+		return Event{}, newError(ErrID, "Ошибка преобразования ID события в число: %s, событие: %s", err, raw)
+	}
+
+	ev := Event{
+		Time:         timeEv,
+		TimeStr:      timeStr,
+		ID:           idEv,
+		CompetitorID: idComp,
+		Extra:        extra,
+		Raw:          raw,
+	}
+	return ev, checkFields(ev)
+}
+
+type jsonEvent struct {
+	Time       *string `json:"time"`
+	Event      *int    `json:"event"`
+	Competitor *string `json:"competitor"`
+	Extra      string  `json:"extra"`
+	Race       string  `json:"race"`
+}
+
+// ParseJSON разбирает строку вида
+// {"time":"09:30:01.005","event":4,"competitor":"3","extra":""}.
+// Неизвестные поля игнорируются.
+func ParseJSON(line string) (Event, error) {
+	var raw jsonEvent
+	if err := json.Unmarshal([]byte(line), &raw); err != nil {
+		return Event{}, &Error{Kind: ErrSyntax, msg: fmt.Sprintf("Ошибка разбора JSON события: %s, событие: %s", err, line), cause: err}
+	}
+
+	switch {
+	case raw.Time == nil:
+		return Event{}, newError(ErrFields, "в событии отсутствует поле time, событие: %s", line)
+	case raw.Event == nil:
+		return Event{}, newError(ErrFields, "в событии отсутствует поле event, событие: %s", line)
+	case raw.Competitor == nil:
+		return Event{}, newError(ErrFields, "в событии отсутствует поле competitor, событие: %s", line)
+	}
+
+	timeStr := "[" + *raw.Time + "]"
+	timeEv, err := ParseTime(timeStr)
+	if err != nil {
+		return Event{}, newError(ErrTime, "Ошибка парсинга времени события: %s,  событие: %s", err, line)
+	}
+
+	ev := Event{
+		Time:         timeEv,
+		TimeStr:      timeStr,
+		ID:           *raw.Event,
+		CompetitorID: *raw.Competitor,
+		Extra:        raw.Extra,
+		Raw:          line,
+		Race:         raw.Race,
+	}
+	return ev, checkFields(ev)
+}
+
+// ParseTime разбирает время события в формате [HH:MM:SS.sss]
+func ParseTime(timeStr string) (time.Time, error) {
+	if len(timeStr) < 2 || timeStr[0] != '[' || timeStr[len(timeStr)-1] != ']' {
+		return time.Time{}, fmt.Errorf("время %q должно быть в квадратных скобках", timeStr)
+	}
+
+	return ParseClock(timeStr[1 : len(timeStr)-1])
+}
+
+// DateFormat — необязательная дата перед временем: [2006-01-02 15:04:05.000]
+const DateFormat = "2006-01-02 "
+
+// ParseClock разбирает время HH:MM:SS.sss, которому может предшествовать
+// дата. Ручные записи бывают без миллисекунд — тогда они считаются равными
+// .000. Время без даты получает нулевой год, дату ему назначает WithDate.
+func ParseClock(s string) (time.Time, error) {
+	layout := TimeFormat
+	if len(s) > len(DateFormat) && s[4] == '-' {
+		layout = DateFormat + TimeFormat
+	}
+
+	t, err := time.Parse(layout, s)
+	if err == nil {
+		return t, nil
+	}
+	if t, errSec := time.Parse(layout[:len(layout)-4], s); errSec == nil {
+		return t, nil
+	}
+	return time.Time{}, err
+}
+
+// WithDate переносит время без даты на дату старта гонки из конфигурации.
+// Если и в конфигурации дата не указана, время остаётся без изменений.
+func WithDate(t, start time.Time) time.Time {
+	if t.Year() != 0 {
+		return t
+	}
+	year, month, day := start.Date()
+	return t.AddDate(year, int(month)-1, day-1)
+}
+
+// TimeFormat — время события без скобок: 15:04:05.000
+const TimeFormat = "15:04:05.000"
@@ -0,0 +1,145 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseEventBroken(t *testing.T) {
+	tests := []struct {
+		line string
+		kind error
+	}{
+		{"", ErrFields},
+		{" ", ErrFields},
+		{"[09:30:01.005]", ErrFields},
+		{"[09:30:01.005] 1", ErrFields},
+		{"[09:30:01.005] 1 ", ErrFields},
+		{"[09:30:01.005] 2 1", ErrFields},
+		{"[09:30:01.005] 5 1", ErrFields},
+		{"[09:30:01.005] 6 1", ErrFields},
+		{"[09:30:01.005] 11 1", ErrFields},
+		{"[09:30:01.005] x 1", ErrID},
+		{"[09:30:01.005] 1.5 1", ErrID},
+		{"[] 1 1", ErrTime},
+		{"[ 1 1", ErrTime},
+		{"[25:00:00.000] 1 1", ErrTime},
+		{"[09:30] 1 1", ErrTime},
+		{"09:30:01.005 1 1", ErrTime},
+		{"10:00:00.000] 1 1", ErrTime},
+		{"1R [09:30:01.005] 1 1", ErrTime},
+		{"R1: [09:30:01.005] 1 1", ErrTime},
+		{"R1", ErrFields},
+		{"R1 [09:30:01.005]", ErrFields},
+		{"[[09:30:01.005]] 1 1", ErrTime},
+		{"\x00\xff\xfe", ErrFields},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseEvent(tt.line)
+		if !errors.Is(err, tt.kind) {
+			t.Errorf("ParseEvent(%q): ошибка %v, ожидалась %v", tt.line, err, tt.kind)
+		}
+	}
+}
+
+func TestParseEventRace(t *testing.T) {
+	for _, race := range []string{"R1", "sprint_men", "W-7"} {
+		ev, err := ParseEvent(race + " [09:30:01.005] 1 1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev.Race != race || ev.CompetitorID != "1" {
+			t.Errorf("гонка %q, участник %q, ожидались %q и 1", ev.Race, ev.CompetitorID, race)
+		}
+	}
+}
+
+func TestParseJSONBroken(t *testing.T) {
+	tests := []struct {
+		line string
+		kind error
+	}{
+		{`{"time":"09:30:01.005","event":1`, ErrSyntax},
+		{`{"time":"09:30:01.005","event":"1","competitor":"1"}`, ErrSyntax},
+		{`{"event":1,"competitor":"1"}`, ErrFields},
+		{`{"time":"09:30","event":1,"competitor":"1"}`, ErrTime},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseJSON(tt.line)
+		if !errors.Is(err, tt.kind) {
+			t.Errorf("ParseJSON(%q): ошибка %v, ожидалась %v", tt.line, err, tt.kind)
+		}
+	}
+
+	// Исходная ошибка encoding/json сохраняется
+	_, err := ParseJSON(`{"time":1,"event":1,"competitor":"1"}`)
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		t.Errorf("ParseJSON: ошибка %v, ожидалась *json.UnmarshalTypeError", err)
+	}
+}
+
+func FuzzParseEvent(f *testing.F) {
+	for _, seed := range []string{
+		"[09:30:01.005] 1 1",
+		"[09:30:01.005] 11 1 Lost in the forest",
+		"R1 [2024-03-10 09:30:01.005] 2 1 [10:00:00.000]",
+		"[09:30:01.005] 1",
+		"[",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, line string) {
+		ev, err := ParseEvent(line)
+		if err == nil && ev.CompetitorID == "" {
+			t.Errorf("ParseEvent(%q): событие без ID участника", line)
+		}
+	})
+}
+
+func TestParseEventComment(t *testing.T) {
+	for _, comment := range []string{
+		"Fell on descent  [medical attention]",
+		"  two  leading spaces and [brackets] [",
+		"[10:00:00.000] 1 1",
+	} {
+		ev, err := ParseEvent("[10:30:00.000] 11 1 " + comment)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev.Extra != comment {
+			t.Errorf("комментарий %q, ожидался %q", ev.Extra, comment)
+		}
+	}
+}
+
+func TestParseClockPrecision(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"09:30:01", "0000-01-01 09:30:01.000"},
+		{"09:30:01.000", "0000-01-01 09:30:01.000"},
+		{"09:30:01.005", "0000-01-01 09:30:01.005"},
+		{"2024-03-10 09:30:01", "2024-03-10 09:30:01.000"},
+		{"2024-03-10 09:30:01.005", "2024-03-10 09:30:01.005"},
+	}
+	for _, tt := range tests {
+		got, err := ParseClock(tt.in)
+		if err != nil {
+			t.Errorf("ParseClock(%q): %v", tt.in, err)
+			continue
+		}
+		if s := got.Format(DateFormat + TimeFormat); s != tt.want {
+			t.Errorf("ParseClock(%q) = %s, ожидалось %s", tt.in, s, tt.want)
+		}
+	}
+
+	for _, in := range []string{"09:30", "09:30:01.", "09:30:01.5x", "2024-03-10"} {
+		if _, err := ParseClock(in); err == nil {
+			t.Errorf("ParseClock(%q): нет ошибки", in)
+		}
+	}
+}
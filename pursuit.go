@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"biathlon_system/internal/race"
+)
+
+// loadPursuitBasis читает итоговую таблицу квалификации для -pursuit-basis:
+// JSON-отчёт или текстовый resulting_table. Возвращает отставание каждого
+// финишировавшего участника от победителя по ID в каноническом виде cfg.
+func loadPursuitBasis(path string, cfg race.Config) (map[string]time.Duration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var times map[string]time.Duration
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		times, err = jsonFinishTimes(data)
+	} else {
+		times, err = textFinishTimes(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(times) == 0 {
+		return nil, fmt.Errorf("%s: в квалификации нет финишировавших участников", path)
+	}
+
+	leader := time.Duration(-1)
+	for _, total := range times {
+		if leader < 0 || total < leader {
+			leader = total
+		}
+	}
+	gaps := make(map[string]time.Duration, len(times))
+	for id, total := range times {
+		canonical, err := cfg.CompetitorID(id)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		gaps[canonical] = total - leader
+	}
+	return gaps, nil
+}
+
+// jsonFinishTimes — время гонки финишировавших участников из JSON-отчёта
+func jsonFinishTimes(data []byte) (map[string]time.Duration, error) {
+	var rows []struct {
+		Competitor string `json:"competitor"`
+		Status     string `json:"status"`
+		TotalTime  string `json:"totalTime"`
+	}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, err
+	}
+
+	times := make(map[string]time.Duration)
+	for _, row := range rows {
+		if row.Status != "Finished" {
+			continue
+		}
+		total, err := race.ParseDuration(row.TotalTime)
+		if err != nil {
+			return nil, fmt.Errorf("участник %s: %w", row.Competitor, err)
+		}
+		times[row.Competitor] = total
+	}
+	return times, nil
+}
+
+// textFinishTimes — время гонки финишировавших участников из текстового
+// отчёта: строки вида {00:25:18.356} 2 ..., в том числе с местом в начале
+// (-with-rank). Строки [NotStarted], [NotFinished] и [Invalid] пропускаются.
+func textFinishTimes(data []byte) (map[string]time.Duration, error) {
+	times := make(map[string]time.Duration)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		open, end := strings.Index(text, "{"), strings.Index(text, "}")
+		if open < 0 || end < open || strings.Contains(text[:open], "[") {
+			continue
+		}
+		fields := strings.Fields(text[open+1 : end])
+		rest := strings.Fields(text[end+1:])
+		if len(fields) == 0 || len(rest) == 0 {
+			return nil, fmt.Errorf("строка %d: не удалось разобрать строку таблицы", line)
+		}
+		total, err := race.ParseDuration(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("строка %d: %w", line, err)
+		}
+		times[rest[0]] = total
+	}
+	return times, scanner.Err()
+}
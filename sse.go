@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"biathlon_system/internal/race"
+	"biathlon_system/parser"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	sseKeepAlive = 15 * time.Second
+	// sseBuffer — снимков в очереди клиента; при переполнении отбрасываются
+	// самые старые
+	sseBuffer = 16
+)
+
+// standingsStream рассылает клиентам GET /standings/stream снимки таблицы,
+// когда окончание круга, финиш или снятие участника меняют порядок в ней
+type standingsStream struct {
+	mu    sync.Mutex
+	clock race.Clock
+	// race возвращает гонку по её идентификатору
+	race func(raceID string) *race.Race
+	hubs map[string]*standingsHub
+	// order — порядок таблицы гонки в последнем разосланном снимке
+	order map[string]string
+}
+
+func newStandingsStream(clock race.Clock) *standingsStream {
+	return &standingsStream{clock: clock, hubs: make(map[string]*standingsHub), order: make(map[string]string)}
+}
+
+// update рассылает снимок таблицы гонки события ev, если у неё есть клиенты
+// и порядок в таблице изменился
+func (s *standingsStream) update(ev parser.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hub, ok := s.hubs[ev.Race]
+	if !ok || s.race == nil {
+		return
+	}
+	snapshot, order, err := s.snapshot(ev.Race)
+	if err != nil {
+		logrus.Errorf("Ошибка формирования результатов: %s", err)
+		return
+	}
+	if order == s.order[ev.Race] {
+		return
+	}
+	s.order[ev.Race] = order
+	hub.publish(snapshot)
+}
+
+// snapshot возвращает ответ /standings для гонки raceID и порядок в таблице
+func (s *standingsStream) snapshot(raceID string) ([]byte, string, error) {
+	rows, processed := s.race(raceID).Standings()
+	resp := statusSnapshot(rows, processed, s.clock)
+	body, err := json.Marshal(resp)
+	return body, standingsOrder(rows), err
+}
+
+// standingsOrder — подпись порядка таблицы: места участников по ходу гонки и
+// состояния всех участников
+func standingsOrder(rows []race.Result) string {
+	var b strings.Builder
+	for _, row := range race.LiveStandings(rows) {
+		b.WriteString(row.ID + ":" + strconv.Itoa(row.Place) + " ")
+	}
+	for _, row := range rows {
+		b.WriteString(row.ID + ":" + row.Status() + " ")
+	}
+	return b.String()
+}
+
+// serve отправляет клиенту сначала полный снимок таблицы, затем снимки после
+// каждого изменения порядка и комментарии раз в sseKeepAlive без изменений
+func (s *standingsStream) serve(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "потоковая передача не поддерживается", http.StatusInternalServerError)
+		return
+	}
+	raceID := r.URL.Query().Get("race")
+
+	// Снимок и подписка под одной блокировкой: изменение между ними не
+	// потеряется
+	s.mu.Lock()
+	hub, ok := s.hubs[raceID]
+	if !ok {
+		hub = newBufferedHub(sseBuffer)
+		s.hubs[raceID] = hub
+	}
+	updates := hub.subscribe()
+	first, order, err := s.snapshot(raceID)
+	s.order[raceID] = order
+	s.mu.Unlock()
+	defer hub.unsubscribe(updates)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	if _, err := fmt.Fprintf(w, "event: standings\ndata: %s\n\n", first); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	for {
+		var message string
+		select {
+		case <-r.Context().Done():
+			return
+		case snapshot := <-updates:
+			message = fmt.Sprintf("event: standings\ndata: %s\n\n", snapshot)
+		case <-s.clock.After(sseKeepAlive):
+			message = ": keep-alive\n\n"
+		}
+		if _, err := fmt.Fprint(w, message); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
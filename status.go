@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"biathlon_system/internal/race"
+	"biathlon_system/internal/report"
+
+	"github.com/sirupsen/logrus"
+)
+
+// serveStatus отдаёт по HTTP текущие результаты, пока события принимаются в
+// другом режиме (параметр -status): GET /standings — таблицу в структуре
+// JSON-отчёта, GET /standings/stream — её обновления Server-Sent Events,
+// GET /competitors/{id} — подробные данные участника. Сервер только читает
+// состояние гонки. raceOf возвращает гонку по параметру запроса race.
+func serveStatus(ctx context.Context, addr string, raceOf func(raceID string) *race.Race, stream *standingsStream, clock race.Clock) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           newStatusHandler(raceOf, stream, clock),
+		ReadHeaderTimeout: 10 * time.Second,
+		// Потоки /standings/stream завершаются вместе с ctx, иначе Shutdown
+		// ждал бы их до таймаута
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		logrus.Infof("HTTP-сервер результатов слушает %s", addr)
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// statusResponse — ответ сервера результатов: время формирования снимка и
+// число обработанных к этому моменту событий
+type statusResponse struct {
+	Generated       string `json:"generated"`
+	ProcessedEvents int    `json:"processedEvents"`
+	Standings       any    `json:"standings,omitempty"`
+	Competitor      any    `json:"competitor,omitempty"`
+}
+
+// statusSnapshot — ответ /standings по строкам таблицы rows и числу
+// обработанных событий processed, снятым одним снимком
+func statusSnapshot(rows []race.Result, processed int, clock race.Clock) statusResponse {
+	return statusResponse{
+		Generated:       clock.Now().Format(time.RFC3339Nano),
+		ProcessedEvents: processed,
+		Standings:       report.StandingsJSON(rows),
+	}
+}
+
+func newStatusHandler(raceOf func(raceID string) *race.Race, stream *standingsStream, clock race.Clock) http.Handler {
+	// snapshot снимает строки таблицы и число событий под одной блокировкой
+	snapshot := func(r *http.Request) (*race.Race, []race.Result, statusResponse) {
+		current := raceOf(r.URL.Query().Get("race"))
+		rows, processed := current.Standings()
+		return current, rows, statusResponse{Generated: clock.Now().Format(time.RFC3339Nano), ProcessedEvents: processed}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/standings", func(w http.ResponseWriter, r *http.Request) {
+		if !allowGet(w, r) {
+			return
+		}
+		current := raceOf(r.URL.Query().Get("race"))
+		rows, processed := current.Standings()
+		writeJSON(w, statusSnapshot(rows, processed, clock))
+	})
+	mux.HandleFunc("/standings/stream", func(w http.ResponseWriter, r *http.Request) {
+		if !allowGet(w, r) {
+			return
+		}
+		stream.serve(w, r)
+	})
+	mux.HandleFunc("/competitors/", func(w http.ResponseWriter, r *http.Request) {
+		if !allowGet(w, r) {
+			return
+		}
+		current, rows, resp := snapshot(r)
+		id, err := current.Config().CompetitorID(strings.TrimPrefix(r.URL.Path, "/competitors/"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, row := range rows {
+			if row.ID == id {
+				resp.Competitor = report.CompetitorJSON(row)
+				writeJSON(w, resp)
+				return
+			}
+		}
+		http.Error(w, "участник "+id+" не найден", http.StatusNotFound)
+	})
+	return mux
+}
+
+func allowGet(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method == http.MethodGet {
+		return true
+	}
+	w.Header().Set("Allow", http.MethodGet)
+	http.Error(w, "метод не поддерживается", http.StatusMethodNotAllowed)
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		logrus.Errorf("Ошибка формирования результатов: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(body)
+}
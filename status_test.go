@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"biathlon_system/internal/race"
+	"biathlon_system/parser"
+)
+
+// midRaceStatus возвращает сервер результатов гонки после первых n событий
+// events
+func midRaceStatus(t *testing.T, n int) *httptest.Server {
+	t.Helper()
+
+	races := race.NewSet(testConfigs(t, ""))
+	file, err := os.Open("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for i := 0; i < n && scanner.Scan(); i++ {
+		ev, err := parser.ParseEvent(scanner.Text())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := races.Apply(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return httptest.NewServer(newStatusHandler(races.Race, nil, race.SystemClock{}))
+}
+
+// getStatus выполняет GET path и разбирает ответ JSON в v
+func getStatus(t *testing.T, url string, v any) {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: статус %d", url, resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Errorf("GET %s: Content-Type %q", url, ct)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+}
+
+func TestStatusStandingsMidRace(t *testing.T) {
+	// После 36 событий все пятеро на первом круге, участник 3 на рубеже
+	server := midRaceStatus(t, 36)
+	defer server.Close()
+
+	var resp struct {
+		Generated       string `json:"generated"`
+		ProcessedEvents int    `json:"processedEvents"`
+		Standings       []struct {
+			Competitor string `json:"competitor"`
+			Status     string `json:"status"`
+		} `json:"standings"`
+	}
+	getStatus(t, server.URL+"/standings", &resp)
+
+	if _, err := time.Parse(time.RFC3339Nano, resp.Generated); err != nil {
+		t.Errorf("generated %q: %v", resp.Generated, err)
+	}
+	if resp.ProcessedEvents != 36 {
+		t.Errorf("processedEvents %d, ожидалось 36", resp.ProcessedEvents)
+	}
+	if len(resp.Standings) != 5 {
+		t.Fatalf("участников %d, ожидалось 5", len(resp.Standings))
+	}
+	for _, row := range resp.Standings {
+		if row.Status != "Running" {
+			t.Errorf("участник %s: статус %s, ожидался Running", row.Competitor, row.Status)
+		}
+	}
+}
+
+func TestStatusCompetitorMidRace(t *testing.T) {
+	server := midRaceStatus(t, 36)
+	defer server.Close()
+
+	type lap struct {
+		Start string `json:"start"`
+		Open  bool   `json:"open"`
+	}
+	var resp struct {
+		ProcessedEvents int `json:"processedEvents"`
+		Competitor      struct {
+			Competitor  string `json:"competitor"`
+			Laps        []lap  `json:"laps"`
+			RangeVisits []lap  `json:"rangeVisits"`
+		} `json:"competitor"`
+	}
+	getStatus(t, server.URL+"/competitors/3", &resp)
+
+	c := resp.Competitor
+	if c.Competitor != "3" || resp.ProcessedEvents != 36 {
+		t.Errorf("участник %s, processedEvents %d, ожидались 3 и 36", c.Competitor, resp.ProcessedEvents)
+	}
+	if len(c.Laps) != 1 || !c.Laps[0].Open || c.Laps[0].Start != "10:03:00.887" {
+		t.Errorf("круги %+v, ожидался открытый круг со стартом 10:03:00.887", c.Laps)
+	}
+	if len(c.RangeVisits) != 1 || !c.RangeVisits[0].Open || c.RangeVisits[0].Start != "10:11:54.557" {
+		t.Errorf("огневые рубежи %+v, ожидалось открытое посещение с 10:11:54.557", c.RangeVisits)
+	}
+
+	for path, status := range map[string]int{"/competitors/42": http.StatusNotFound, "/competitors/": http.StatusBadRequest} {
+		r, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Body.Close()
+		if r.StatusCode != status {
+			t.Errorf("GET %s: статус %d, ожидался %d", path, r.StatusCode, status)
+		}
+	}
+}
+
+// readStreamEvent читает из потока Server-Sent Events следующее событие
+// standings и разбирает его данные в v
+func readStreamEvent(t *testing.T, stream *bufio.Reader, v any) {
+	t.Helper()
+
+	var name, data string
+	for {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			t.Fatalf("поток прерван: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && name != "":
+			if name != "standings" {
+				t.Fatalf("событие %q, ожидалось standings", name)
+			}
+			if err := json.Unmarshal([]byte(data), v); err != nil {
+				t.Fatalf("данные %s: %v", data, err)
+			}
+			return
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestStatusStandingsStream(t *testing.T) {
+	stream := newStandingsStream(race.SystemClock{})
+	configs := testConfigs(t, "").WithOptions(race.Options{Logger: race.NopLogger{}, Hooks: standingsHooks(stream.update)})
+	races := race.NewSet(configs)
+	stream.race = races.Race
+	server := httptest.NewServer(newStatusHandler(races.Race, stream, race.SystemClock{}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/standings/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type %q", ct)
+	}
+	body := bufio.NewReader(resp.Body)
+
+	type snapshot struct {
+		ProcessedEvents int `json:"processedEvents"`
+		Standings       []struct {
+			Competitor string `json:"competitor"`
+		} `json:"standings"`
+	}
+	// Подключившийся клиент сначала получает полный снимок
+	var first snapshot
+	readStreamEvent(t, body, &first)
+	if first.ProcessedEvents != 0 || len(first.Standings) != 0 {
+		t.Errorf("первый снимок %+v, ожидалась пустая таблица", first)
+	}
+
+	// Старты порядок не меняют, первое окончание круга в строке 43 — меняет
+	file, err := os.Open("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for i := 0; i < 43 && scanner.Scan(); i++ {
+		ev, err := parser.ParseEvent(scanner.Text())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := races.Apply(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var next snapshot
+	readStreamEvent(t, body, &next)
+	if next.ProcessedEvents != 43 || len(next.Standings) != 5 || next.Standings[0].Competitor != "1" {
+		t.Errorf("снимок после окончания круга: processedEvents %d, участников %d, ожидались 43 и 5 с участником 1 впереди", next.ProcessedEvents, len(next.Standings))
+	}
+}
+
+func TestStandingsHubDropsOldest(t *testing.T) {
+	// Зависший клиент не блокирует рассылку: в очереди остаются последние
+	// sseBuffer снимков
+	hub := newBufferedHub(sseBuffer)
+	updates := hub.subscribe()
+	for i := 0; i < sseBuffer+4; i++ {
+		hub.publish([]byte(strconv.Itoa(i)))
+	}
+	if len(updates) != sseBuffer {
+		t.Fatalf("в очереди %d снимков, ожидалось %d", len(updates), sseBuffer)
+	}
+	if oldest := string(<-updates); oldest != "4" {
+		t.Errorf("самый старый снимок в очереди %s, ожидался 4", oldest)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"biathlon_system/internal/race"
+
+	"github.com/sirupsen/logrus"
+)
+
+// runSuperSprint обрабатывает квалификацию суперспринта из -events, отбирает
+// в финал лучших Qualifiers участников и обрабатывает события финала из
+// -final. Итоговая таблица — таблица финала с местом и временем квалификации.
+func runSuperSprint(ctx context.Context, opts options, configs race.Configs) error {
+	qualification := race.NewSet(configs)
+	stats := newProcessStats(opts.mode, configs)
+	stats.rejectUnknown = opts.rejectUnknown
+	if err := processFiles(ctx, opts, qualification, stats); err != nil {
+		return inputError(fmt.Errorf("Квалификация: %w", err))
+	}
+	qualifying := qualification.Race("")
+	qualifying.Finalize()
+	qualifying.LogDataIssues()
+	qualified := race.Qualify(qualifying.Results(), configs.Base.Qualifiers)
+	if len(qualified) == 0 {
+		return inputError(errors.New("Квалификация: нет финишировавших участников"))
+	}
+	logrus.Infof("В финал суперспринта прошли участников: %d", len(qualified))
+
+	finalConfigs := configs.Final(qualified)
+	finalOpts := opts
+	finalOpts.eventsPaths = []string{opts.finalPath}
+	finalOpts.configs = finalConfigs
+	final := race.NewSet(finalConfigs)
+	finalStats := newProcessStats(opts.mode, finalConfigs)
+	finalStats.rejectUnknown = opts.rejectUnknown
+	if err := processFiles(ctx, finalOpts, final, finalStats); err != nil {
+		return inputError(fmt.Errorf("Финал: %w", err))
+	}
+
+	if err := writeRaceReports(final, opts.reportOutputs(), opts.outEventsPath); err != nil {
+		return outputError(err)
+	}
+	if err := errors.Join(stats.incomplete(), finalStats.incomplete()); err != nil {
+		return &exitError{code: exitIncomplete, err: err}
+	}
+	return nil
+}
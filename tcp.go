@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"biathlon_system/internal/race"
+
+	"github.com/sirupsen/logrus"
+)
+
+// serveTCP принимает события по TCP, по одному событию на строку; события
+// с идентификатором гонки идут в свою гонку races.
+// После отмены ctx новые подключения не принимаются, а возврат
+// происходит, когда закроются все уже открытые.
+func serveTCP(ctx context.Context, addr string, races *race.Set, newSource func(io.Reader) eventSource) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("Приём событий по TCP на %s", listener.Addr())
+	return acceptTCP(ctx, listener, races, newSource)
+}
+
+// acceptTCP принимает подключения listener до отмены ctx
+func acceptTCP(ctx context.Context, listener net.Listener, races *race.Set, newSource func(io.Reader) eventSource) error {
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			logrus.Info("Приём подключений остановлен, ожидание закрытия открытых подключений")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer conn.Close()
+			handleConn(conn, races, newSource)
+		}()
+	}
+}
+
+// handleConn обрабатывает события одного подключения; ошибочные строки
+// пропускаются, чтобы один клиент не мог остановить приём
+func handleConn(conn net.Conn, races *race.Set, newSource func(io.Reader) eventSource) {
+	remote := conn.RemoteAddr()
+	logrus.Infof("Подключение %s", remote)
+	defer logrus.Infof("Подключение %s закрыто", remote)
+
+	src := newSource(conn)
+	for {
+		ev, err := src.next()
+		if err == io.EOF {
+			return
+		}
+		var lineErr *lineError
+		if errors.As(err, &lineErr) {
+			logrus.Errorf("%s: %s", remote, err)
+			continue
+		}
+		if err != nil {
+			logrus.Errorf("%s: %s", remote, err)
+			return
+		}
+
+		if err := races.Apply(ev); err != nil {
+			logrus.Errorf("%s: строка %d: %s", remote, ev.Line, err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"biathlon_system/internal/race"
+)
+
+func TestTCPTwoConnections(t *testing.T) {
+	// Участники 1–3 и 4–5 передаются по разным подключениям; во втором есть
+	// ошибочная строка, которая не должна остановить приём
+	configs := testConfigs(t, "")
+	want := processPaths(t, configs, "events")
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	races := race.NewSet(configs)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- acceptTCP(ctx, listener, races, eventFormats["text"]) }()
+
+	for _, name := range []string{"conn1", "conn2"} {
+		feed, err := os.ReadFile(filepath.Join("testdata", "tcp", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Write(feed); err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}
+
+	// Подключения обрабатываются асинхронно: ждём, пока будут применены все
+	// 104 события
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if _, applied := races.Race("").Standings(); applied == 104 {
+			break
+		}
+	}
+	cancel()
+	if err := <-served; err != nil {
+		t.Errorf("acceptTCP: %v", err)
+	}
+	if got := reportText(t, races.Race("")); got != want {
+		t.Errorf("таблица по TCP:\n%s\nпо файлу:\n%s", got, want)
+	}
+}
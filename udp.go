@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+
+	"biathlon_system/internal/race"
+	"biathlon_system/parser"
+
+	"github.com/sirupsen/logrus"
+)
+
+const maxDatagramSize = 64 * 1024
+
+// serveUDP принимает события UDP-датаграммами, по одному событию в датаграмме,
+// до отмены ctx
+func serveUDP(ctx context.Context, addr string, races *race.Set, newSource func(io.Reader) eventSource) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("Приём событий по UDP на %s", conn.LocalAddr())
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	seen := newEventDedup()
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, remote, err := conn.ReadFrom(buf)
+		if errors.Is(err, net.ErrClosed) {
+			logrus.Info("Приём датаграмм остановлен")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		payload := bytes.TrimRight(buf[:n], "\r\n")
+		handleDatagram(payload, remote, races, newSource, seen)
+	}
+}
+
+func handleDatagram(payload []byte, remote net.Addr, races *race.Set, newSource func(io.Reader) eventSource, seen *eventDedup) {
+	src := newSource(bytes.NewReader(payload))
+	for {
+		ev, err := src.next()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			logrus.Errorf("%s: %s", remote, err)
+			return
+		}
+
+		if !seen.add(ev) {
+			continue
+		}
+		// Датаграммы могут теряться: несогласованность только отмечаем
+		if gap, skip := races.Race(ev.Race).SequenceGap(ev); gap != "" {
+			logrus.Warnf("Пропуск в данных участника %s: %s, событие: %s", ev.CompetitorID, gap, ev.Raw)
+			if skip {
+				continue
+			}
+		}
+
+		if err := races.Apply(ev); err != nil {
+			logrus.Errorf("%s: %s", remote, err)
+		}
+	}
+}
+
+// eventDedup отбрасывает повторно переданные события. Событие считается
+// повтором, если совпадают гонка, время, ID, участник и дополнительный
+// параметр.
+type eventDedup struct {
+	seen map[string]struct{}
+}
+
+func newEventDedup() *eventDedup {
+	return &eventDedup{seen: make(map[string]struct{})}
+}
+
+// add запоминает событие и сообщает, встретилось ли оно впервые
+func (d *eventDedup) add(ev parser.Event) bool {
+	key := ev.Race + " " + ev.Time.Format(parser.DateFormat+parser.TimeFormat) + " " + strconv.Itoa(ev.ID) + " " + ev.CompetitorID + " " + ev.Extra
+	if _, ok := d.seen[key]; ok {
+		return false
+	}
+	d.seen[key] = struct{}{}
+	return true
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"biathlon_system/internal/race"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+const webSocketWriteTimeout = 5 * time.Second
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// standingsHub рассылает снимки таблицы результатов подключённым зрителям.
+// У каждого зрителя в очереди хранится не больше buffer снимков: если он
+// не успевает их забирать, самый старый отбрасывается, и приём событий
+// из-за медленного клиента не блокируется.
+type standingsHub struct {
+	mu      sync.Mutex
+	buffer  int
+	viewers map[chan []byte]struct{}
+}
+
+// newStandingsHub возвращает рассыльщик, у зрителей которого в очереди
+// только последний снимок
+func newStandingsHub() *standingsHub {
+	return newBufferedHub(1)
+}
+
+func newBufferedHub(buffer int) *standingsHub {
+	return &standingsHub{buffer: buffer, viewers: make(map[chan []byte]struct{})}
+}
+
+func (h *standingsHub) subscribe() chan []byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	updates := make(chan []byte, h.buffer)
+	h.viewers[updates] = struct{}{}
+	return updates
+}
+
+func (h *standingsHub) unsubscribe(updates chan []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.viewers, updates)
+}
+
+func (h *standingsHub) publish(snapshot []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for updates := range h.viewers {
+		// Зритель только читает из очереди, поэтому место в ней освобождается
+		for sent := false; !sent; {
+			select {
+			case updates <- snapshot:
+				sent = true
+			default:
+				select {
+				case <-updates:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// raceHubs — рассыльщики снимков таблиц по идентификаторам гонок
+type raceHubs struct {
+	mu   sync.Mutex
+	hubs map[string]*standingsHub
+}
+
+func newRaceHubs() *raceHubs {
+	return &raceHubs{hubs: make(map[string]*standingsHub)}
+}
+
+// hub возвращает рассыльщик снимков таблицы гонки raceID
+func (h *raceHubs) hub(raceID string) *standingsHub {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hub, ok := h.hubs[raceID]
+	if !ok {
+		hub = newStandingsHub()
+		h.hubs[raceID] = hub
+	}
+	return hub
+}
+
+// serveWebSocket принимает события текстовыми сообщениями и отправляет
+// клиенту таблицу результатов гонки ?race=<id> после каждого обработанного
+// события этой гонки
+func serveWebSocket(w http.ResponseWriter, r *http.Request, races *race.Set, hubs *raceHubs) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logrus.Errorf("Ошибка установки WebSocket-соединения: %s", err)
+		return
+	}
+	defer conn.Close()
+
+	raceID := r.URL.Query().Get("race")
+	hub := hubs.hub(raceID)
+	updates := hub.subscribe()
+	defer hub.unsubscribe(updates)
+
+	if snapshot, err := standingsJSON(races.Race(raceID)); err == nil {
+		updates <- snapshot
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			messageType, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if messageType != websocket.TextMessage {
+				continue
+			}
+			if err := applyEventLine(string(message), races, hubs); err != nil {
+				logrus.Errorf("%s: %s", conn.RemoteAddr(), err)
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		case snapshot := <-updates:
+			conn.SetWriteDeadline(time.Now().Add(webSocketWriteTimeout))
+			if err := conn.WriteMessage(websocket.TextMessage, snapshot); err != nil {
+				return
+			}
+		}
+	}
+}
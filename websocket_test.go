@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"biathlon_system/internal/race"
+	"biathlon_system/internal/report"
+	"biathlon_system/parser"
+
+	"github.com/gorilla/websocket"
+)
+
+// standingsProgress — сумма пройденных кругов и попаданий в снимке таблицы;
+// по мере гонки она не убывает
+func standingsProgress(t *testing.T, snapshot []byte) int {
+	t.Helper()
+
+	var rows []struct {
+		Laps []struct {
+			Time string `json:"time"`
+		} `json:"laps"`
+		Hits int `json:"hits"`
+	}
+	if err := json.Unmarshal(snapshot, &rows); err != nil {
+		t.Fatalf("снимок таблицы %s: %v", snapshot, err)
+	}
+	progress := 0
+	for _, row := range rows {
+		for _, lap := range row.Laps {
+			if lap.Time != "" {
+				progress++
+			}
+		}
+		progress += row.Hits
+	}
+	return progress
+}
+
+func TestWebSocketStandings(t *testing.T) {
+	configs := testConfigs(t, "")
+	server := httptest.NewServer(newHTTPHandler(race.NewSet(configs), report.Text{}, newRaceHubs()))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	viewer, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer viewer.Close()
+	// Первый снимок — таблица до событий
+	if _, _, err := viewer.ReadMessage(); err != nil {
+		t.Fatal(err)
+	}
+
+	feed, err := os.ReadFile("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := race.New(configs.Base)
+	sender, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+	for _, line := range strings.Split(strings.TrimSpace(string(feed)), "\n") {
+		ev, err := parser.ParseEvent(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := want.Apply(ev); err != nil {
+			t.Fatal(err)
+		}
+		if err := sender.WriteMessage(websocket.TextMessage, []byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	final, err := standingsJSON(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Медленный зритель может пропустить снимки, но каждый следующий
+	// полученный не хуже предыдущего, а последний — итоговая таблица
+	viewer.SetReadDeadline(time.Now().Add(5 * time.Second))
+	last := 0
+	for {
+		_, snapshot, err := viewer.ReadMessage()
+		if err != nil {
+			t.Fatalf("итоговая таблица не получена: %v", err)
+		}
+		progress := standingsProgress(t, snapshot)
+		if progress < last {
+			t.Errorf("снимок хуже предыдущего: %d < %d", progress, last)
+		}
+		last = progress
+		if string(snapshot) == string(final) {
+			break
+		}
+	}
+}